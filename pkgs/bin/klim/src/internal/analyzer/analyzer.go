@@ -1,26 +1,39 @@
 package analyzer
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 
 	corev1 "k8s.io/api/core/v1"
 
 	"klim/internal/kubernetes"
 	"klim/internal/progress"
+	"klim/internal/prometheus"
 	"klim/internal/recommendations"
 	"klim/pkg/types"
 )
 
 // Analyzer coordinates the analysis process.
 type Analyzer struct {
-	k8sClient        *kubernetes.Client
-	prometheusClient types.PrometheusClient
-	engine           *recommendations.Engine
-	config           *types.Config
-	progressTracker  *progress.Tracker
-	bulkData         map[string]map[string][]types.MetricPoint
-	bulkDataMu       sync.RWMutex
+	k8sClient            *kubernetes.Client
+	prometheusClient     types.PrometheusClient
+	engine               *recommendations.Engine
+	config               *types.Config
+	progressTracker      *progress.Tracker
+	bulkData             map[string]map[string][]types.MetricPoint
+	bulkEphemeral        map[string]map[string][]types.MetricPoint
+	bulkSummary          map[string]map[string]types.UsageSummary
+	bulkEphemeralSummary map[string]map[string]types.UsageSummary
+	nodePeaks            map[string]map[string]map[string]float64
+	replicaPeaks         map[string]map[string]map[int]float64
+	emptyDirPeaks        map[string]map[string]float64
+	minReplicas          map[string]int
+	bulkDataMu           sync.RWMutex
+	unanalyzed           []string
+	unanalyzedMu         sync.Mutex
 }
 
 // NewAnalyzer creates a new analyzer.
@@ -44,12 +57,14 @@ func (a *Analyzer) SetProgressTracker(tracker *progress.Tracker) {
 }
 
 // Analyze performs the analysis and generates recommendations.
-func (a *Analyzer) Analyze() ([]types.Recommendation, error) {
+// If ctx is cancelled the recommendations completed so far are returned together
+// with the context error; Unanalyzed reports the workloads that were not finished.
+func (a *Analyzer) Analyze(ctx context.Context) ([]types.Recommendation, error) {
 	if a.config.Verbose {
 		fmt.Println("Fetching pods from Kubernetes cluster...")
 	}
 
-	pods, err := a.k8sClient.GetPods(a.config.Namespaces, a.config.LabelSelector)
+	pods, err := a.k8sClient.GetPods(ctx, a.config.Namespaces, a.config.LabelSelector)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pods: %w", err)
 	}
@@ -73,22 +88,6 @@ func (a *Analyzer) Analyze() ([]types.Recommendation, error) {
 		}
 	}
 
-	// Fetch all memory data upfront with a single bulk query
-	if a.config.Verbose {
-		fmt.Println("Fetching memory usage data from Prometheus...")
-	}
-	bulkData, err := a.prometheusClient.BulkQueryMemoryUsage(a.config.Namespaces, a.config.HistoryDuration)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch bulk memory data: %w", err)
-	}
-	a.bulkDataMu.Lock()
-	a.bulkData = bulkData
-	a.bulkDataMu.Unlock()
-
-	if a.config.Verbose {
-		fmt.Printf("Fetched data for %d workloads\n", len(bulkData))
-	}
-
 	// Group pods by workload to avoid duplicate analysis of replicas
 	workloadPods := make(map[string]corev1.Pod)
 	for _, pod := range runningPods {
@@ -111,6 +110,25 @@ func (a *Analyzer) Analyze() ([]types.Recommendation, error) {
 		fmt.Printf("Grouped into %d unique workloads (from %d pods)\n", len(workloadPods), len(runningPods))
 	}
 
+	// Fetch all usage data upfront with bulk queries
+	if err := a.fetchBulkData(ctx); err != nil {
+		if ctx.Err() != nil {
+			for key := range workloadPods {
+				a.markUnanalyzed(key)
+			}
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	// Per-node and per-replica-count breakdowns are optional refinements
+	if err := a.fetchBreakdowns(ctx, workloadPods); err != nil {
+		for key := range workloadPods {
+			a.markUnanalyzed(key)
+		}
+		return nil, err
+	}
+
 	// Update progress tracker with actual workload count
 	if a.progressTracker != nil {
 		a.progressTracker.UpdateTotal(len(workloadPods))
@@ -131,20 +149,35 @@ func (a *Analyzer) Analyze() ([]types.Recommendation, error) {
 		fmt.Printf("Processing with concurrency: %d\n", concurrency)
 	}
 
-	for _, pod := range workloadPods {
+	for key, pod := range workloadPods {
 		wg.Add(1)
-		pod := pod
+		key, pod := key, pod
 		go func() {
 			defer wg.Done()
-			semaphore <- struct{}{}
+
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				a.markUnanalyzed(key)
+				return
+			}
 			defer func() { <-semaphore }()
 
+			if ctx.Err() != nil {
+				a.markUnanalyzed(key)
+				return
+			}
+
 			if a.progressTracker != nil {
 				a.progressTracker.StartProcessing(pod.Namespace, pod.Name)
 				defer a.progressTracker.FinishProcessing(pod.Name)
 			}
 
-			podRecs := a.analyzePod(pod)
+			podRecs, err := a.analyzePod(ctx, pod)
+			if err != nil {
+				a.markUnanalyzed(key)
+				return
+			}
 			if len(podRecs) > 0 {
 				mu.Lock()
 				recommendations = append(recommendations, podRecs...)
@@ -167,23 +200,245 @@ func (a *Analyzer) Analyze() ([]types.Recommendation, error) {
 		fmt.Printf("Generated %d recommendations\n", len(recommendations))
 	}
 
+	if ctx.Err() != nil {
+		return recommendations, ctx.Err()
+	}
+
 	return recommendations, nil
 }
 
+// fetchBulkData loads usage data for all workloads. Full time series are only fetched
+// when graphs need them; otherwise Prometheus aggregates peak and percentiles itself.
+func (a *Analyzer) fetchBulkData(ctx context.Context) error {
+	if a.config.Verbose {
+		fmt.Println("Fetching memory usage data from Prometheus...")
+	}
+
+	if a.config.FetchHistory {
+		bulkData, err := a.prometheusClient.BulkQueryMemoryUsage(ctx, a.config.Namespaces, a.config.HistoryDuration)
+		if err != nil {
+			return fmt.Errorf("failed to fetch bulk memory data: %w", err)
+		}
+		a.bulkDataMu.Lock()
+		a.bulkData = bulkData
+		a.bulkDataMu.Unlock()
+
+		if a.config.Verbose {
+			fmt.Printf("Fetched data for %d workloads\n", len(bulkData))
+		}
+	} else {
+		bulkSummary, err := a.prometheusClient.BulkQueryMemorySummary(ctx, a.config.Namespaces, a.config.HistoryDuration)
+		if err != nil {
+			return fmt.Errorf("failed to fetch bulk memory summary: %w", err)
+		}
+		a.bulkDataMu.Lock()
+		a.bulkSummary = bulkSummary
+		a.bulkDataMu.Unlock()
+
+		if a.config.Verbose {
+			fmt.Printf("Fetched aggregated data for %d workloads\n", len(bulkSummary))
+		}
+	}
+
+	if a.config.Verbose {
+		fmt.Println("Fetching ephemeral-storage usage data from Prometheus...")
+	}
+
+	// Ephemeral-storage metrics are optional; recommendations are skipped if unavailable
+	var err error
+	var count int
+	if a.config.FetchHistory {
+		var bulkEphemeral map[string]map[string][]types.MetricPoint
+		bulkEphemeral, err = a.prometheusClient.BulkQueryEphemeralStorageUsage(ctx, a.config.Namespaces, a.config.HistoryDuration)
+		if err == nil {
+			a.bulkDataMu.Lock()
+			a.bulkEphemeral = bulkEphemeral
+			a.bulkDataMu.Unlock()
+			count = len(bulkEphemeral)
+		}
+	} else {
+		var bulkEphemeralSummary map[string]map[string]types.UsageSummary
+		bulkEphemeralSummary, err = a.prometheusClient.BulkQueryEphemeralStorageSummary(ctx, a.config.Namespaces, a.config.HistoryDuration)
+		if err == nil {
+			a.bulkDataMu.Lock()
+			a.bulkEphemeralSummary = bulkEphemeralSummary
+			a.bulkDataMu.Unlock()
+			count = len(bulkEphemeralSummary)
+		}
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if a.config.Verbose {
+			fmt.Printf("Warning: failed to fetch ephemeral-storage data: %v\n", err)
+		}
+	} else if a.config.Verbose {
+		fmt.Printf("Fetched ephemeral-storage data for %d workloads\n", count)
+	}
+
+	// emptyDir usage is optional too; without it sizeLimits are left unchanged
+	emptyDirPeaks, err := a.prometheusClient.BulkQueryEmptyDirUsage(ctx, a.config.Namespaces, a.config.HistoryDuration)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// A missing exporter is a setup problem worth reporting even without --verbose
+		if errors.Is(err, prometheus.ErrEmptyDirMetricsMissing) {
+			fmt.Printf("Warning: emptyDir sizeLimits are left unchanged: %v\n", err)
+		} else if a.config.Verbose {
+			fmt.Printf("Warning: failed to fetch emptyDir usage data: %v\n", err)
+		}
+	} else {
+		a.bulkDataMu.Lock()
+		a.emptyDirPeaks = emptyDirPeaks
+		a.bulkDataMu.Unlock()
+
+		if a.config.Verbose {
+			fmt.Printf("Fetched emptyDir usage data for %d workloads\n", len(emptyDirPeaks))
+		}
+	}
+
+	return nil
+}
+
+// fetchBreakdowns loads per-node usage for DaemonSets and per-replica-count usage for
+// HPA-managed workloads. Failures only disable the breakdowns unless ctx was cancelled.
+func (a *Analyzer) fetchBreakdowns(ctx context.Context, workloadPods map[string]corev1.Pod) error {
+	hasDaemonSets := false
+	for _, pod := range workloadPods {
+		if kubernetes.GetWorkloadKind(pod) == "DaemonSet" {
+			hasDaemonSets = true
+			break
+		}
+	}
+
+	if hasDaemonSets {
+		if a.config.Verbose {
+			fmt.Println("Fetching per-node DaemonSet usage from Prometheus...")
+		}
+		nodePeaks, err := a.prometheusClient.BulkQueryMemoryByNode(ctx, a.config.Namespaces, a.config.HistoryDuration)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if a.config.Verbose {
+				fmt.Printf("Warning: failed to fetch per-node usage: %v\n", err)
+			}
+		} else {
+			a.bulkDataMu.Lock()
+			a.nodePeaks = nodePeaks
+			a.bulkDataMu.Unlock()
+		}
+	}
+
+	minReplicas, err := a.k8sClient.GetHPAMinReplicas(ctx, a.config.Namespaces)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if a.config.Verbose {
+			fmt.Printf("Warning: failed to list horizontal pod autoscalers: %v\n", err)
+		}
+		return nil
+	}
+	if len(minReplicas) == 0 {
+		return nil
+	}
+
+	// Only query the autoscaled workloads that are being analysed
+	targets := make(map[string]int)
+	namespaceSet := make(map[string]bool)
+	workloadSet := make(map[string]bool)
+	for _, pod := range workloadPods {
+		workloadName := kubernetes.GetWorkloadName(pod)
+		key := fmt.Sprintf("%s/%s/%s", pod.Namespace, kubernetes.GetWorkloadKind(pod), workloadName)
+		if replicas, ok := minReplicas[key]; ok {
+			targets[key] = replicas
+			namespaceSet[pod.Namespace] = true
+			workloadSet[workloadName] = true
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	namespaces := sortedKeys(namespaceSet)
+	workloads := sortedKeys(workloadSet)
+
+	if a.config.Verbose {
+		fmt.Printf("Fetching per-replica usage for %d autoscaled workloads from Prometheus...\n", len(targets))
+	}
+	replicaPeaks, err := a.prometheusClient.BulkQueryMemoryByReplicas(ctx, namespaces, workloads, a.config.HistoryDuration)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if a.config.Verbose {
+			fmt.Printf("Warning: failed to fetch per-replica usage: %v\n", err)
+		}
+		return nil
+	}
+
+	a.bulkDataMu.Lock()
+	a.minReplicas = targets
+	a.replicaPeaks = replicaPeaks
+	a.bulkDataMu.Unlock()
+
+	return nil
+}
+
+// sortedKeys returns the keys of set in sorted order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Unanalyzed returns the "namespace/workload" keys that were not analysed because
+// the analysis was cancelled.
+func (a *Analyzer) Unanalyzed() []string {
+	a.unanalyzedMu.Lock()
+	defer a.unanalyzedMu.Unlock()
+
+	result := make([]string, len(a.unanalyzed))
+	copy(result, a.unanalyzed)
+	sort.Strings(result)
+	return result
+}
+
+// markUnanalyzed records a workload that could not be analysed.
+func (a *Analyzer) markUnanalyzed(key string) {
+	a.unanalyzedMu.Lock()
+	defer a.unanalyzedMu.Unlock()
+	a.unanalyzed = append(a.unanalyzed, key)
+}
+
 // analyzePod analyzes a single pod and returns recommendations for its containers.
-func (a *Analyzer) analyzePod(pod corev1.Pod) []types.Recommendation {
+// An error is only returned if ctx was cancelled before all containers were analysed.
+func (a *Analyzer) analyzePod(ctx context.Context, pod corev1.Pod) ([]types.Recommendation, error) {
 	var recommendations []types.Recommendation
 
 	workloadKind := kubernetes.GetWorkloadKind(pod)
 	workloadName := kubernetes.GetWorkloadName(pod)
 
 	for _, container := range pod.Spec.Containers {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
 		if a.config.Verbose {
 			fmt.Printf("Analyzing %s/%s container %s\n", pod.Namespace, pod.Name, container.Name)
 		}
 
-		metrics, err := a.collectMetrics(pod, container.Name)
+		metrics, err := a.collectMetrics(ctx, pod, container.Name)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			if a.config.Verbose {
 				fmt.Printf("Warning: failed to collect metrics for %s/%s/%s: %v\n",
 					pod.Namespace, pod.Name, container.Name, err)
@@ -191,7 +446,7 @@ func (a *Analyzer) analyzePod(pod corev1.Pod) []types.Recommendation {
 			continue
 		}
 
-		if len(metrics.MemoryUsage) == 0 {
+		if len(metrics.MemoryUsage) == 0 && metrics.MemorySummary == nil {
 			if a.config.Verbose {
 				fmt.Printf("Warning: no metrics found for %s/%s/%s (checked %s history)\n",
 					pod.Namespace, pod.Name, container.Name, a.config.HistoryDuration)
@@ -209,15 +464,17 @@ func (a *Analyzer) analyzePod(pod corev1.Pod) []types.Recommendation {
 		recommendations = append(recommendations, rec)
 	}
 
-	return recommendations
+	return recommendations, nil
 }
 
 // collectMetrics collects resource metrics for a container.
-func (a *Analyzer) collectMetrics(pod corev1.Pod, containerName string) (types.ResourceMetrics, error) {
+func (a *Analyzer) collectMetrics(ctx context.Context, pod corev1.Pod, containerName string) (types.ResourceMetrics, error) {
 	// Get the app instance label from the pod
 	appInstance, hasLabel := pod.Labels["app.kubernetes.io/instance"]
 
 	var memoryUsage []types.MetricPoint
+	var ephemeralUsage []types.MetricPoint
+	var memorySummary, ephemeralSummary *types.UsageSummary
 
 	// Try to get data from bulk query results
 	if hasLabel && appInstance != "" {
@@ -225,8 +482,20 @@ func (a *Analyzer) collectMetrics(pod corev1.Pod, containerName string) (types.R
 
 		a.bulkDataMu.RLock()
 		workloadData, found := a.bulkData[key]
+		ephemeralUsage = a.bulkEphemeral[key][containerName]
+		if summary, ok := a.bulkSummary[key][containerName]; ok {
+			memorySummary = &summary
+		}
+		if summary, ok := a.bulkEphemeralSummary[key][containerName]; ok {
+			ephemeralSummary = &summary
+		}
 		a.bulkDataMu.RUnlock()
 
+		if memorySummary != nil && a.config.Verbose {
+			fmt.Printf("  Found aggregated data for %s/%s/%s (peak %.0fMi)\n",
+				pod.Namespace, appInstance, containerName, memorySummary.Peak/(1024*1024))
+		}
+
 		if found {
 			containerData, hasContainer := workloadData[containerName]
 			if hasContainer {
@@ -240,7 +509,7 @@ func (a *Analyzer) collectMetrics(pod corev1.Pod, containerName string) (types.R
 	}
 
 	// Fallback to pod-specific query if no bulk data available
-	if len(memoryUsage) == 0 {
+	if len(memoryUsage) == 0 && memorySummary == nil {
 		if a.config.Verbose {
 			fmt.Printf("  No bulk data for %s/%s/%s, falling back to pod query\n",
 				pod.Namespace, pod.Name, containerName)
@@ -248,6 +517,7 @@ func (a *Analyzer) collectMetrics(pod corev1.Pod, containerName string) (types.R
 
 		var err error
 		memoryUsage, err = a.prometheusClient.QueryMemoryUsage(
+			ctx,
 			pod.Namespace,
 			pod.Name,
 			containerName,
@@ -258,15 +528,44 @@ func (a *Analyzer) collectMetrics(pod corev1.Pod, containerName string) (types.R
 		}
 	}
 
+	// Breakdowns are keyed by the owning workload rather than the instance label
+	workloadKind := kubernetes.GetWorkloadKind(pod)
+	workloadName := kubernetes.GetWorkloadName(pod)
+	workloadKey := fmt.Sprintf("%s/%s", pod.Namespace, workloadName)
+
+	a.bulkDataMu.RLock()
+	var nodePeaks map[string]float64
+	if workloadKind == "DaemonSet" {
+		nodePeaks = a.nodePeaks[workloadKey][containerName]
+	}
+	minReplicas := a.minReplicas[fmt.Sprintf("%s/%s/%s", pod.Namespace, workloadKind, workloadName)]
+	replicaPeaks := a.replicaPeaks[workloadKey][containerName]
+	emptyDirPeaks := a.emptyDirPeaks[workloadKey]
+	a.bulkDataMu.RUnlock()
+
 	_, memoryLimit, _, memoryRequest := kubernetes.GetContainerResources(pod, containerName)
+	ephemeralLimit, ephemeralRequest := kubernetes.GetContainerEphemeralStorage(pod, containerName)
 
 	return types.ResourceMetrics{
 		Namespace:      pod.Namespace,
 		Pod:            pod.Name,
 		Container:      containerName,
 		MemoryUsage:    memoryUsage,
+		MemorySummary:  memorySummary,
 		CurrentMemory:  memoryLimit,
 		CurrentRequest: memoryRequest,
+
+		EphemeralUsage:          ephemeralUsage,
+		EphemeralSummary:        ephemeralSummary,
+		CurrentEphemeral:        ephemeralLimit,
+		CurrentEphemeralRequest: ephemeralRequest,
+		EmptyDirPeaks:           emptyDirPeaks,
+
+		Runtime: kubernetes.GetContainerRuntime(pod, containerName),
+
+		NodePeaks:    nodePeaks,
+		ReplicaPeaks: replicaPeaks,
+		MinReplicas:  minReplicas,
 	}, nil
 }
 
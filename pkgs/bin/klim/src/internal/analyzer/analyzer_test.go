@@ -0,0 +1,372 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"klim/internal/config"
+	"klim/internal/kubernetes"
+	"klim/internal/prometheus"
+	"klim/internal/recommendations"
+	"klim/internal/testutil"
+	"klim/pkg/types"
+)
+
+const mib = 1024 * 1024
+
+// replicaSetHash is the pod-template-hash of test ReplicaSets, whose length with the
+// separating dash is what kubernetes.GetWorkloadName strips.
+const replicaSetHash = "5d4f8b9c7"
+
+// testPod builds a running pod of a Deployment with a single container. The pod is owned
+// by the workload's ReplicaSet and named after it with suffix.
+func testPod(namespace, workload, suffix, instance, container, memoryLimit string) *corev1.Pod {
+	replicaSet := workload + "-" + replicaSetHash
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      replicaSet + "-" + suffix,
+			Labels:    map[string]string{},
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: replicaSet},
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: container}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if instance != "" {
+		pod.Labels["app.kubernetes.io/instance"] = instance
+	}
+	if memoryLimit != "" {
+		pod.Spec.Containers[0].Resources.Limits = corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse(memoryLimit),
+		}
+	}
+	return pod
+}
+
+func TestAnalyze(t *testing.T) {
+	crashLooping := testPod("default", "broken", "abcde", "broken", "app", "128Mi")
+	crashLooping.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "app", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+	}
+
+	tests := []struct {
+		name         string
+		fetchHistory bool
+		pods         []*corev1.Pod
+		series       map[string][]testutil.Series
+		want         map[string]float64 // namespace/workload/container -> recommended limit (Mi)
+	}{
+		{
+			name: "bulk data grouped by instance",
+			pods: []*corev1.Pod{
+				testPod("default", "echo", "abcde", "echo", "app", "512Mi"),
+				testPod("default", "echo", "fghij", "echo", "app", "512Mi"),
+			},
+			series: map[string][]testutil.Series{
+				"container_memory_working_set_bytes": {{
+					Labels: map[string]string{"namespace": "default", "owner_name": "echo", "container": "app"},
+					Values: []float64{50 * mib, 100 * mib},
+				}},
+			},
+			want: map[string]float64{"default/echo/app": 150},
+		},
+		{
+			name:         "bulk range data when history is needed",
+			fetchHistory: true,
+			pods: []*corev1.Pod{
+				testPod("default", "echo", "abcde", "echo", "app", "512Mi"),
+			},
+			series: map[string][]testutil.Series{
+				"container_memory_working_set_bytes": {{
+					Labels: map[string]string{"namespace": "default", "owner_name": "echo", "container": "app"},
+					Values: []float64{200 * mib, 100 * mib},
+				}},
+			},
+			want: map[string]float64{"default/echo/app": 300},
+		},
+		{
+			name: "falls back to pod query without instance label",
+			pods: []*corev1.Pod{
+				testPod("media", "plex", "abcde", "", "plex", ""),
+			},
+			series: map[string][]testutil.Series{
+				`pod=~"plex-` + replicaSetHash + `.*"`: {{
+					Labels: map[string]string{},
+					Values: []float64{200 * mib},
+				}},
+			},
+			want: map[string]float64{"media/plex/plex": 300},
+		},
+		{
+			name: "skips crash looping pods and pods without metrics",
+			pods: []*corev1.Pod{
+				crashLooping,
+				testPod("default", "quiet", "abcde", "quiet", "app", "64Mi"),
+			},
+			want: map[string]float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := testutil.NewPrometheusStub()
+			defer stub.Close()
+			for match, series := range tt.series {
+				stub.AddSeries(match, series...)
+			}
+
+			an := newTestAnalyzer(t, stub, tt.pods)
+			an.config.FetchHistory = tt.fetchHistory
+
+			recs, err := an.Analyze(context.Background())
+			if err != nil {
+				t.Fatalf("Analyze() error: %v", err)
+			}
+
+			got := make(map[string]float64)
+			for _, rec := range recs {
+				if rec.MemoryStats.Peak == 0 {
+					t.Errorf("%s/%s: missing usage stats", rec.WorkloadName, rec.Container)
+				}
+				got[fmt.Sprintf("%s/%s/%s", rec.Namespace, rec.WorkloadName, rec.Container)] = rec.RecommendedMemory.Value
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got recommendations %v, want %v", got, tt.want)
+			}
+			for key, limit := range tt.want {
+				if got[key] != limit {
+					t.Errorf("%s: limit = %v, want %v", key, got[key], limit)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeCancelled(t *testing.T) {
+	stub := testutil.NewPrometheusStub()
+	defer stub.Close()
+
+	an := newTestAnalyzer(t, stub, []*corev1.Pod{
+		testPod("default", "echo", "abcde", "echo", "app", "512Mi"),
+		testPod("default", "web", "abcde", "web", "app", "512Mi"),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The fake clientset ignores ctx, so cancellation is observed by the bulk Prometheus query
+	recs, err := an.Analyze(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Analyze() error = %v, want context.Canceled", err)
+	}
+	if len(recs) != 0 {
+		t.Errorf("got %d recommendations, want none", len(recs))
+	}
+
+	unanalyzed := an.Unanalyzed()
+	if len(unanalyzed) != 2 || unanalyzed[0] != "default/echo" || unanalyzed[1] != "default/web" {
+		t.Errorf("Unanalyzed() = %v, want [default/echo default/web]", unanalyzed)
+	}
+}
+
+func TestAnalyzeCancelledAfterBulkQueries(t *testing.T) {
+	stub := testutil.NewPrometheusStub()
+	defer stub.Close()
+	stub.AddSeries(`pod=~"echo-`+replicaSetHash+`.*"`, testutil.Series{Labels: map[string]string{}, Values: []float64{100 * mib}})
+
+	// Without instance labels both workloads are queried per pod once the bulk phase is done
+	web := testPod("default", "web", "abcde", "", "app", "512Mi")
+	an := newTestAnalyzer(t, stub, []*corev1.Pod{
+		testPod("default", "echo", "abcde", "", "app", "512Mi"),
+		web,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	an.prometheusClient = &cancellingClient{
+		PrometheusClient: an.prometheusClient,
+		keep:             "echo-",
+		cancel:           cancel,
+		kept:             make(chan struct{}),
+	}
+
+	recs, err := an.Analyze(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Analyze() error = %v, want context.Canceled", err)
+	}
+	if len(recs) != 1 || recs[0].WorkloadName != "echo" {
+		t.Errorf("got recommendations %+v, want the one for echo", recs)
+	}
+
+	unanalyzed := an.Unanalyzed()
+	if want := "default/" + web.Name; len(unanalyzed) != 1 || unanalyzed[0] != want {
+		t.Errorf("Unanalyzed() = %v, want [%s]", unanalyzed, want)
+	}
+}
+
+// cancellingClient interrupts the analysis from the per-pod query of any pod not named
+// with the keep prefix, after the query of the kept pod has been answered.
+type cancellingClient struct {
+	types.PrometheusClient
+	keep   string
+	cancel context.CancelFunc
+	kept   chan struct{}
+}
+
+func (c *cancellingClient) QueryMemoryUsage(ctx context.Context, namespace, pod, container string, duration time.Duration) ([]types.MetricPoint, error) {
+	if strings.HasPrefix(pod, c.keep) {
+		defer close(c.kept)
+		return c.PrometheusClient.QueryMemoryUsage(ctx, namespace, pod, container, duration)
+	}
+	<-c.kept
+	c.cancel()
+	return nil, ctx.Err()
+}
+
+func TestAnalyzeAutoscaled(t *testing.T) {
+	stub := testutil.NewPrometheusStub()
+	defer stub.Close()
+
+	web := map[string]string{"namespace": "default", "owner_name": "web", "container": "app"}
+	echo := map[string]string{"namespace": "default", "owner_name": "echo", "container": "app"}
+	stub.AddSeries("count by (namespace, owner_name, container)", testutil.Series{Labels: web, Values: []float64{4, 4}})
+	stub.AddSeries(`owner_name=~"(web)(-[a-z0-9]+)?"`, testutil.Series{Labels: web, Values: []float64{150 * mib, 200 * mib}})
+	stub.AddSeries("container_memory_working_set_bytes",
+		testutil.Series{Labels: web, Values: []float64{200 * mib}},
+		testutil.Series{Labels: echo, Values: []float64{100 * mib}},
+	)
+
+	an := newTestAnalyzer(t, stub, []*corev1.Pod{
+		testPod("default", "echo", "abcde", "echo", "app", "512Mi"),
+		testPod("default", "web", "abcde", "web", "app", "512Mi"),
+	})
+	minReplicas := int32(2)
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: "Deployment", Name: "web"},
+			MinReplicas:    &minReplicas,
+		},
+	}
+	if _, err := an.k8sClient.Clientset().AutoscalingV2().HorizontalPodAutoscalers("default").Create(context.Background(), hpa, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := an.Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("Analyze() error: %v", err)
+	}
+
+	// web never ran at 2 replicas, so its 200Mi at 4 replicas is scaled to 400Mi
+	got := make(map[string]float64)
+	for _, rec := range recs {
+		got[rec.WorkloadName] = rec.RecommendedMemory.Value
+	}
+	if got["web"] != 600 || got["echo"] != 150 {
+		t.Errorf("limits = %v, want map[echo:150 web:600]", got)
+	}
+
+	// Only the autoscaled workload is broken down by replica count
+	replicaQueries := 0
+	for _, query := range stub.Queries() {
+		if strings.Contains(query, "count by (namespace, owner_name, container)") {
+			replicaQueries++
+			if !strings.Contains(query, `owner_name=~"(web)(-[a-z0-9]+)?"`) {
+				t.Errorf("replica query is not restricted to the autoscaled workload: %s", query)
+			}
+		}
+	}
+	if replicaQueries != 1 {
+		t.Errorf("got %d replica count queries, want 1", replicaQueries)
+	}
+}
+
+func TestAnalyzeEmptyDirs(t *testing.T) {
+	stub := testutil.NewPrometheusStub()
+	defer stub.Close()
+
+	// StatefulSet names are not shortened, unlike the ReplicaSet names of Deployments
+	statefulSet := testPod("default", "", "", "cache-store", "app", "512Mi")
+	statefulSet.Name = "cache-store-0"
+	statefulSet.OwnerReferences = []metav1.OwnerReference{{Kind: "StatefulSet", Name: "cache-store"}}
+
+	cacheStore := map[string]string{"namespace": "default", "owner_name": "cache-store"}
+	webAPI := map[string]string{"namespace": "default", "owner_name": "web-api"}
+	stub.AddSeries("ephemeral_storage_container_volume_usage",
+		testutil.Series{Labels: withLabel(cacheStore, "volume_name", "data"), Values: []float64{300 * mib}},
+		testutil.Series{Labels: withLabel(webAPI, "volume_name", "tmp"), Values: []float64{100 * mib}},
+	)
+	stub.AddSeries("container_memory_working_set_bytes",
+		testutil.Series{Labels: withLabel(cacheStore, "container", "app"), Values: []float64{100 * mib}},
+		testutil.Series{Labels: withLabel(webAPI, "container", "app"), Values: []float64{100 * mib}},
+	)
+
+	an := newTestAnalyzer(t, stub, []*corev1.Pod{
+		statefulSet,
+		testPod("default", "web-api", "abcde", "web-api", "app", "512Mi"),
+	})
+
+	recs, err := an.Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("Analyze() error: %v", err)
+	}
+
+	got := make(map[string]map[string]float64)
+	for _, rec := range recs {
+		got[rec.WorkloadName] = make(map[string]float64)
+		for volume, size := range rec.RecommendedEmptyDirs {
+			got[rec.WorkloadName][volume] = size.Value
+		}
+	}
+	if got["cache-store"]["data"] == 0 || got["web-api"]["tmp"] == 0 || len(got["cache-store"])+len(got["web-api"]) != 2 {
+		t.Errorf("emptyDir sizes = %v, want data for cache-store and tmp for web-api", got)
+	}
+}
+
+// withLabel returns a copy of labels with name set to value.
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	out[name] = value
+	return out
+}
+
+// newTestAnalyzer wires an Analyzer to a fake clientset and the Prometheus stub.
+func newTestAnalyzer(t *testing.T, stub *testutil.PrometheusStub, pods []*corev1.Pod) *Analyzer {
+	t.Helper()
+
+	clientset := fake.NewClientset()
+	for _, pod := range pods {
+		if _, err := clientset.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	promClient, err := prometheus.NewClient(stub.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.HistoryDuration = time.Hour
+	cfg.Concurrency = 2
+
+	engine := recommendations.NewEngine(cfg.MemoryBuffer, cfg.MinMemory, cfg.EphemeralBuffer, cfg.MinEphemeral)
+	return NewAnalyzer(kubernetes.NewClientFromClientset(clientset, nil), promClient, engine, cfg)
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"time"
 
 	"klim/pkg/types"
@@ -9,16 +10,23 @@ import (
 // DefaultConfig returns the default configuration.
 func DefaultConfig() *types.Config {
 	return &types.Config{
-		HistoryDuration: 7 * 24 * time.Hour,
-		MemoryBuffer:    0.5,
-		MinMemory:       10.0,
-		OutputFormat:    "table",
-		Concurrency:     10,
+		HistoryDuration:  7 * 24 * time.Hour,
+		PrometheusAccess: "auto",
+		MemoryBuffer:     0.5,
+		MinMemory:        10.0,
+		EphemeralBuffer:  1.0,
+		MinEphemeral:     64.0,
+		OutputFormat:     "table",
+		Concurrency:      10,
+		QueryTimeout:     60 * time.Second,
 	}
 }
 
 // Validate checks if the configuration is valid.
 func Validate(cfg *types.Config) error {
-	// Add validation logic as needed
+	// A port-forward needs a discovered service to forward to
+	if cfg.PrometheusURL != "" && cfg.PrometheusAccess == "port-forward" {
+		return fmt.Errorf("--prometheus-access=port-forward only applies to discovered Prometheus and cannot be combined with --prometheus")
+	}
 	return nil
 }
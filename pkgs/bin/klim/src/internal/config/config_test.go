@@ -0,0 +1,30 @@
+package config
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		access  string
+		wantErr bool
+	}{
+		{name: "discovered with port-forward", access: "port-forward"},
+		{name: "explicit url", url: "http://prometheus:9090", access: "auto"},
+		{name: "explicit url with direct access", url: "http://prometheus:9090", access: "direct"},
+		{name: "explicit url with port-forward", url: "http://prometheus:9090", access: "port-forward", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.PrometheusURL = tt.url
+			cfg.PrometheusAccess = tt.access
+
+			err := Validate(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
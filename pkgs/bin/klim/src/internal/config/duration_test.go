@@ -0,0 +1,42 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "days", input: "7d", want: 7 * 24 * time.Hour},
+		{name: "weeks", input: "2w", want: 14 * 24 * time.Hour},
+		{name: "weeks and days", input: "1w3d", want: 10 * 24 * time.Hour},
+		{name: "hours", input: "168h", want: 168 * time.Hour},
+		{name: "minutes and seconds", input: "5m30s", want: 5*time.Minute + 30*time.Second},
+		{name: "zero", input: "0d", wantErr: true},
+		{name: "garbage", input: "soon", wantErr: true},
+		{name: "empty", input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDuration(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseDuration(%q) = %v, want error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDuration(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDuration(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -17,16 +18,22 @@ import (
 
 // Client wraps the Kubernetes client.
 type Client struct {
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
+	dynamic   dynamic.Interface
 	config    *rest.Config
 	context   string
 }
 
 // Clientset returns the underlying Kubernetes clientset.
-func (c *Client) Clientset() *kubernetes.Clientset {
+func (c *Client) Clientset() kubernetes.Interface {
 	return c.clientset
 }
 
+// Dynamic returns the dynamic client used for custom resources, or nil if unavailable.
+func (c *Client) Dynamic() dynamic.Interface {
+	return c.dynamic
+}
+
 // Config returns the underlying Kubernetes config.
 func (c *Client) Config() *rest.Config {
 	return c.config
@@ -59,13 +66,27 @@ func NewClient(contextName string) (*Client, error) {
 		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
 	return &Client{
 		clientset: clientset,
+		dynamic:   dynamicClient,
 		config:    config,
 		context:   contextName,
 	}, nil
 }
 
+// NewClientFromClientset wraps an existing clientset, e.g. a fake clientset in tests.
+func NewClientFromClientset(clientset kubernetes.Interface, config *rest.Config) *Client {
+	return &Client{
+		clientset: clientset,
+		config:    config,
+	}
+}
+
 // getKubeconfigWithContext loads kubeconfig with optional context override.
 func getKubeconfigWithContext(contextName string) (*rest.Config, string, error) {
 	kubeconfig := os.Getenv("KUBECONFIG")
@@ -112,7 +133,7 @@ func getKubeconfigWithContext(contextName string) (*rest.Config, string, error)
 }
 
 // GetPods returns all pods in the specified namespaces with label selector.
-func (c *Client) GetPods(namespaces []string, labelSelector string) ([]corev1.Pod, error) {
+func (c *Client) GetPods(ctx context.Context, namespaces []string, labelSelector string) ([]corev1.Pod, error) {
 	var allPods []corev1.Pod
 
 	if len(namespaces) == 0 {
@@ -120,7 +141,7 @@ func (c *Client) GetPods(namespaces []string, labelSelector string) ([]corev1.Po
 	}
 
 	for _, namespace := range namespaces {
-		pods, err := c.clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
 			LabelSelector: labelSelector,
 		})
 		if err != nil {
@@ -132,6 +153,33 @@ func (c *Client) GetPods(namespaces []string, labelSelector string) ([]corev1.Po
 	return allPods, nil
 }
 
+// GetHPAMinReplicas returns the minReplicas of every HorizontalPodAutoscaler in the given
+// namespaces, keyed by "namespace/Kind/name" of the scale target.
+func (c *Client) GetHPAMinReplicas(ctx context.Context, namespaces []string) (map[string]int, error) {
+	result := make(map[string]int)
+
+	if len(namespaces) == 0 {
+		namespaces = []string{corev1.NamespaceAll}
+	}
+
+	for _, namespace := range namespaces {
+		hpas, err := c.clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list horizontal pod autoscalers in namespace %s: %w", namespace, err)
+		}
+		for _, hpa := range hpas.Items {
+			minReplicas := 1
+			if hpa.Spec.MinReplicas != nil {
+				minReplicas = int(*hpa.Spec.MinReplicas)
+			}
+			target := hpa.Spec.ScaleTargetRef
+			result[fmt.Sprintf("%s/%s/%s", hpa.Namespace, target.Kind, target.Name)] = minReplicas
+		}
+	}
+
+	return result, nil
+}
+
 // GetContainerResources extracts current resource requests and limits from a pod.
 func GetContainerResources(pod corev1.Pod, containerName string) (cpuLimit, memoryLimit, cpuRequest, memoryRequest types.ResourceQuantity) {
 	for _, container := range pod.Spec.Containers {
@@ -154,6 +202,28 @@ func GetContainerResources(pod corev1.Pod, containerName string) (cpuLimit, memo
 	return
 }
 
+// GetContainerEphemeralStorage extracts current ephemeral-storage limit and request from a pod.
+func GetContainerEphemeralStorage(pod corev1.Pod, containerName string) (limit, request types.ResourceQuantity) {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == containerName {
+			if storage, ok := container.Resources.Limits[corev1.ResourceEphemeralStorage]; ok {
+				limit = types.ResourceQuantity{
+					Value: float64(storage.Value()) / (1024 * 1024),
+					Unit:  "Mi",
+				}
+			}
+			if storage, ok := container.Resources.Requests[corev1.ResourceEphemeralStorage]; ok {
+				request = types.ResourceQuantity{
+					Value: float64(storage.Value()) / (1024 * 1024),
+					Unit:  "Mi",
+				}
+			}
+			break
+		}
+	}
+	return
+}
+
 // GetWorkloadKind determines the workload kind (Deployment, StatefulSet, etc.) for a pod.
 func GetWorkloadKind(pod corev1.Pod) string {
 	for _, owner := range pod.OwnerReferences {
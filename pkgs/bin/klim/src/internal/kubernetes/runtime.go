@@ -0,0 +1,135 @@
+package kubernetes
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"klim/pkg/types"
+)
+
+// javaOptionEnvs are the environment variables commonly used to pass JVM options.
+var javaOptionEnvs = []string{"JAVA_TOOL_OPTIONS", "JDK_JAVA_OPTIONS", "_JAVA_OPTIONS", "JAVA_OPTS"}
+
+var (
+	xmxPattern              = regexp.MustCompile(`-X(?:mx|X:MaxHeapSize=)(\d+)([kKmMgGtT]?)\b`)
+	maxRAMPercentagePattern = regexp.MustCompile(`-XX:MaxRAMPercentage=(\d+(?:\.\d+)?)`)
+	goMemLimitPattern       = regexp.MustCompile(`^(\d+)(B|KiB|MiB|GiB|TiB)?$`)
+)
+
+// GetContainerRuntime detects JVM heap and Go memory limit settings from a container's env and args.
+// Env values sourced via valueFrom cannot be resolved and are ignored.
+func GetContainerRuntime(pod corev1.Pod, containerName string) types.RuntimeSettings {
+	for _, container := range pod.Spec.Containers {
+		if container.Name != containerName {
+			continue
+		}
+
+		env := make(map[string]string)
+		for _, e := range container.Env {
+			if e.ValueFrom == nil {
+				env[e.Name] = e.Value
+			}
+		}
+
+		// Go: GOMEMLIMIT is only honoured from the environment
+		if value, ok := env["GOMEMLIMIT"]; ok {
+			if limit, ok := ParseGoMemLimit(value); ok {
+				return types.RuntimeSettings{
+					Runtime:    "go",
+					GoMemLimit: limit,
+					EnvName:    "GOMEMLIMIT",
+					EnvValue:   value,
+				}
+			}
+		}
+
+		// JVM: check well-known option env vars first, then command and args
+		for _, name := range javaOptionEnvs {
+			if value, ok := env[name]; ok {
+				if settings, ok := parseJavaOptions(value); ok {
+					settings.EnvName = name
+					settings.EnvValue = value
+					return settings
+				}
+			}
+		}
+
+		cmdline := strings.Join(append(append([]string{}, container.Command...), container.Args...), " ")
+		if settings, ok := parseJavaOptions(cmdline); ok {
+			return settings
+		}
+
+		break
+	}
+
+	return types.RuntimeSettings{}
+}
+
+// parseJavaOptions extracts heap settings from a string of JVM options.
+func parseJavaOptions(options string) (types.RuntimeSettings, bool) {
+	settings := types.RuntimeSettings{Runtime: "jvm"}
+	found := false
+
+	// The JVM uses the last occurrence of a repeated option
+	if matches := xmxPattern.FindAllStringSubmatch(options, -1); len(matches) > 0 {
+		match := matches[len(matches)-1]
+		if value, err := strconv.ParseFloat(match[1], 64); err == nil {
+			settings.HeapMax = value * javaUnitMiB(match[2])
+			found = true
+		}
+	}
+
+	if matches := maxRAMPercentagePattern.FindAllStringSubmatch(options, -1); len(matches) > 0 {
+		if value, err := strconv.ParseFloat(matches[len(matches)-1][1], 64); err == nil {
+			settings.MaxRAMPercentage = value
+			found = true
+		}
+	}
+
+	return settings, found
+}
+
+// javaUnitMiB returns the MiB multiplier for a JVM size suffix.
+func javaUnitMiB(unit string) float64 {
+	switch strings.ToLower(unit) {
+	case "k":
+		return 1.0 / 1024
+	case "m":
+		return 1
+	case "g":
+		return 1024
+	case "t":
+		return 1024 * 1024
+	default:
+		return 1.0 / (1024 * 1024)
+	}
+}
+
+// ParseGoMemLimit parses a GOMEMLIMIT value into MiB. "off" and invalid values return false.
+func ParseGoMemLimit(value string) (float64, bool) {
+	match := goMemLimitPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, false
+	}
+
+	n, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	switch match[2] {
+	case "KiB":
+		return n / 1024, true
+	case "MiB":
+		return n, true
+	case "GiB":
+		return n * 1024, true
+	case "TiB":
+		return n * 1024 * 1024, true
+	default:
+		return n / (1024 * 1024), true
+	}
+}
@@ -0,0 +1,122 @@
+package kubernetes
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"klim/pkg/types"
+)
+
+func TestParseJavaOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		want    types.RuntimeSettings
+		found   bool
+	}{
+		{name: "gigabytes", options: "-Xmx2g", want: types.RuntimeSettings{Runtime: "jvm", HeapMax: 2048}, found: true},
+		{name: "megabytes", options: "-Xms256m -Xmx768M", want: types.RuntimeSettings{Runtime: "jvm", HeapMax: 768}, found: true},
+		{name: "kilobytes", options: "-Xmx524288k", want: types.RuntimeSettings{Runtime: "jvm", HeapMax: 512}, found: true},
+		{name: "bytes without suffix", options: "-Xmx1073741824", want: types.RuntimeSettings{Runtime: "jvm", HeapMax: 1024}, found: true},
+		{name: "max heap size option", options: "-XX:MaxHeapSize=1g", want: types.RuntimeSettings{Runtime: "jvm", HeapMax: 1024}, found: true},
+		{name: "last -Xmx wins", options: "-Xmx1g -XX:+UseG1GC -Xmx512m", want: types.RuntimeSettings{Runtime: "jvm", HeapMax: 512}, found: true},
+		{name: "max RAM percentage", options: "-XX:MaxRAMPercentage=75.0", want: types.RuntimeSettings{Runtime: "jvm", MaxRAMPercentage: 75}, found: true},
+		{name: "no heap options", options: "-XX:+UseG1GC -Dfoo=bar"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := parseJavaOptions(tt.options)
+			if found != tt.found {
+				t.Fatalf("parseJavaOptions(%q) found = %v, want %v", tt.options, found, tt.found)
+			}
+			if found && got != tt.want {
+				t.Errorf("parseJavaOptions(%q) = %+v, want %+v", tt.options, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGoMemLimit(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+		ok    bool
+	}{
+		{value: "524288KiB", want: 512, ok: true},
+		{value: "768MiB", want: 768, ok: true},
+		{value: "2GiB", want: 2048, ok: true},
+		{value: "1TiB", want: 1024 * 1024, ok: true},
+		{value: "268435456", want: 256, ok: true},
+		{value: "268435456B", want: 256, ok: true},
+		{value: " 512MiB ", want: 512, ok: true},
+		{value: "off"},
+		{value: "512M"},
+		{value: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := ParseGoMemLimit(tt.value)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("ParseGoMemLimit(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGetContainerRuntime(t *testing.T) {
+	fromSecret := &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{Key: "opts"}}
+
+	tests := []struct {
+		name      string
+		container corev1.Container
+		want      types.RuntimeSettings
+	}{
+		{
+			name:      "GOMEMLIMIT",
+			container: corev1.Container{Env: []corev1.EnvVar{{Name: "GOMEMLIMIT", Value: "900MiB"}}},
+			want:      types.RuntimeSettings{Runtime: "go", GoMemLimit: 900, EnvName: "GOMEMLIMIT", EnvValue: "900MiB"},
+		},
+		{
+			name:      "GOMEMLIMIT off",
+			container: corev1.Container{Env: []corev1.EnvVar{{Name: "GOMEMLIMIT", Value: "off"}}},
+		},
+		{
+			name:      "JVM option env",
+			container: corev1.Container{Env: []corev1.EnvVar{{Name: "JAVA_OPTS", Value: "-Xmx1g"}}},
+			want:      types.RuntimeSettings{Runtime: "jvm", HeapMax: 1024, EnvName: "JAVA_OPTS", EnvValue: "-Xmx1g"},
+		},
+		{
+			name:      "JVM args",
+			container: corev1.Container{Command: []string{"java"}, Args: []string{"-Xmx512m", "-jar", "app.jar"}},
+			want:      types.RuntimeSettings{Runtime: "jvm", HeapMax: 512},
+		},
+		{
+			name: "valueFrom envs are ignored",
+			container: corev1.Container{Env: []corev1.EnvVar{
+				{Name: "GOMEMLIMIT", ValueFrom: fromSecret},
+				{Name: "JAVA_TOOL_OPTIONS", ValueFrom: fromSecret},
+			}},
+		},
+		{
+			name: "valueFrom env falls through to args",
+			container: corev1.Container{
+				Env:  []corev1.EnvVar{{Name: "JAVA_OPTS", ValueFrom: fromSecret}},
+				Args: []string{"-Xmx2g"},
+			},
+			want: types.RuntimeSettings{Runtime: "jvm", HeapMax: 2048},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.container.Name = "app"
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{tt.container}}}
+			if got := GetContainerRuntime(pod, "app"); got != tt.want {
+				t.Errorf("GetContainerRuntime() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
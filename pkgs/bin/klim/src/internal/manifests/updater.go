@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/fatih/color"
@@ -41,12 +43,83 @@ func (u *HelmReleaseUpdater) Update(filePath string, recommendations []types.Rec
 	inResources := false
 	inLimits := false
 	inRequests := false
+	inPersistence := false
+	persistenceIndent := 0
+	emptyDirs := []*emptyDirEntry{}
+	var currentEntry *emptyDirEntry
+	inEnv := false
+	envIndent := 0
+	envChildIndent := 0
+	envKey := ""
 
 	for i, line := range lines {
 		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		// Track bjw-s persistence entries so emptyDir sizeLimits can be updated
+		if inPersistence && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			if indent <= persistenceIndent {
+				inPersistence = false
+				currentEntry = nil
+			} else if strings.HasSuffix(trimmed, ":") && (currentEntry == nil || indent <= currentEntry.indent) {
+				currentEntry = &emptyDirEntry{name: strings.TrimSuffix(trimmed, ":"), indent: indent, lastLine: i, sizeLimitLine: -1}
+				emptyDirs = append(emptyDirs, currentEntry)
+				continue
+			} else if currentEntry != nil && indent > currentEntry.indent {
+				if currentEntry.childIndent == 0 {
+					currentEntry.childIndent = indent
+				}
+				currentEntry.lastLine = i
+				if trimmed == "type: emptyDir" {
+					currentEntry.isEmptyDir = true
+				} else if strings.HasPrefix(trimmed, "sizeLimit:") {
+					currentEntry.sizeLimitLine = i
+				}
+				continue
+			}
+		}
+
+		// Rewrite env values of the current container when the engine proposed new ones
+		if inEnv && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			if indent <= envIndent {
+				inEnv = false
+			} else {
+				// Keys of the map form are at the indent of the first line under env:
+				if envChildIndent == 0 {
+					envChildIndent = indent
+				}
+				proposed := recsByContainer[currentContainer].ProposedEnv
+				if match := envMapPattern.FindStringSubmatch(trimmed); match != nil && indent == envChildIndent {
+					// Map form: KEY: value, or KEY: followed by a nested value:
+					envKey = match[1]
+					if newValue, ok := proposed[envKey]; ok && match[2] != "" {
+						lines[i] = fmt.Sprintf("%s%s: %s", strings.Repeat(" ", indent), envKey, strconv.Quote(newValue))
+						modified = true
+					}
+				} else if match := envListPattern.FindStringSubmatch(trimmed); match != nil {
+					// List form: - name: KEY followed by value:
+					envKey = match[1]
+				} else if strings.HasPrefix(trimmed, "value:") {
+					if newValue, ok := proposed[envKey]; ok {
+						lines[i] = fmt.Sprintf("%svalue: %s", strings.Repeat(" ", indent), strconv.Quote(newValue))
+						modified = true
+					}
+				}
+				continue
+			}
+		}
 
 		// State transitions
-		if strings.HasPrefix(trimmed, "containers:") {
+		if strings.HasPrefix(trimmed, "persistence:") {
+			inPersistence = true
+			persistenceIndent = indent
+			inContainers = false
+			currentContainer = ""
+			inResources = false
+			inLimits = false
+			inRequests = false
+			continue
+		} else if strings.HasPrefix(trimmed, "containers:") {
 			inContainers = true
 			currentContainer = ""
 			inResources = false
@@ -65,6 +138,14 @@ func (u *HelmReleaseUpdater) Update(filePath string, recommendations []types.Rec
 			// Don't clear currentContainer for other keys - just ignore them
 		}
 
+		if currentContainer != "" && trimmed == "env:" && len(recsByContainer[currentContainer].ProposedEnv) > 0 {
+			inEnv = true
+			envIndent = indent
+			envChildIndent = 0
+			envKey = ""
+			continue
+		}
+
 		if currentContainer != "" && strings.HasPrefix(trimmed, "resources:") {
 			inResources = true
 			inLimits = false
@@ -82,7 +163,6 @@ func (u *HelmReleaseUpdater) Update(filePath string, recommendations []types.Rec
 				newValue := formatResourceQuantity(rec.RecommendedMemory)
 
 				// Preserve indentation
-				indent := len(line) - len(strings.TrimLeft(line, " "))
 				lineIndent := strings.Repeat(" ", indent)
 				lines[i] = fmt.Sprintf("%smemory: %s", lineIndent, newValue)
 				modified = true
@@ -95,13 +175,59 @@ func (u *HelmReleaseUpdater) Update(filePath string, recommendations []types.Rec
 					newValue := formatResourceQuantity(rec.RecommendedRequest)
 
 					// Preserve indentation
-					indent := len(line) - len(strings.TrimLeft(line, " "))
 					lineIndent := strings.Repeat(" ", indent)
 					lines[i] = fmt.Sprintf("%smemory: %s", lineIndent, newValue)
 					modified = true
 				}
 			}
+		} else if inLimits && strings.HasPrefix(trimmed, "ephemeral-storage:") {
+			// Update the ephemeral-storage limit if we have usage data for it
+			if currentContainer != "" {
+				rec := recsByContainer[currentContainer]
+				if rec.RecommendedEphemeral.Unit != "" {
+					newValue := formatResourceQuantity(rec.RecommendedEphemeral)
+					lines[i] = fmt.Sprintf("%sephemeral-storage: %s", strings.Repeat(" ", indent), newValue)
+					modified = true
+				}
+			}
+		} else if inRequests && strings.HasPrefix(trimmed, "ephemeral-storage:") {
+			// Update the ephemeral-storage request if it needs to be lowered
+			if currentContainer != "" {
+				rec := recsByContainer[currentContainer]
+				if rec.EphemeralRequestLowered {
+					newValue := formatResourceQuantity(rec.RecommendedEphemeralRequest)
+					lines[i] = fmt.Sprintf("%sephemeral-storage: %s", strings.Repeat(" ", indent), newValue)
+					modified = true
+				}
+			}
+		}
+	}
+
+	// emptyDir volumes count towards the pod's ephemeral-storage, so size each one
+	// from its own usage and add a sizeLimit where there is none yet
+	sizeLimits := emptyDirRecommendations(recommendations)
+	var inserts []*emptyDirEntry
+	for _, entry := range emptyDirs {
+		sizeLimit, ok := sizeLimits[entry.name]
+		if !entry.isEmptyDir || !ok {
+			continue
 		}
+		if entry.sizeLimitLine < 0 {
+			inserts = append(inserts, entry)
+			continue
+		}
+		line := lines[entry.sizeLimitLine]
+		lineIndent := strings.Repeat(" ", len(line)-len(strings.TrimLeft(line, " ")))
+		lines[entry.sizeLimitLine] = fmt.Sprintf("%ssizeLimit: %s", lineIndent, formatResourceQuantity(sizeLimit))
+		modified = true
+	}
+
+	// Insert from the end so the line numbers of earlier entries stay valid
+	for k := len(inserts) - 1; k >= 0; k-- {
+		entry := inserts[k]
+		newLine := fmt.Sprintf("%ssizeLimit: %s", strings.Repeat(" ", entry.childIndent), formatResourceQuantity(sizeLimits[entry.name]))
+		lines = append(lines[:entry.lastLine+1], append([]string{newLine}, lines[entry.lastLine+1:]...)...)
+		modified = true
 	}
 
 	if !modified {
@@ -111,6 +237,35 @@ func (u *HelmReleaseUpdater) Update(filePath string, recommendations []types.Rec
 	return strings.Join(lines, "\n"), nil
 }
 
+var (
+	envMapPattern  = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*):\s*(.*)$`)
+	envListPattern = regexp.MustCompile(`^-\s+name:\s*"?([A-Za-z_][A-Za-z0-9_]*)"?\s*$`)
+)
+
+// emptyDirEntry tracks a bjw-s persistence entry while scanning a manifest.
+type emptyDirEntry struct {
+	name          string // Persistence key, which bjw-s uses as the volume name
+	indent        int
+	childIndent   int // Indent of the entry's keys, for inserting a sizeLimit
+	lastLine      int // Last line of the entry
+	isEmptyDir    bool
+	sizeLimitLine int
+}
+
+// emptyDirRecommendations collects the emptyDir sizeLimits by volume name. They are the
+// same on every container of a pod, so the first recommendation carrying a volume wins.
+func emptyDirRecommendations(recommendations []types.Recommendation) map[string]types.ResourceQuantity {
+	sizeLimits := make(map[string]types.ResourceQuantity)
+	for _, rec := range recommendations {
+		for volume, sizeLimit := range rec.RecommendedEmptyDirs {
+			if _, ok := sizeLimits[volume]; !ok {
+				sizeLimits[volume] = sizeLimit
+			}
+		}
+	}
+	return sizeLimits
+}
+
 // formatResourceQuantity formats a resource quantity as a string.
 func formatResourceQuantity(rq types.ResourceQuantity) string {
 	if rq.Unit == "" {
@@ -125,7 +280,8 @@ func (u *HelmReleaseUpdater) ApplyChanges(filePath, updatedContent string) error
 	return os.WriteFile(filePath, []byte(updatedContent), 0644)
 }
 
-// GenerateDiff creates a simple diff between original and updated content.
+// GenerateDiff creates a line diff between original and updated content with
+// three lines of context around each change.
 func GenerateDiff(filePath, original, updated string) string {
 	var diff strings.Builder
 
@@ -137,64 +293,80 @@ func GenerateDiff(filePath, original, updated string) string {
 	cyan.Fprintf(&diff, "--- %s\n", filePath)
 	cyan.Fprintf(&diff, "+++ %s (updated)\n\n", filePath)
 
-	origLines := strings.Split(original, "\n")
-	updatedLines := strings.Split(updated, "\n")
-
-	maxLines := len(origLines)
-	if len(updatedLines) > maxLines {
-		maxLines = len(updatedLines)
-	}
+	ops := diffLines(strings.Split(original, "\n"), strings.Split(updated, "\n"))
 
 	contextLines := 3
-	inDiff := false
-	linesSinceChange := 0
+	lastPrinted := -1
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
 
-	for i := 0; i < maxLines; i++ {
-		var origLine, updatedLine string
+		// Print context before the change, separating hunks by a blank line
+		start := max(i-contextLines, lastPrinted+1)
+		if lastPrinted >= 0 && start > lastPrinted+1 {
+			diff.WriteString("\n")
+		}
+		for j := start; j < i; j++ {
+			diff.WriteString(fmt.Sprintf("  %s\n", ops[j].line))
+		}
 
-		if i < len(origLines) {
-			origLine = origLines[i]
+		if op.kind == '-' {
+			red.Fprintf(&diff, "- %s\n", op.line)
+		} else {
+			green.Fprintf(&diff, "+ %s\n", op.line)
 		}
-		if i < len(updatedLines) {
-			updatedLine = updatedLines[i]
+		lastPrinted = i
+
+		// Print context after the change; a following change prints the rest of the gap
+		for j := i + 1; j < len(ops) && j <= i+contextLines && ops[j].kind == ' '; j++ {
+			diff.WriteString(fmt.Sprintf("  %s\n", ops[j].line))
+			lastPrinted = j
 		}
+	}
 
-		if origLine != updatedLine {
-			// Print context before the change
-			if !inDiff {
-				start := i - contextLines
-				if start < 0 {
-					start = 0
-				}
-				for j := start; j < i; j++ {
-					if j < len(origLines) {
-						diff.WriteString(fmt.Sprintf("  %s\n", origLines[j]))
-					}
-				}
-			}
+	return diff.String()
+}
 
-			if origLine != "" && updatedLine != "" {
-				red.Fprintf(&diff, "- %s\n", origLine)
-				green.Fprintf(&diff, "+ %s\n", updatedLine)
-			} else if origLine != "" {
-				red.Fprintf(&diff, "- %s\n", origLine)
-			} else if updatedLine != "" {
-				green.Fprintf(&diff, "+ %s\n", updatedLine)
-			}
+// diffOp is a line of a diff: ' ' unchanged, '-' removed or '+' added.
+type diffOp struct {
+	kind byte
+	line string
+}
 
-			inDiff = true
-			linesSinceChange = 0
-		} else if inDiff {
-			// Print context after the change
-			if linesSinceChange < contextLines {
-				diff.WriteString(fmt.Sprintf("  %s\n", origLine))
-				linesSinceChange++
+// diffLines computes a line diff from the longest common subsequence of a and b.
+// Removed lines are listed before the lines added in their place.
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
 			} else {
-				inDiff = false
-				diff.WriteString("\n")
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
 			}
 		}
 	}
 
-	return diff.String()
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	return ops
 }
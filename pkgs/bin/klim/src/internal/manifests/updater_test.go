@@ -0,0 +1,135 @@
+package manifests
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"klim/pkg/types"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func mi(v float64) types.ResourceQuantity {
+	return types.ResourceQuantity{Value: v, Unit: "Mi"}
+}
+
+func TestHelmReleaseUpdaterUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		recs    []types.Recommendation
+		wantErr bool
+	}{
+		{
+			name: "memory",
+			file: "memory",
+			recs: []types.Recommendation{
+				{Container: "app", RecommendedMemory: mi(300), RecommendedRequest: mi(256)},
+				{Container: "sidecar", RecommendedMemory: mi(32)},
+			},
+		},
+		{
+			name: "ephemeral",
+			file: "ephemeral",
+			recs: []types.Recommendation{
+				{
+					Container:                   "app",
+					RecommendedMemory:           mi(384),
+					RecommendedEphemeral:        mi(1536),
+					RecommendedEphemeralRequest: mi(1536),
+					EphemeralRequestLowered:     true,
+					RecommendedEmptyDirs:        map[string]types.ResourceQuantity{"tmp": mi(512), "cache": mi(2048), "scratch": mi(128)},
+				},
+			},
+		},
+		{
+			name: "runtime-env",
+			file: "runtime-env",
+			recs: []types.Recommendation{
+				{Container: "app", RecommendedMemory: mi(2048), ProposedEnv: map[string]string{"JAVA_OPTS": "-Xms256m -Xmx1638m -XX:+UseG1GC"}},
+				{Container: "exporter", RecommendedMemory: mi(256), ProposedEnv: map[string]string{"GOMEMLIMIT": "230MiB"}},
+			},
+		},
+		{
+			name: "runtime-env with 4-space indent",
+			file: "runtime-env-indent4",
+			recs: []types.Recommendation{
+				{Container: "app", RecommendedMemory: mi(2048), ProposedEnv: map[string]string{"JAVA_OPTS": "-Xms256m -Xmx1638m -XX:+UseG1GC"}},
+			},
+		},
+		{
+			name:    "no matching container",
+			file:    "memory",
+			recs:    []types.Recommendation{{Container: "missing", RecommendedMemory: mi(64)}},
+			wantErr: true,
+		},
+	}
+
+	updater := NewHelmReleaseUpdater()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := filepath.Join("testdata", tt.file+".yaml")
+			golden := filepath.Join("testdata", tt.file+".golden")
+
+			got, err := updater.Update(input, tt.recs)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Update() error: %v", err)
+			}
+
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file (run with -update to create): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("Update() mismatch with %s\n%s", golden, GenerateDiff(golden, string(want), got))
+			}
+		})
+	}
+}
+
+func TestGenerateDiff(t *testing.T) {
+	original := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl"
+	updated := "a\nb\nc\nd\nE\ne\nf\ng\nh\ni\nj\nk\nL"
+
+	got := GenerateDiff("values.yaml", original, updated)
+
+	// The inserted line doesn't show the following lines as changed
+	want := strings.Join([]string{
+		"--- values.yaml",
+		"+++ values.yaml (updated)",
+		"",
+		"  b",
+		"  c",
+		"  d",
+		"+ E",
+		"  e",
+		"  f",
+		"  g",
+		"",
+		"  i",
+		"  j",
+		"  k",
+		"- l",
+		"+ L",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("GenerateDiff() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/olekukonko/tablewriter"
@@ -43,6 +44,8 @@ func (f *Formatter) Output(recs []types.Recommendation) error {
 		return f.formatCSV(recs)
 	case "html":
 		output, err = f.formatHTML(recs)
+	case "markdown", "md":
+		output, err = f.formatMarkdown(recs)
 	case "table":
 		output, err = f.formatTable(recs)
 	default:
@@ -100,6 +103,9 @@ func (f *Formatter) formatCSV(recs []types.Recommendation) error {
 		"Current Limit",
 		"Recommended Limit",
 		"Change %",
+		"Current Ephemeral Limit",
+		"Recommended Ephemeral Limit",
+		"Ephemeral Change %",
 	}
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
@@ -115,6 +121,9 @@ func (f *Formatter) formatCSV(recs []types.Recommendation) error {
 			recommendations.FormatResourceQuantity(rec.CurrentMemory),
 			recommendations.FormatResourceQuantity(rec.RecommendedMemory),
 			formatChangeString(rec.MemoryChange, rec.CurrentMemory.Unit != ""),
+			recommendations.FormatResourceQuantity(rec.CurrentEphemeral),
+			recommendations.FormatResourceQuantity(rec.RecommendedEphemeral),
+			formatChangeString(rec.EphemeralChange, rec.CurrentEphemeral.Unit != "" && rec.RecommendedEphemeral.Unit != ""),
 		}
 		if err := writer.Write(row); err != nil {
 			return fmt.Errorf("failed to write CSV row: %w", err)
@@ -138,6 +147,8 @@ func (f *Formatter) formatTable(recs []types.Recommendation) (string, error) {
 		"Current Limit",
 		"Rec. Limit",
 		"Δ%",
+		"Eph. Limit",
+		"Rec. Eph.",
 	)
 
 	for _, rec := range recs {
@@ -151,12 +162,31 @@ func (f *Formatter) formatTable(recs []types.Recommendation) (string, error) {
 			recommendations.FormatResourceQuantity(rec.CurrentMemory),
 			recommendations.FormatResourceQuantity(rec.RecommendedMemory),
 			colorChange(rec.MemoryChange, rec.Severity, rec.CurrentMemory),
+			recommendations.FormatResourceQuantity(rec.CurrentEphemeral),
+			recommendations.FormatResourceQuantity(rec.RecommendedEphemeral),
 		})
 	}
 
 	if err := table.Render(); err != nil {
 		return "", fmt.Errorf("failed to render table: %w", err)
 	}
+
+	// List runtime conflicts below the table
+	for _, rec := range recs {
+		for _, warning := range rec.RuntimeWarnings {
+			builder.WriteString(fmt.Sprintf("\033[33m⚠ %s/%s/%s (%s): %s\033[0m\n",
+				rec.Namespace, rec.WorkloadName, rec.Container, rec.Runtime, warning))
+		}
+	}
+
+	// List per-node and per-replica spreads below the table
+	for _, rec := range recs {
+		if spread := recommendations.FormatSpread(rec.Spread); spread != "" {
+			builder.WriteString(fmt.Sprintf("↕ %s/%s/%s: %s\n",
+				rec.Namespace, rec.WorkloadName, rec.Container, spread))
+		}
+	}
+
 	return builder.String(), nil
 }
 
@@ -167,6 +197,7 @@ func (f *Formatter) formatHTML(recs []types.Recommendation) (string, error) {
 	builder.WriteString(`<!DOCTYPE html>
 <html>
 <head>
+    <meta charset="utf-8">
     <title>Klim Resource Recommendations</title>
     <style>
         body { font-family: Arial, sans-serif; margin: 20px; }
@@ -177,6 +208,7 @@ func (f *Formatter) formatHTML(recs []types.Recommendation) (string, error) {
         .critical { color: red; font-weight: bold; }
         .warning { color: orange; font-weight: bold; }
         .info { color: green; }
+        .sparkline { font-family: monospace; white-space: nowrap; }
     </style>
 </head>
 <body>
@@ -187,9 +219,12 @@ func (f *Formatter) formatHTML(recs []types.Recommendation) (string, error) {
             <th>Workload</th>
             <th>Kind</th>
             <th>Container</th>
+            <th>History</th>
             <th>Current Limit</th>
             <th>Recommended Limit</th>
             <th>Change %</th>
+            <th>Current Ephemeral Limit</th>
+            <th>Recommended Ephemeral Limit</th>
         </tr>
 `)
 
@@ -199,19 +234,25 @@ func (f *Formatter) formatHTML(recs []types.Recommendation) (string, error) {
             <td>%s</td>
             <td>%s</td>
             <td>%s</td>
+            <td class="sparkline">%s</td>
             <td>%s</td>
             <td>%s</td>
             <td class="%s">%s</td>
+            <td>%s</td>
+            <td>%s</td>
         </tr>
 `,
 			rec.Namespace,
 			rec.WorkloadName,
 			rec.WorkloadKind,
 			rec.Container,
+			graph.GenerateSparkline(rec.MemoryHistory, 24),
 			recommendations.FormatResourceQuantity(rec.CurrentMemory),
 			recommendations.FormatResourceQuantity(rec.RecommendedMemory),
 			rec.Severity,
 			formatChangeString(rec.MemoryChange, rec.CurrentMemory.Unit != ""),
+			recommendations.FormatResourceQuantity(rec.CurrentEphemeral),
+			recommendations.FormatResourceQuantity(rec.RecommendedEphemeral),
 		))
 	}
 
@@ -222,6 +263,148 @@ func (f *Formatter) formatHTML(recs []types.Recommendation) (string, error) {
 	return builder.String(), nil
 }
 
+// formatMarkdown formats recommendations as GitHub/Gitea flavoured markdown for PR comments.
+func (f *Formatter) formatMarkdown(recs []types.Recommendation) (string, error) {
+	var builder strings.Builder
+
+	// Group by namespace and count severities for the summary header
+	byNamespace := make(map[string][]types.Recommendation)
+	severityCounts := make(map[string]int)
+	for _, rec := range recs {
+		byNamespace[rec.Namespace] = append(byNamespace[rec.Namespace], rec)
+		severityCounts[rec.Severity]++
+	}
+
+	namespaces := make([]string, 0, len(byNamespace))
+	for ns := range byNamespace {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	builder.WriteString("## Klim Resource Recommendations\n\n")
+	builder.WriteString(fmt.Sprintf("**%d** recommendations across **%d** namespaces: ", len(recs), len(namespaces)))
+	builder.WriteString(fmt.Sprintf("%s %d critical · %s %d warning · %s %d info\n\n",
+		severityEmoji("critical"), severityCounts["critical"],
+		severityEmoji("warning"), severityCounts["warning"],
+		severityEmoji("info"), severityCounts["info"],
+	))
+
+	for _, ns := range namespaces {
+		nsRecs := byNamespace[ns]
+
+		// Sort by severity, then by largest absolute change
+		sort.SliceStable(nsRecs, func(i, j int) bool {
+			if severityRank(nsRecs[i].Severity) != severityRank(nsRecs[j].Severity) {
+				return severityRank(nsRecs[i].Severity) < severityRank(nsRecs[j].Severity)
+			}
+			return abs(nsRecs[i].MemoryChange) > abs(nsRecs[j].MemoryChange)
+		})
+
+		// Expand namespaces that contain critical findings
+		open := ""
+		if nsRecs[0].Severity == "critical" {
+			open = " open"
+		}
+
+		builder.WriteString(fmt.Sprintf("<details%s>\n<summary>%s <b>%s</b> (%d)</summary>\n\n",
+			open, severityEmoji(nsRecs[0].Severity), escapeMarkdown(ns), len(nsRecs)))
+		builder.WriteString("| | Workload | Container | History | Current Limit | Rec. Limit | Δ% |\n")
+		builder.WriteString("|---|---|---|---|---|---|---|\n")
+
+		for _, rec := range nsRecs {
+			builder.WriteString(fmt.Sprintf("| %s | %s | %s | `%s` | %s | %s | %s |\n",
+				severityEmoji(rec.Severity),
+				escapeMarkdown(fmt.Sprintf("%s/%s", rec.WorkloadKind, rec.WorkloadName)),
+				escapeMarkdown(rec.Container),
+				graph.GenerateSparkline(rec.MemoryHistory, 12),
+				recommendations.FormatResourceQuantity(rec.CurrentMemory),
+				recommendations.FormatResourceQuantity(rec.RecommendedMemory),
+				markdownChange(rec.MemoryChange, rec.CurrentMemory),
+			))
+		}
+
+		// Runtime conflicts for this namespace
+		warningsHeader := false
+		for _, rec := range nsRecs {
+			for _, warning := range rec.RuntimeWarnings {
+				if !warningsHeader {
+					builder.WriteString("\n**Runtime warnings**\n\n")
+					warningsHeader = true
+				}
+				builder.WriteString(fmt.Sprintf("- ⚠️ `%s/%s` (%s): %s\n",
+					rec.WorkloadName, rec.Container, rec.Runtime, escapeMarkdown(warning)))
+			}
+		}
+
+		// Usage spread across nodes or replica counts for this namespace
+		spreadHeader := false
+		for _, rec := range nsRecs {
+			spread := recommendations.FormatSpread(rec.Spread)
+			if spread == "" {
+				continue
+			}
+			if !spreadHeader {
+				builder.WriteString("\n**Usage spread**\n\n")
+				spreadHeader = true
+			}
+			builder.WriteString(fmt.Sprintf("- `%s/%s`: %s\n", rec.WorkloadName, rec.Container, escapeMarkdown(spread)))
+		}
+
+		builder.WriteString("\n</details>\n\n")
+	}
+
+	return strings.TrimRight(builder.String(), "\n"), nil
+}
+
+// severityEmoji maps a severity to an emoji for markdown output.
+func severityEmoji(severity string) string {
+	switch severity {
+	case "critical":
+		return "🔴"
+	case "warning":
+		return "🟡"
+	case "info":
+		return "🟢"
+	default:
+		return "⚪"
+	}
+}
+
+// severityRank orders severities from most to least severe.
+func severityRank(severity string) int {
+	switch severity {
+	case "critical":
+		return 0
+	case "warning":
+		return 1
+	case "info":
+		return 2
+	default:
+		return 3
+	}
+}
+
+// markdownChange formats a percentage change for markdown, returning "N/A" if no current limit set.
+func markdownChange(change float64, currentMemory types.ResourceQuantity) string {
+	if currentMemory.Unit == "" {
+		return "N/A"
+	}
+	return formatChange(change)
+}
+
+// escapeMarkdown escapes characters that would break a markdown table cell.
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer("|", "\\|", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// abs returns the absolute value of a float64.
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
 // formatChangeString formats a percentage change, returning "N/A" if no current memory set.
 func formatChangeString(change float64, hasCurrentMemory bool) string {
 	if !hasCurrentMemory {
@@ -0,0 +1,58 @@
+package output
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"klim/pkg/types"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func mi(v float64) types.ResourceQuantity {
+	return types.ResourceQuantity{Value: v, Unit: "Mi"}
+}
+
+func TestFormatMarkdown(t *testing.T) {
+	recs := []types.Recommendation{
+		{
+			Namespace: "media", WorkloadKind: "Deployment", WorkloadName: "plex", Container: "plex",
+			CurrentMemory: mi(1024), RecommendedMemory: mi(1536), MemoryChange: 50, Severity: "warning",
+		},
+		{
+			Namespace: "apps", WorkloadKind: "Deployment", WorkloadName: "echo", Container: "app",
+			CurrentMemory: mi(512), RecommendedMemory: mi(480), MemoryChange: -6.25, Severity: "info",
+		},
+		{
+			Namespace: "apps", WorkloadKind: "StatefulSet", WorkloadName: "web|api", Container: "side|car",
+			RecommendedMemory: mi(64), Severity: "info",
+		},
+		{
+			Namespace: "apps", WorkloadKind: "Deployment", WorkloadName: "search", Container: "app",
+			CurrentMemory: mi(256), RecommendedMemory: mi(768), MemoryChange: 200, Severity: "critical",
+			Runtime: "jvm", RuntimeWarnings: []string{"-Xmx1g exceeds the recommended limit | heap > limit"},
+		},
+	}
+
+	got, err := NewFormatter("markdown", "").formatMarkdown(recs)
+	if err != nil {
+		t.Fatalf("formatMarkdown() error: %v", err)
+	}
+
+	golden := filepath.Join("testdata", "markdown.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("formatMarkdown() mismatch with %s\ngot:\n%s\nwant:\n%s", golden, got, want)
+	}
+}
@@ -20,6 +20,7 @@ type Tracker struct {
 	frameIndex    int
 	lastLineCount int
 	done          chan struct{}
+	stopOnce      sync.Once
 	verbose       bool
 	maxConcurrent int
 }
@@ -87,14 +88,16 @@ func (t *Tracker) FinishProcessing(podName string) {
 	t.completed++
 }
 
-// Stop stops the progress display.
+// Stop stops the progress display. It is safe to call more than once.
 func (t *Tracker) Stop() {
 	if t.verbose {
 		return
 	}
 
-	close(t.done)
-	time.Sleep(150 * time.Millisecond) // Let it finish rendering
+	t.stopOnce.Do(func() {
+		close(t.done)
+		time.Sleep(150 * time.Millisecond) // Let it finish rendering
+	})
 }
 
 // render draws the current state.
@@ -2,15 +2,15 @@ package prometheus
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	"klim/pkg/types"
@@ -50,8 +50,13 @@ func NewClient(endpoint string, tlsConfig *rest.Config) (*Client, error) {
 	}, nil
 }
 
+// SetTimeout sets the timeout applied to each individual query.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
 // QueryMemoryUsage queries memory usage metrics from Prometheus.
-func (c *Client) QueryMemoryUsage(namespace, pod, container string, duration time.Duration) ([]types.MetricPoint, error) {
+func (c *Client) QueryMemoryUsage(ctx context.Context, namespace, pod, container string, duration time.Duration) ([]types.MetricPoint, error) {
 	// Use regex to match pod name prefix (handles pod restarts)
 	// e.g., "echo-server-abc123" -> "echo-server-.*"
 	// This captures historical data from previous pods in the same workload
@@ -78,12 +83,12 @@ func (c *Client) QueryMemoryUsage(namespace, pod, container string, duration tim
 		namespace, podPrefix, container,
 	)
 
-	return c.queryRange(query, duration)
+	return c.queryRange(ctx, query, duration)
 }
 
 // QueryMemoryUsageByWorkload queries by workload name using kube_pod_owner join.
 // This is the most reliable method across pod restarts.
-func (c *Client) QueryMemoryUsageByWorkload(namespace, workloadName, container string, duration time.Duration) ([]types.MetricPoint, error) {
+func (c *Client) QueryMemoryUsageByWorkload(ctx context.Context, namespace, workloadName, container string, duration time.Duration) ([]types.MetricPoint, error) {
 	// Use kube_pod_owner to join container metrics with owner information
 	// This properly handles:
 	// - Deployments: owner_name will be ReplicaSet like "app-abc123", regex strips to "app"
@@ -120,12 +125,12 @@ func (c *Client) QueryMemoryUsageByWorkload(namespace, workloadName, container s
 		fmt.Printf("DEBUG: Workload query: %s\n", query)
 	}
 
-	return c.queryRange(query, duration)
+	return c.queryRange(ctx, query, duration)
 }
 
 // queryRange executes a range query and returns metric points.
-func (c *Client) queryRange(query string, duration time.Duration) ([]types.MetricPoint, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+func (c *Client) queryRange(ctx context.Context, query string, duration time.Duration) ([]types.MetricPoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
 	end := time.Now()
@@ -187,57 +192,43 @@ func (c *Client) queryRange(query string, duration time.Duration) ([]types.Metri
 
 // BulkQueryMemoryUsage fetches all memory data for all workloads in specified namespaces.
 // Returns a map of "namespace/workload/container" -> []types.MetricPoint for fast lookup.
-func (c *Client) BulkQueryMemoryUsage(namespaces []string, duration time.Duration) (map[string]map[string][]types.MetricPoint, error) {
-	// Build namespace filter
-	namespaceFilter := ""
-	if len(namespaces) > 0 {
-		namespaceFilter = fmt.Sprintf(`namespace=~"%s"`, joinNamespaces(namespaces))
-	} else {
-		namespaceFilter = `namespace=~".+"`
+func (c *Client) BulkQueryMemoryUsage(ctx context.Context, namespaces []string, duration time.Duration) (map[string]map[string][]types.MetricPoint, error) {
+	return c.bulkQueryByWorkload(ctx, "container_memory_working_set_bytes", namespaces, duration)
+}
+
+// BulkQueryEphemeralStorageUsage fetches ephemeral-storage usage (writable layer and logs)
+// for all workloads in specified namespaces, keyed the same way as BulkQueryMemoryUsage.
+func (c *Client) BulkQueryEphemeralStorageUsage(ctx context.Context, namespaces []string, duration time.Duration) (map[string]map[string][]types.MetricPoint, error) {
+	return c.bulkQueryByWorkload(ctx, "container_fs_usage_bytes", namespaces, duration)
+}
+
+// bulkQueryByWorkload runs a range query for a cAdvisor container metric, aggregated
+// by namespace, owning workload and container.
+func (c *Client) bulkQueryByWorkload(ctx context.Context, metric string, namespaces []string, duration time.Duration) (map[string]map[string][]types.MetricPoint, error) {
+	results, err := c.rangeByWorkload(ctx, workloadUsageExpr(metric, namespaces), time.Now(), duration)
+	if err != nil {
+		return nil, err
 	}
 
-	// Query all workload memory usage at once
-	// Use max by to deduplicate kube_pod_owner in case of stale metrics
-	query := fmt.Sprintf(
-		`max by (namespace, owner_name, container) (
-			container_memory_working_set_bytes{
-				job="kubelet",
-				metrics_path="/metrics/cadvisor",
-				%s,
-				image!="",
-				container!=""
-			}
-			* on(namespace, pod) group_left(owner_name)
-			(
-				label_replace(
-					max by (namespace, pod, owner_name, owner_kind) (
-						kube_pod_owner{
-							%s,
-							owner_kind=~"StatefulSet|DaemonSet|ReplicaSet"
-						}
-					),
-					"owner_name", "$1", "owner_name", "^(.*)-[a-z0-9]+$"
-				)
-			)
-		)`,
-		namespaceFilter, namespaceFilter,
-	)
+	if os.Getenv("KLIM_DEBUG_QUERIES") == "true" {
+		fmt.Printf("DEBUG: Bulk query for %s returned data for %d workloads\n", metric, len(results))
+	}
 
+	return results, nil
+}
+
+// rangeByWorkload runs a range query ending at end whose series carry namespace, owner_name and
+// container labels and returns them as namespace/workload -> container -> points.
+func (c *Client) rangeByWorkload(ctx context.Context, query string, end time.Time, duration time.Duration) (map[string]map[string][]types.MetricPoint, error) {
 	if os.Getenv("KLIM_DEBUG_QUERIES") == "true" {
 		fmt.Printf("DEBUG: Bulk query: %s\n", query)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
-	end := time.Now()
 	start := end.Add(-duration)
-
-	// Use larger step for bulk queries
-	step := duration / 300
-	if step < time.Minute {
-		step = time.Minute
-	}
+	step := bulkStep(duration)
 
 	result, warnings, err := c.api.QueryRange(ctx, query, v1.Range{
 		Start: start,
@@ -289,10 +280,447 @@ func (c *Client) BulkQueryMemoryUsage(namespaces []string, duration time.Duratio
 		results[key][container] = points
 	}
 
+	return results, nil
+}
+
+// BulkQueryMemorySummary returns peak and percentile memory usage per workload container,
+// aggregated by Prometheus so no time series need to be transferred.
+func (c *Client) BulkQueryMemorySummary(ctx context.Context, namespaces []string, duration time.Duration) (map[string]map[string]types.UsageSummary, error) {
+	return c.bulkSummaryByWorkload(ctx, "container_memory_working_set_bytes", namespaces, duration)
+}
+
+// BulkQueryEphemeralStorageSummary returns peak and percentile ephemeral-storage usage per workload container.
+func (c *Client) BulkQueryEphemeralStorageSummary(ctx context.Context, namespaces []string, duration time.Duration) (map[string]map[string]types.UsageSummary, error) {
+	return c.bulkSummaryByWorkload(ctx, "container_fs_usage_bytes", namespaces, duration)
+}
+
+// bulkSummaryByWorkload runs instant subqueries over the history window for each statistic.
+func (c *Client) bulkSummaryByWorkload(ctx context.Context, metric string, namespaces []string, duration time.Duration) (map[string]map[string]types.UsageSummary, error) {
+	expr := workloadUsageExpr(metric, namespaces)
+	window := fmt.Sprintf("[%s:%s]", model.Duration(duration), model.Duration(bulkStep(duration)))
+
+	aggregations := []struct {
+		query string
+		set   func(*types.UsageSummary, float64)
+	}{
+		{fmt.Sprintf("max_over_time((%s)%s)", expr, window), func(s *types.UsageSummary, v float64) { s.Peak = v }},
+		{fmt.Sprintf("quantile_over_time(0.5, (%s)%s)", expr, window), func(s *types.UsageSummary, v float64) { s.P50 = v }},
+		{fmt.Sprintf("quantile_over_time(0.95, (%s)%s)", expr, window), func(s *types.UsageSummary, v float64) { s.P95 = v }},
+		{fmt.Sprintf("quantile_over_time(0.99, (%s)%s)", expr, window), func(s *types.UsageSummary, v float64) { s.P99 = v }},
+	}
+
+	results := make(map[string]map[string]types.UsageSummary)
+	now := time.Now()
+
+	for _, agg := range aggregations {
+		if os.Getenv("KLIM_DEBUG_QUERIES") == "true" {
+			fmt.Printf("DEBUG: Summary query: %s\n", agg.query)
+		}
+
+		queryCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		result, warnings, err := c.api.Query(queryCtx, agg.query, now)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("prometheus summary query failed: %w", err)
+		}
+
+		for _, w := range warnings {
+			fmt.Printf("Warning: %s\n", w)
+		}
+
+		vector, ok := result.(model.Vector)
+		if !ok {
+			return nil, fmt.Errorf("unexpected result type: %T", result)
+		}
+
+		for _, sample := range vector {
+			namespace := string(sample.Metric["namespace"])
+			ownerName := string(sample.Metric["owner_name"])
+			container := string(sample.Metric["container"])
+
+			if namespace == "" || ownerName == "" || container == "" {
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%s", namespace, ownerName)
+			if results[key] == nil {
+				results[key] = make(map[string]types.UsageSummary)
+			}
+
+			summary := results[key][container]
+			agg.set(&summary, float64(sample.Value))
+			results[key][container] = summary
+		}
+	}
+
+	if os.Getenv("KLIM_DEBUG_QUERIES") == "true" {
+		fmt.Printf("DEBUG: Summary query for %s returned data for %d workloads\n", metric, len(results))
+	}
+
+	return results, nil
+}
+
+// workloadUsageExpr builds a PromQL expression for a cAdvisor container metric joined with
+// kube_pod_owner and aggregated by namespace, owning workload and container.
+func workloadUsageExpr(metric string, namespaces []string) string {
+	return workloadAggregateExpr("max", metric, namespaces, nil)
+}
+
+// workloadAggregateExpr is workloadUsageExpr with a configurable aggregation across replicas,
+// e.g. "count" to get the number of running replicas, restricted to the named workloads
+// unless workloads is empty.
+func workloadAggregateExpr(aggregation, metric string, namespaces, workloads []string) string {
+	namespaceFilter := namespaceMatcher(namespaces)
+	ownerFilter := ""
+	if len(workloads) > 0 {
+		// ReplicaSets carry the Deployment name with a hash suffix
+		ownerFilter = fmt.Sprintf(`,
+							owner_name=~"(%s)(-[a-z0-9]+)?"`, strings.Join(workloads, "|"))
+	}
+
+	// Use max by to deduplicate kube_pod_owner in case of stale metrics
+	return fmt.Sprintf(
+		`%s by (namespace, owner_name, container) (
+			%s{
+				job="kubelet",
+				metrics_path="/metrics/cadvisor",
+				%s,
+				image!="",
+				container!=""
+			}
+			* on(namespace, pod) group_left(owner_name)
+			(
+				label_replace(
+					max by (namespace, pod, owner_name, owner_kind) (
+						kube_pod_owner{
+							%s,
+							owner_kind=~"StatefulSet|DaemonSet|ReplicaSet"%s
+						}
+					),
+					"owner_name", "$1", "owner_name", "^(.*)-[a-z0-9]+$"
+				)
+			)
+		)`,
+		aggregation, metric, namespaceFilter, namespaceFilter, ownerFilter,
+	)
+}
+
+// namespaceMatcher returns a PromQL label matcher for namespaces, matching all if empty.
+func namespaceMatcher(namespaces []string) string {
+	return labelMatcher("namespace", namespaces)
+}
+
+// labelMatcher returns a PromQL matcher of label against namespaces, matching all if empty.
+func labelMatcher(label string, namespaces []string) string {
+	if len(namespaces) > 0 {
+		return fmt.Sprintf(`%s=~"%s"`, label, joinNamespaces(namespaces))
+	}
+	return label + `=~".+"`
+}
+
+// BulkQueryMemoryByNode returns the peak memory usage of DaemonSet containers on each node,
+// keyed by namespace/daemonset -> container -> node.
+func (c *Client) BulkQueryMemoryByNode(ctx context.Context, namespaces []string, duration time.Duration) (map[string]map[string]map[string]float64, error) {
+	namespaceFilter := namespaceMatcher(namespaces)
+	window := fmt.Sprintf("[%s:%s]", model.Duration(duration), model.Duration(bulkStep(duration)))
+
+	query := fmt.Sprintf(
+		`max_over_time((max by (namespace, owner_name, container, node) (
+			container_memory_working_set_bytes{
+				job="kubelet",
+				metrics_path="/metrics/cadvisor",
+				%s,
+				image!="",
+				container!=""
+			}
+			* on(namespace, pod) group_left(owner_name)
+			max by (namespace, pod, owner_name) (kube_pod_owner{%s, owner_kind="DaemonSet"})
+			* on(namespace, pod) group_left(node)
+			max by (namespace, pod, node) (kube_pod_info{%s})
+		))%s)`,
+		namespaceFilter, namespaceFilter, namespaceFilter, window,
+	)
+
+	if os.Getenv("KLIM_DEBUG_QUERIES") == "true" {
+		fmt.Printf("DEBUG: Node breakdown query: %s\n", query)
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	result, warnings, err := c.api.Query(queryCtx, query, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("prometheus node breakdown query failed: %w", err)
+	}
+
+	for _, w := range warnings {
+		fmt.Printf("Warning: %s\n", w)
+	}
+
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type: %T", result)
+	}
+
+	results := make(map[string]map[string]map[string]float64)
+	for _, sample := range vector {
+		namespace := string(sample.Metric["namespace"])
+		ownerName := string(sample.Metric["owner_name"])
+		container := string(sample.Metric["container"])
+		node := string(sample.Metric["node"])
+
+		if namespace == "" || ownerName == "" || container == "" || node == "" {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s", namespace, ownerName)
+		if results[key] == nil {
+			results[key] = make(map[string]map[string]float64)
+		}
+		if results[key][container] == nil {
+			results[key][container] = make(map[string]float64)
+		}
+		results[key][container][node] = float64(sample.Value)
+	}
+
+	return results, nil
+}
+
+// BulkQueryMemoryByReplicas returns the peak per-replica memory usage of the named workloads'
+// containers for each number of concurrently running replicas seen over the window, keyed by
+// namespace/workload -> container -> replica count. Both range queries are restricted to the
+// workloads, which are usually the few targets of horizontal pod autoscalers.
+func (c *Client) BulkQueryMemoryByReplicas(ctx context.Context, namespaces, workloads []string, duration time.Duration) (map[string]map[string]map[int]float64, error) {
+	metric := "container_memory_working_set_bytes"
+
+	// Both queries must share the same range so their samples line up
+	end := time.Now()
+	usage, err := c.rangeByWorkload(ctx, workloadAggregateExpr("max", metric, namespaces, workloads), end, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas, err := c.rangeByWorkload(ctx, workloadAggregateExpr("count", metric, namespaces, workloads), end, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]map[string]map[int]float64)
+	for key, containers := range usage {
+		for container, points := range containers {
+			counts := make(map[time.Time]int, len(replicas[key][container]))
+			for _, point := range replicas[key][container] {
+				counts[point.Timestamp] = int(point.Value)
+			}
+
+			for _, point := range points {
+				count := counts[point.Timestamp]
+				if count <= 0 {
+					continue
+				}
+				if results[key] == nil {
+					results[key] = make(map[string]map[int]float64)
+				}
+				if results[key][container] == nil {
+					results[key][container] = make(map[int]float64)
+				}
+				if point.Value > results[key][container][count] {
+					results[key][container][count] = point.Value
+				}
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// emptyDirUsageMetric is the per-volume usage exported by k8s-ephemeral-storage-metrics
+// (https://github.com/jmcgrath207/k8s-ephemeral-storage-metrics).
+const emptyDirUsageMetric = "ephemeral_storage_container_volume_usage"
+
+// ErrEmptyDirMetricsMissing is returned by BulkQueryEmptyDirUsage when Prometheus has no
+// emptyDir usage at all because the exporter is not installed.
+var ErrEmptyDirMetricsMissing = errors.New(emptyDirUsageMetric + " not found, emptyDir usage requires the k8s-ephemeral-storage-metrics exporter")
+
+// BulkQueryEmptyDirUsage returns the peak usage of each emptyDir volume of the workloads in the
+// specified namespaces, keyed by namespace/workload -> volume name, in bytes.
+//
+// The kubelet only exports volume stats for persistent volume claims and cAdvisor's
+// container_fs_usage_bytes only covers the writable layer, so per-volume usage comes from the
+// kubelet summary API as exported by k8s-ephemeral-storage-metrics. Its labels are renamed to
+// namespace and pod so the series can be joined with kube_pod_owner.
+func (c *Client) BulkQueryEmptyDirUsage(ctx context.Context, namespaces []string, duration time.Duration) (map[string]map[string]float64, error) {
+	namespaceFilter := namespaceMatcher(namespaces)
+	window := fmt.Sprintf("[%s:%s]", model.Duration(duration), model.Duration(bulkStep(duration)))
+
+	// Only ReplicaSet names carry a hash to strip; StatefulSet and DaemonSet names are kept
+	// whole as they may contain dashes themselves
+	query := fmt.Sprintf(
+		`max_over_time((max by (namespace, owner_name, volume_name) (
+			label_replace(
+				label_replace(
+					%s{%s},
+					"namespace", "$1", "pod_namespace", "(.+)"
+				),
+				"pod", "$1", "pod_name", "(.+)"
+			)
+			* on(namespace, pod) group_left(owner_name)
+			(
+				label_replace(
+					max by (namespace, pod, owner_name) (
+						kube_pod_owner{%s, owner_kind="ReplicaSet"}
+					),
+					"owner_name", "$1", "owner_name", "^(.*)-[a-z0-9]+$"
+				)
+				or
+				max by (namespace, pod, owner_name) (
+					kube_pod_owner{%s, owner_kind=~"StatefulSet|DaemonSet"}
+				)
+			)
+		))%s)`,
+		emptyDirUsageMetric, labelMatcher("pod_namespace", namespaces), namespaceFilter, namespaceFilter, window,
+	)
+
 	if os.Getenv("KLIM_DEBUG_QUERIES") == "true" {
-		fmt.Printf("DEBUG: Bulk query returned data for %d workloads\n", len(results))
+		fmt.Printf("DEBUG: emptyDir query: %s\n", query)
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	result, warnings, err := c.api.Query(queryCtx, query, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("prometheus emptyDir query failed: %w", err)
+	}
+
+	for _, w := range warnings {
+		fmt.Printf("Warning: %s\n", w)
+	}
+
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type: %T", result)
+	}
+
+	results := make(map[string]map[string]float64)
+	for _, sample := range vector {
+		namespace := string(sample.Metric["namespace"])
+		ownerName := string(sample.Metric["owner_name"])
+		volume := string(sample.Metric["volume_name"])
+
+		if namespace == "" || ownerName == "" || volume == "" {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s", namespace, ownerName)
+		if results[key] == nil {
+			results[key] = make(map[string]float64)
+		}
+		results[key][volume] = float64(sample.Value)
+	}
+
+	if len(results) == 0 {
+		present, err := c.hasSeries(ctx, emptyDirUsageMetric)
+		if err != nil {
+			return nil, err
+		}
+		if !present {
+			return nil, ErrEmptyDirMetricsMissing
+		}
+	}
+
+	return results, nil
+}
+
+// hasSeries reports whether Prometheus currently has any series of metric.
+func (c *Client) hasSeries(ctx context.Context, metric string) (bool, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	result, _, err := c.api.Query(queryCtx, fmt.Sprintf("count(%s)", metric), time.Now())
+	if err != nil {
+		return false, fmt.Errorf("prometheus query for %s failed: %w", metric, err)
+	}
+	vector, ok := result.(model.Vector)
+	return ok && len(vector) > 0, nil
+}
+
+// bulkStep returns the resolution used for bulk queries over duration.
+func bulkStep(duration time.Duration) time.Duration {
+	// Use larger step for bulk queries
+	step := duration / 300
+	if step < time.Minute {
+		step = time.Minute
+	}
+	return step
+}
+
+// QueryContainerUsage returns the current working set, request and limit of every container
+// in the given namespaces using instant queries.
+func (c *Client) QueryContainerUsage(ctx context.Context, namespaces []string) ([]types.ContainerUsage, error) {
+	namespaceFilter := fmt.Sprintf(`namespace=~"%s"`, joinNamespaces(namespaces))
+
+	queries := []struct {
+		query string
+		set   func(*types.ContainerUsage, float64)
+	}{
+		{
+			fmt.Sprintf(`max by (namespace, pod, container) (container_memory_working_set_bytes{job="kubelet", metrics_path="/metrics/cadvisor", image!="", container!="", %s})`, namespaceFilter),
+			func(u *types.ContainerUsage, v float64) { u.WorkingSet = v },
+		},
+		{
+			fmt.Sprintf(`max by (namespace, pod, container) (kube_pod_container_resource_requests{resource="memory", %s})`, namespaceFilter),
+			func(u *types.ContainerUsage, v float64) { u.Request = v },
+		},
+		{
+			fmt.Sprintf(`max by (namespace, pod, container) (kube_pod_container_resource_limits{resource="memory", %s})`, namespaceFilter),
+			func(u *types.ContainerUsage, v float64) { u.Limit = v },
+		},
+	}
+
+	usage := make(map[string]*types.ContainerUsage)
+	now := time.Now()
+
+	for i, q := range queries {
+		queryCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		result, warnings, err := c.api.Query(queryCtx, q.query, now)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("prometheus query failed: %w", err)
+		}
+
+		for _, w := range warnings {
+			fmt.Printf("Warning: %s\n", w)
+		}
+
+		vector, ok := result.(model.Vector)
+		if !ok {
+			return nil, fmt.Errorf("unexpected result type: %T", result)
+		}
+
+		for _, sample := range vector {
+			key := fmt.Sprintf("%s/%s/%s", sample.Metric["namespace"], sample.Metric["pod"], sample.Metric["container"])
+			entry, exists := usage[key]
+			if !exists {
+				// Requests and limits of containers that are not running are ignored
+				if i > 0 {
+					continue
+				}
+				entry = &types.ContainerUsage{
+					Namespace: string(sample.Metric["namespace"]),
+					Pod:       string(sample.Metric["pod"]),
+					Container: string(sample.Metric["container"]),
+				}
+				usage[key] = entry
+			}
+			q.set(entry, float64(sample.Value))
+		}
 	}
 
+	results := make([]types.ContainerUsage, 0, len(usage))
+	for _, entry := range usage {
+		results = append(results, *entry)
+	}
 	return results, nil
 }
 
@@ -314,50 +742,3 @@ func joinNamespaces(namespaces []string) string {
 	}
 	return result
 }
-
-// DiscoverPrometheus attempts to discover the Prometheus endpoint from the cluster using Kubernetes API.
-func DiscoverPrometheus(k8sClient kubernetes.Interface, config *rest.Config) (string, error) {
-	labelSelectors := []string{
-		"app=kube-prometheus-stack-prometheus",
-		"app=prometheus,component=server",
-		"app=prometheus-server",
-		"app=prometheus-operator-prometheus",
-		"app=rancher-monitoring-prometheus",
-		"app=prometheus-prometheus",
-		"app.kubernetes.io/name=prometheus,app.kubernetes.io/component=server",
-		"app=stack-prometheus",
-	}
-
-	// Detect if running inside cluster
-	_, insideCluster := os.LookupEnv("KUBERNETES_SERVICE_HOST")
-
-	for _, selector := range labelSelectors {
-		services, err := k8sClient.CoreV1().Services("").List(context.TODO(), metav1.ListOptions{
-			LabelSelector: selector,
-		})
-		if err != nil {
-			continue
-		}
-
-		if len(services.Items) == 0 {
-			continue
-		}
-
-		svc := services.Items[0]
-		name := svc.Name
-		namespace := svc.Namespace
-		port := svc.Spec.Ports[0].Port
-
-		if insideCluster {
-			// Inside cluster: use internal DNS
-			url := fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", name, namespace, port)
-			return url, nil
-		} else {
-			// Outside cluster: use Kubernetes API proxy
-			url := fmt.Sprintf("%s/api/v1/namespaces/%s/services/%s:%d/proxy", config.Host, namespace, name, port)
-			return url, nil
-		}
-	}
-
-	return "", fmt.Errorf("failed to discover prometheus endpoint using label selectors")
-}
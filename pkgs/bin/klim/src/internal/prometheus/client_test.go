@@ -0,0 +1,274 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"klim/internal/testutil"
+	"klim/pkg/types"
+)
+
+func TestBulkQueryMemoryUsage(t *testing.T) {
+	stub := testutil.NewPrometheusStub()
+	defer stub.Close()
+
+	stub.AddSeries("container_memory_working_set_bytes",
+		testutil.Series{
+			Labels: map[string]string{"namespace": "default", "owner_name": "echo", "container": "app"},
+			Values: []float64{1, 2, 3},
+		},
+		testutil.Series{
+			Labels: map[string]string{"namespace": "default", "owner_name": "echo", "container": "sidecar"},
+			Values: []float64{4},
+		},
+		testutil.Series{
+			// Missing owner, must be ignored
+			Labels: map[string]string{"namespace": "default", "container": "app"},
+			Values: []float64{5},
+		},
+	)
+
+	client, err := NewClient(stub.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.BulkQueryMemoryUsage(context.Background(), []string{"default"}, time.Hour)
+	if err != nil {
+		t.Fatalf("BulkQueryMemoryUsage() error: %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("got %d workloads, want 1: %v", len(got), got)
+	}
+	if points := got["default/echo"]["app"]; len(points) != 3 || points[2].Value != 3 {
+		t.Errorf("app points = %v, want 3 points ending in 3", points)
+	}
+	if points := got["default/echo"]["sidecar"]; len(points) != 1 || points[0].Value != 4 {
+		t.Errorf("sidecar points = %v, want [4]", points)
+	}
+
+	queries := stub.Queries()
+	if len(queries) != 1 {
+		t.Fatalf("got %d queries, want 1", len(queries))
+	}
+}
+
+func TestBulkQueryMemorySummary(t *testing.T) {
+	stub := testutil.NewPrometheusStub()
+	defer stub.Close()
+
+	labels := map[string]string{"namespace": "default", "owner_name": "echo", "container": "app"}
+	stub.AddSeries("max_over_time(", testutil.Series{Labels: labels, Values: []float64{400}})
+	stub.AddSeries("quantile_over_time(0.5,", testutil.Series{Labels: labels, Values: []float64{100}})
+	stub.AddSeries("quantile_over_time(0.95,", testutil.Series{Labels: labels, Values: []float64{300}})
+	stub.AddSeries("quantile_over_time(0.99,", testutil.Series{Labels: labels, Values: []float64{350}})
+
+	client, err := NewClient(stub.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.BulkQueryMemorySummary(context.Background(), nil, 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("BulkQueryMemorySummary() error: %v", err)
+	}
+
+	want := types.UsageSummary{Peak: 400, P50: 100, P95: 300, P99: 350}
+	if summary := got["default/echo"]["app"]; summary != want {
+		t.Errorf("summary = %+v, want %+v", summary, want)
+	}
+
+	for _, query := range stub.Queries() {
+		if !strings.Contains(query, "[1w:33m36s]") {
+			t.Errorf("query does not use the 7d window with bulk step: %s", query)
+		}
+	}
+}
+
+func TestBulkQueryMemoryByNode(t *testing.T) {
+	stub := testutil.NewPrometheusStub()
+	defer stub.Close()
+
+	stub.AddSeries(`owner_kind="DaemonSet"`,
+		testutil.Series{
+			Labels: map[string]string{"namespace": "monitoring", "owner_name": "node-exporter", "container": "exporter", "node": "big"},
+			Values: []float64{300},
+		},
+		testutil.Series{
+			Labels: map[string]string{"namespace": "monitoring", "owner_name": "node-exporter", "container": "exporter", "node": "small"},
+			Values: []float64{100},
+		},
+		testutil.Series{
+			// Missing node, must be ignored
+			Labels: map[string]string{"namespace": "monitoring", "owner_name": "node-exporter", "container": "exporter"},
+			Values: []float64{500},
+		},
+	)
+
+	client, err := NewClient(stub.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.BulkQueryMemoryByNode(context.Background(), []string{"monitoring"}, time.Hour)
+	if err != nil {
+		t.Fatalf("BulkQueryMemoryByNode() error: %v", err)
+	}
+
+	nodes := got["monitoring/node-exporter"]["exporter"]
+	if len(nodes) != 2 || nodes["big"] != 300 || nodes["small"] != 100 {
+		t.Errorf("node peaks = %v, want map[big:300 small:100]", nodes)
+	}
+}
+
+func TestBulkQueryMemoryByReplicas(t *testing.T) {
+	stub := testutil.NewPrometheusStub()
+	defer stub.Close()
+
+	labels := map[string]string{"namespace": "default", "owner_name": "web", "container": "app"}
+	stub.AddSeries("count by (namespace, owner_name, container)", testutil.Series{Labels: labels, Values: []float64{2, 2, 4, 4, 0}})
+	stub.AddSeries("max by (namespace, owner_name, container)", testutil.Series{Labels: labels, Values: []float64{300, 350, 200, 250, 900}})
+
+	client, err := NewClient(stub.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.BulkQueryMemoryByReplicas(context.Background(), []string{"default"}, []string{"web", "api"}, time.Hour)
+	if err != nil {
+		t.Fatalf("BulkQueryMemoryByReplicas() error: %v", err)
+	}
+
+	// Samples without running replicas are ignored
+	replicas := got["default/web"]["app"]
+	if len(replicas) != 2 || replicas[2] != 350 || replicas[4] != 250 {
+		t.Errorf("replica peaks = %v, want map[2:350 4:250]", replicas)
+	}
+
+	// Only the autoscaled workloads are queried
+	for _, query := range stub.Queries() {
+		if !strings.Contains(query, `owner_name=~"(web|api)(-[a-z0-9]+)?"`) {
+			t.Errorf("query is not restricted to the workloads: %s", query)
+		}
+	}
+}
+
+func TestBulkQueryEmptyDirUsage(t *testing.T) {
+	stub := testutil.NewPrometheusStub()
+	defer stub.Close()
+
+	stub.AddSeries("ephemeral_storage_container_volume_usage",
+		testutil.Series{
+			Labels: map[string]string{"namespace": "default", "owner_name": "builder", "volume_name": "tmp"},
+			Values: []float64{300},
+		},
+		testutil.Series{
+			Labels: map[string]string{"namespace": "default", "owner_name": "builder", "volume_name": "cache"},
+			Values: []float64{100},
+		},
+		testutil.Series{
+			// Missing volume, must be ignored
+			Labels: map[string]string{"namespace": "default", "owner_name": "builder"},
+			Values: []float64{500},
+		},
+	)
+
+	client, err := NewClient(stub.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.BulkQueryEmptyDirUsage(context.Background(), []string{"default"}, time.Hour)
+	if err != nil {
+		t.Fatalf("BulkQueryEmptyDirUsage() error: %v", err)
+	}
+
+	volumes := got["default/builder"]
+	if len(volumes) != 2 || volumes["tmp"] != 300 || volumes["cache"] != 100 {
+		t.Errorf("emptyDir peaks = %v, want map[cache:100 tmp:300]", volumes)
+	}
+
+	queries := stub.Queries()
+	if len(queries) != 1 || !strings.Contains(queries[0], `ephemeral_storage_container_volume_usage{pod_namespace=~"default"}`) {
+		t.Fatalf("queries = %q, want one filtered on pod_namespace", queries)
+	}
+
+	// Only ReplicaSet names lose their hash suffix; StatefulSet and DaemonSet names stay whole
+	for _, want := range []string{
+		`kube_pod_owner{namespace=~"default", owner_kind="ReplicaSet"}`,
+		`kube_pod_owner{namespace=~"default", owner_kind=~"StatefulSet|DaemonSet"}`,
+	} {
+		if !strings.Contains(queries[0], want) {
+			t.Errorf("query does not contain %s: %s", want, queries[0])
+		}
+	}
+}
+
+func TestBulkQueryEmptyDirUsageWithoutExporter(t *testing.T) {
+	tests := []struct {
+		name    string
+		series  []testutil.Series
+		wantErr error
+	}{
+		{name: "exporter missing", wantErr: ErrEmptyDirMetricsMissing},
+		{
+			name:   "no emptyDirs in the namespaces",
+			series: []testutil.Series{{Labels: map[string]string{}, Values: []float64{12}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := testutil.NewPrometheusStub()
+			defer stub.Close()
+			// Only the presence check finds series, the usage query has no results
+			stub.AddSeries("count(ephemeral_storage_container_volume_usage)", tt.series...)
+
+			client, err := NewClient(stub.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := client.BulkQueryEmptyDirUsage(context.Background(), []string{"default"}, time.Hour)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("BulkQueryEmptyDirUsage() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(got) != 0 {
+				t.Errorf("emptyDir peaks = %v, want none", got)
+			}
+		})
+	}
+}
+
+func TestQueryContainerUsage(t *testing.T) {
+	stub := testutil.NewPrometheusStub()
+	defer stub.Close()
+
+	running := map[string]string{"namespace": "default", "pod": "web-0", "container": "app"}
+	stopped := map[string]string{"namespace": "default", "pod": "job-0", "container": "app"}
+	stub.AddSeries("container_memory_working_set_bytes", testutil.Series{Labels: running, Values: []float64{100}})
+	stub.AddSeries("kube_pod_container_resource_requests",
+		testutil.Series{Labels: running, Values: []float64{64}},
+		testutil.Series{Labels: stopped, Values: []float64{64}},
+	)
+	stub.AddSeries("kube_pod_container_resource_limits", testutil.Series{Labels: running, Values: []float64{128}})
+
+	client, err := NewClient(stub.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.QueryContainerUsage(context.Background(), []string{"default"})
+	if err != nil {
+		t.Fatalf("QueryContainerUsage() error: %v", err)
+	}
+
+	want := types.ContainerUsage{Namespace: "default", Pod: "web-0", Container: "app", WorkingSet: 100, Request: 64, Limit: 128}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("QueryContainerUsage() = %+v, want [%+v]", got, want)
+	}
+}
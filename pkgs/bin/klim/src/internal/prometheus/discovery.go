@@ -0,0 +1,209 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Access modes for reaching a discovered Prometheus.
+const (
+	AccessAuto        = "auto"
+	AccessProxy       = "proxy"
+	AccessPortForward = "port-forward"
+	AccessDirect      = "direct"
+)
+
+// AccessModes lists the supported access modes.
+var AccessModes = []string{AccessAuto, AccessProxy, AccessPortForward, AccessDirect}
+
+// operatedService is the governing service the Prometheus operator creates for every Prometheus CR.
+const operatedService = "prometheus-operated"
+
+var prometheusGVR = schema.GroupVersionResource{
+	Group:    "monitoring.coreos.com",
+	Version:  "v1",
+	Resource: "prometheuses",
+}
+
+// Endpoint is a discovered Prometheus service.
+type Endpoint struct {
+	Namespace   string
+	Service     string
+	Port        int32
+	TargetPort  intstr.IntOrString
+	PodSelector map[string]string
+	PathPrefix  string // Route prefix such as "/prometheus", empty if served at the root
+}
+
+// Connection is a reachable Prometheus URL, possibly backed by a port-forward.
+type Connection struct {
+	URL     string
+	Proxied bool // Requests go through the Kubernetes API server and need its credentials
+	stop    func()
+}
+
+// Close stops the port-forward backing the connection, if any.
+func (c *Connection) Close() {
+	if c.stop != nil {
+		c.stop()
+	}
+}
+
+// DiscoverPrometheus finds Prometheus in the cluster and connects to it using the given access mode.
+func DiscoverPrometheus(ctx context.Context, k8sClient kubernetes.Interface, dynamicClient dynamic.Interface, config *rest.Config, access string) (*Connection, error) {
+	endpoint, err := FindPrometheus(ctx, k8sClient, dynamicClient)
+	if err != nil {
+		return nil, err
+	}
+	return Connect(ctx, endpoint, k8sClient, config, access)
+}
+
+// FindPrometheus locates a Prometheus service, preferring Prometheus operator CRs and falling
+// back to well-known service label selectors. dynamicClient may be nil to skip CR discovery.
+func FindPrometheus(ctx context.Context, k8sClient kubernetes.Interface, dynamicClient dynamic.Interface) (*Endpoint, error) {
+	if dynamicClient != nil {
+		endpoint, err := findPrometheusCR(ctx, k8sClient, dynamicClient)
+		if err == nil {
+			return endpoint, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if os.Getenv("KLIM_DEBUG_QUERIES") == "true" {
+			fmt.Printf("DEBUG: Prometheus CR discovery failed: %v\n", err)
+		}
+	}
+
+	labelSelectors := []string{
+		"app=kube-prometheus-stack-prometheus",
+		"app=prometheus,component=server",
+		"app=prometheus-server",
+		"app=prometheus-operator-prometheus",
+		"app=rancher-monitoring-prometheus",
+		"app=prometheus-prometheus",
+		"app.kubernetes.io/name=prometheus,app.kubernetes.io/component=server",
+		"app=stack-prometheus",
+	}
+
+	for _, selector := range labelSelectors {
+		services, err := k8sClient.CoreV1().Services("").List(ctx, metav1.ListOptions{
+			LabelSelector: selector,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+
+		if len(services.Items) == 0 {
+			continue
+		}
+
+		svc := services.Items[0]
+		if len(svc.Spec.Ports) == 0 {
+			continue
+		}
+
+		return &Endpoint{
+			Namespace:   svc.Namespace,
+			Service:     svc.Name,
+			Port:        svc.Spec.Ports[0].Port,
+			TargetPort:  svc.Spec.Ports[0].TargetPort,
+			PodSelector: svc.Spec.Selector,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("failed to discover prometheus endpoint using Prometheus CRs or label selectors")
+}
+
+// findPrometheusCR discovers Prometheus via monitoring.coreos.com/v1 Prometheus resources.
+func findPrometheusCR(ctx context.Context, k8sClient kubernetes.Interface, dynamicClient dynamic.Interface) (*Endpoint, error) {
+	list, err := dynamicClient.Resource(prometheusGVR).Namespace("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Prometheus resources: %w", err)
+	}
+	if len(list.Items) == 0 {
+		return nil, fmt.Errorf("no Prometheus resources found")
+	}
+
+	// Pick deterministically when several Prometheus instances exist
+	items := list.Items
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].GetNamespace() != items[j].GetNamespace() {
+			return items[i].GetNamespace() < items[j].GetNamespace()
+		}
+		return items[i].GetName() < items[j].GetName()
+	})
+	cr := items[0]
+
+	endpoint := &Endpoint{
+		Namespace:   cr.GetNamespace(),
+		Service:     operatedService,
+		Port:        9090,
+		TargetPort:  intstr.FromString("web"),
+		PodSelector: map[string]string{"prometheus": cr.GetName()},
+		PathPrefix:  routePrefix(cr),
+	}
+
+	// The operator names the web port "web"; use the actual service port if it exists
+	svc, err := k8sClient.CoreV1().Services(endpoint.Namespace).Get(ctx, operatedService, metav1.GetOptions{})
+	if err == nil {
+		for _, port := range svc.Spec.Ports {
+			if port.Name == "web" {
+				endpoint.Port = port.Port
+			}
+		}
+	}
+
+	return endpoint, nil
+}
+
+// routePrefix returns the normalised spec.routePrefix of a Prometheus CR.
+func routePrefix(cr unstructured.Unstructured) string {
+	prefix, _, _ := unstructured.NestedString(cr.Object, "spec", "routePrefix")
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
+// Connect returns a URL for endpoint using the given access mode. "auto" uses in-cluster DNS when
+// running inside the cluster and the API server proxy otherwise.
+func Connect(ctx context.Context, endpoint *Endpoint, k8sClient kubernetes.Interface, config *rest.Config, access string) (*Connection, error) {
+	if access == "" || access == AccessAuto {
+		// Detect if running inside cluster
+		if _, insideCluster := os.LookupEnv("KUBERNETES_SERVICE_HOST"); insideCluster {
+			access = AccessDirect
+		} else {
+			access = AccessProxy
+		}
+	}
+
+	switch access {
+	case AccessDirect:
+		// Inside cluster: use internal DNS
+		url := fmt.Sprintf("http://%s.%s.svc.cluster.local:%d%s", endpoint.Service, endpoint.Namespace, endpoint.Port, endpoint.PathPrefix)
+		return &Connection{URL: url}, nil
+	case AccessProxy:
+		// Outside cluster: use Kubernetes API proxy
+		url := fmt.Sprintf("%s/api/v1/namespaces/%s/services/%s:%d/proxy%s", config.Host, endpoint.Namespace, endpoint.Service, endpoint.Port, endpoint.PathPrefix)
+		return &Connection{URL: url, Proxied: true}, nil
+	case AccessPortForward:
+		return startPortForward(ctx, endpoint, k8sClient, config)
+	default:
+		return nil, fmt.Errorf("unsupported prometheus access mode %q (valid: %s)", access, strings.Join(AccessModes, ", "))
+	}
+}
@@ -0,0 +1,205 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func service(namespace, name string, labels map[string]string, port int32) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Port: port}},
+		},
+	}
+}
+
+func prometheusCR(namespace, name, routePrefix string) *unstructured.Unstructured {
+	cr := &unstructured.Unstructured{}
+	cr.SetAPIVersion("monitoring.coreos.com/v1")
+	cr.SetKind("Prometheus")
+	cr.SetNamespace(namespace)
+	cr.SetName(name)
+	if routePrefix != "" {
+		_ = unstructured.SetNestedField(cr.Object, routePrefix, "spec", "routePrefix")
+	}
+	return cr
+}
+
+func TestDiscoverPrometheus(t *testing.T) {
+	tests := []struct {
+		name          string
+		services      []*corev1.Service
+		prometheuses  []*unstructured.Unstructured
+		access        string
+		insideCluster bool
+		want          string
+		wantProxied   bool
+		wantErr       bool
+	}{
+		{
+			name: "kube-prometheus-stack via api proxy",
+			services: []*corev1.Service{
+				service("observability", "kube-prometheus-stack-prometheus", map[string]string{"app": "kube-prometheus-stack-prometheus"}, 9090),
+			},
+			want:        "https://k8s.example:6443/api/v1/namespaces/observability/services/kube-prometheus-stack-prometheus:9090/proxy",
+			wantProxied: true,
+		},
+		{
+			name: "recommended labels inside cluster",
+			services: []*corev1.Service{
+				service("monitoring", "prometheus", map[string]string{
+					"app.kubernetes.io/name":      "prometheus",
+					"app.kubernetes.io/component": "server",
+				}, 80),
+			},
+			insideCluster: true,
+			want:          "http://prometheus.monitoring.svc.cluster.local:80",
+		},
+		{
+			name: "explicit direct access outside cluster",
+			services: []*corev1.Service{
+				service("monitoring", "prometheus-server", map[string]string{"app": "prometheus-server"}, 80),
+			},
+			access: AccessDirect,
+			want:   "http://prometheus-server.monitoring.svc.cluster.local:80",
+		},
+		{
+			name: "prometheus CR preferred over label selectors",
+			services: []*corev1.Service{
+				service("observability", "kube-prometheus-stack-prometheus", map[string]string{"app": "kube-prometheus-stack-prometheus"}, 9090),
+			},
+			prometheuses: []*unstructured.Unstructured{
+				prometheusCR("monitoring", "k8s", "/prom/"),
+			},
+			access:      AccessProxy,
+			want:        "https://k8s.example:6443/api/v1/namespaces/monitoring/services/prometheus-operated:9090/proxy/prom",
+			wantProxied: true,
+		},
+		{
+			name: "invalid access mode",
+			services: []*corev1.Service{
+				service("monitoring", "prometheus-server", map[string]string{"app": "prometheus-server"}, 80),
+			},
+			access:  "tunnel",
+			wantErr: true,
+		},
+		{
+			name: "unrelated services",
+			services: []*corev1.Service{
+				service("default", "grafana", map[string]string{"app": "grafana"}, 3000),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no services",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.insideCluster {
+				t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+			}
+
+			clientset := fake.NewClientset()
+			for _, svc := range tt.services {
+				if _, err := clientset.CoreV1().Services(svc.Namespace).Create(context.Background(), svc, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			objects := make([]runtime.Object, 0, len(tt.prometheuses))
+			for _, cr := range tt.prometheuses {
+				objects = append(objects, cr)
+			}
+			dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{prometheusGVR: "PrometheusList"}, objects...)
+
+			got, err := DiscoverPrometheus(context.Background(), clientset, dynamicClient, &rest.Config{Host: "https://k8s.example:6443"}, tt.access)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("DiscoverPrometheus() = %q, want error", got.URL)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DiscoverPrometheus() error: %v", err)
+			}
+			defer got.Close()
+			if got.URL != tt.want {
+				t.Errorf("DiscoverPrometheus() = %q, want %q", got.URL, tt.want)
+			}
+			if got.Proxied != tt.wantProxied {
+				t.Errorf("Proxied = %v, want %v", got.Proxied, tt.wantProxied)
+			}
+		})
+	}
+}
+
+func TestFindReadyPod(t *testing.T) {
+	pod := func(name string, phase corev1.PodPhase, ready corev1.ConditionStatus) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: name, Labels: map[string]string{"prometheus": "k8s"}},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:  "prometheus",
+					Ports: []corev1.ContainerPort{{Name: "web", ContainerPort: 9090}},
+				}},
+			},
+			Status: corev1.PodStatus{
+				Phase:      phase,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}},
+			},
+		}
+	}
+
+	clientset := fake.NewClientset(
+		pod("prometheus-k8s-0", corev1.PodPending, corev1.ConditionFalse),
+		pod("prometheus-k8s-1", corev1.PodRunning, corev1.ConditionTrue),
+	)
+	endpoint := &Endpoint{
+		Namespace:   "monitoring",
+		Service:     operatedService,
+		Port:        9090,
+		TargetPort:  intstr.FromString("web"),
+		PodSelector: map[string]string{"prometheus": "k8s"},
+	}
+
+	got, err := findReadyPod(context.Background(), clientset, endpoint)
+	if err != nil {
+		t.Fatalf("findReadyPod() error: %v", err)
+	}
+	if got.Name != "prometheus-k8s-1" {
+		t.Errorf("findReadyPod() = %s, want prometheus-k8s-1", got.Name)
+	}
+
+	port, err := resolveTargetPort(got, endpoint)
+	if err != nil {
+		t.Fatalf("resolveTargetPort() error: %v", err)
+	}
+	if port != 9090 {
+		t.Errorf("resolveTargetPort() = %d, want 9090", port)
+	}
+
+	endpoint.TargetPort = intstr.FromString("metrics")
+	if _, err := resolveTargetPort(got, endpoint); err == nil {
+		t.Error("resolveTargetPort() with unknown port name, want error")
+	}
+
+	endpoint.PodSelector = map[string]string{"prometheus": "other"}
+	if _, err := findReadyPod(context.Background(), clientset, endpoint); err == nil {
+		t.Error("findReadyPod() with no matching pods, want error")
+	}
+}
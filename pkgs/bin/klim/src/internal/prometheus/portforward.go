@@ -0,0 +1,134 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// startPortForward forwards a random local port to a ready Prometheus pod behind endpoint.
+func startPortForward(ctx context.Context, endpoint *Endpoint, k8sClient kubernetes.Interface, config *rest.Config) (*Connection, error) {
+	pod, err := findReadyPod(ctx, k8sClient, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := resolveTargetPort(pod, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	roundTripper, upgrader, err := spdy.RoundTripperFor(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create port-forward transport: %w", err)
+	}
+
+	req := k8sClient.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("portforward")
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: roundTripper}, http.MethodPost, req.URL())
+
+	stopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", port)}, stopCh, readyCh, io.Discard, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create port-forward: %w", err)
+	}
+
+	var once sync.Once
+	stop := func() { once.Do(func() { close(stopCh) }) }
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- forwarder.ForwardPorts()
+	}()
+
+	select {
+	case <-readyCh:
+	case err := <-errCh:
+		return nil, fmt.Errorf("port-forward to %s/%s failed: %w", pod.Namespace, pod.Name, err)
+	case <-ctx.Done():
+		stop()
+		return nil, ctx.Err()
+	}
+
+	ports, err := forwarder.GetPorts()
+	if err != nil || len(ports) == 0 {
+		stop()
+		return nil, fmt.Errorf("failed to get forwarded port: %w", err)
+	}
+
+	// Queries fail with "connection refused" once the forward is lost, so report why
+	go func() {
+		if err := <-errCh; err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: port-forward to %s/%s stopped: %v\n", pod.Namespace, pod.Name, err)
+		}
+	}()
+
+	return &Connection{
+		URL:  fmt.Sprintf("http://127.0.0.1:%d%s", ports[0].Local, endpoint.PathPrefix),
+		stop: stop,
+	}, nil
+}
+
+// findReadyPod returns a running and ready pod selected by the endpoint.
+func findReadyPod(ctx context.Context, k8sClient kubernetes.Interface, endpoint *Endpoint) (*corev1.Pod, error) {
+	if len(endpoint.PodSelector) == 0 {
+		return nil, fmt.Errorf("service %s/%s has no pod selector", endpoint.Namespace, endpoint.Service)
+	}
+
+	pods, err := k8sClient.CoreV1().Pods(endpoint.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(endpoint.PodSelector).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prometheus pods: %w", err)
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				return pod, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no ready prometheus pod found for %s/%s", endpoint.Namespace, endpoint.Service)
+}
+
+// resolveTargetPort maps the service target port to a container port of pod.
+func resolveTargetPort(pod *corev1.Pod, endpoint *Endpoint) (int32, error) {
+	switch {
+	case endpoint.TargetPort.Type == intstr.Int && endpoint.TargetPort.IntVal > 0:
+		return endpoint.TargetPort.IntVal, nil
+	case endpoint.TargetPort.Type == intstr.String && endpoint.TargetPort.StrVal != "":
+		for _, container := range pod.Spec.Containers {
+			for _, port := range container.Ports {
+				if port.Name == endpoint.TargetPort.StrVal {
+					return port.ContainerPort, nil
+				}
+			}
+		}
+		return 0, fmt.Errorf("pod %s/%s has no port named %q", pod.Namespace, pod.Name, endpoint.TargetPort.StrVal)
+	default:
+		// An unset target port defaults to the service port
+		return endpoint.Port, nil
+	}
+}
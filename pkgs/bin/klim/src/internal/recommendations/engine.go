@@ -3,27 +3,48 @@ package recommendations
 import (
 	"fmt"
 	"math"
+	"sort"
 
 	"klim/pkg/types"
 )
 
 // Engine generates resource recommendations.
 type Engine struct {
-	memoryBuffer float64
-	minMemory    float64
+	memoryBuffer    float64
+	minMemory       float64
+	ephemeralBuffer float64
+	minEphemeral    float64
+
+	proposeRuntimeEnv bool
 }
 
 // NewEngine creates a new recommendation engine.
-func NewEngine(memoryBuffer, minMemory float64) *Engine {
+func NewEngine(memoryBuffer, minMemory, ephemeralBuffer, minEphemeral float64) *Engine {
 	return &Engine{
-		memoryBuffer: memoryBuffer,
-		minMemory:    minMemory,
+		memoryBuffer:    memoryBuffer,
+		minMemory:       minMemory,
+		ephemeralBuffer: ephemeralBuffer,
+		minEphemeral:    minEphemeral,
 	}
 }
 
+// SetProposeRuntimeEnv makes runtime conflicts propose env changes instead of raising the limit.
+func (e *Engine) SetProposeRuntimeEnv(enabled bool) {
+	e.proposeRuntimeEnv = enabled
+}
+
 // Generate creates a recommendation based on resource metrics.
 func (e *Engine) Generate(metrics types.ResourceMetrics, workloadKind, workloadName string) types.Recommendation {
-	memoryRecommendation := e.calculateMemoryRecommendation(metrics.MemoryUsage)
+	memoryStats := usageStats(metrics.MemoryUsage, metrics.MemorySummary)
+
+	// DaemonSets are sized for the busiest node and autoscaled workloads for their
+	// minimum replica count, which may exceed the peak observed across all pods
+	sizingPeak := memoryStats.Peak
+	spread := usageSpread(metrics)
+	if spread != nil && spread.SizingPeak > sizingPeak {
+		sizingPeak = spread.SizingPeak
+	}
+	memoryRecommendation := calculateRecommendation(sizingPeak, e.memoryBuffer, e.minMemory)
 
 	// Calculate recommended request - must not exceed limit
 	recommendedRequest := metrics.CurrentRequest
@@ -41,7 +62,7 @@ func (e *Engine) Generate(metrics types.ResourceMetrics, workloadKind, workloadN
 	memoryChange := calculatePercentageChange(metrics.CurrentMemory.Value, memoryRecommendation.Value)
 	severity := determineSeverity(memoryChange)
 
-	return types.Recommendation{
+	rec := types.Recommendation{
 		Namespace:          metrics.Namespace,
 		WorkloadName:       workloadName,
 		WorkloadKind:       workloadKind,
@@ -54,26 +75,55 @@ func (e *Engine) Generate(metrics types.ResourceMetrics, workloadKind, workloadN
 		Severity:           severity,
 		RequestLowered:     requestLowered,
 		MemoryHistory:      metrics.MemoryUsage,
+		MemoryStats:        memoryStats,
+
+		CurrentEphemeral:        metrics.CurrentEphemeral,
+		CurrentEphemeralRequest: metrics.CurrentEphemeralRequest,
+		EphemeralHistory:        metrics.EphemeralUsage,
+
+		Spread: spread,
 	}
-}
 
-// calculateMemoryRecommendation computes memory recommendation using peak + buffer.
-func (e *Engine) calculateMemoryRecommendation(memoryUsage []types.MetricPoint) types.ResourceQuantity {
-	if len(memoryUsage) == 0 {
-		return types.ResourceQuantity{Value: e.minMemory, Unit: "Mi"}
+	// Only recommend ephemeral-storage when usage data is available, since many
+	// clusters do not scrape container_fs_usage_bytes
+	if len(metrics.EphemeralUsage) > 0 || metrics.EphemeralSummary != nil {
+		rec.EphemeralStats = usageStats(metrics.EphemeralUsage, metrics.EphemeralSummary)
+		ephemeralRecommendation := calculateRecommendation(rec.EphemeralStats.Peak, e.ephemeralBuffer, e.minEphemeral)
+		rec.RecommendedEphemeral = ephemeralRecommendation
+		rec.RecommendedEphemeralRequest = metrics.CurrentEphemeralRequest
+
+		if metrics.CurrentEphemeralRequest.Value > ephemeralRecommendation.Value {
+			rec.RecommendedEphemeralRequest = types.ResourceQuantity{
+				Value: ephemeralRecommendation.Value,
+				Unit:  "Mi",
+			}
+			rec.EphemeralRequestLowered = true
+		}
+
+		rec.EphemeralChange = calculatePercentageChange(metrics.CurrentEphemeral.Value, ephemeralRecommendation.Value)
 	}
 
-	peak := 0.0
-	for _, point := range memoryUsage {
-		// Convert bytes to MiB
-		valueMiB := point.Value / (1024 * 1024)
-		if valueMiB > peak {
-			peak = valueMiB
+	// emptyDir volumes are shared by the containers of a pod, so each volume is sized
+	// from its own usage rather than the containers' ephemeral-storage
+	if len(metrics.EmptyDirPeaks) > 0 {
+		rec.RecommendedEmptyDirs = make(map[string]types.ResourceQuantity, len(metrics.EmptyDirPeaks))
+		for volume, peak := range metrics.EmptyDirPeaks {
+			rec.RecommendedEmptyDirs[volume] = calculateRecommendation(peak, e.ephemeralBuffer, e.minEphemeral)
 		}
 	}
 
-	recommended := peak * (1.0 + e.memoryBuffer)
-	recommended = math.Max(recommended, e.minMemory)
+	e.applyRuntimeConstraints(&rec, metrics.Runtime)
+
+	return rec
+}
+
+// calculateRecommendation computes a recommendation in MiB using peak + buffer.
+func calculateRecommendation(peakBytes, buffer, minimum float64) types.ResourceQuantity {
+	// Convert bytes to MiB
+	peak := peakBytes / (1024 * 1024)
+
+	recommended := peak * (1.0 + buffer)
+	recommended = math.Max(recommended, minimum)
 
 	// Round up to nearest integer
 	return types.ResourceQuantity{
@@ -82,6 +132,44 @@ func (e *Engine) calculateMemoryRecommendation(memoryUsage []types.MetricPoint)
 	}
 }
 
+// usageStats returns the server-side summary if present, otherwise summarizes the time series.
+func usageStats(points []types.MetricPoint, summary *types.UsageSummary) types.UsageSummary {
+	if summary != nil {
+		return *summary
+	}
+	return Summarize(points)
+}
+
+// Summarize computes peak and percentiles (in bytes) from a time series.
+func Summarize(points []types.MetricPoint) types.UsageSummary {
+	if len(points) == 0 {
+		return types.UsageSummary{}
+	}
+
+	values := make([]float64, len(points))
+	for i, point := range points {
+		values[i] = point.Value
+	}
+	sort.Float64s(values)
+
+	return types.UsageSummary{
+		Peak: values[len(values)-1],
+		P50:  quantile(values, 0.5),
+		P95:  quantile(values, 0.95),
+		P99:  quantile(values, 0.99),
+	}
+}
+
+// quantile returns the q-quantile of sorted values using linear interpolation,
+// matching Prometheus' quantile_over_time.
+func quantile(sorted []float64, q float64) float64 {
+	rank := q * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	weight := rank - float64(lower)
+	return sorted[lower]*(1-weight) + sorted[upper]*weight
+}
+
 // calculatePercentageChange computes the percentage change between current and recommended.
 func calculatePercentageChange(current, recommended float64) float64 {
 	if current == 0 {
@@ -0,0 +1,289 @@
+package recommendations
+
+import (
+	"math"
+	"testing"
+
+	"klim/pkg/types"
+)
+
+// mib builds metric points from values in MiB.
+func mib(values ...float64) []types.MetricPoint {
+	points := make([]types.MetricPoint, len(values))
+	for i, v := range values {
+		points[i] = types.MetricPoint{Value: v * 1024 * 1024}
+	}
+	return points
+}
+
+func mi(v float64) types.ResourceQuantity {
+	return types.ResourceQuantity{Value: v, Unit: "Mi"}
+}
+
+func TestEngineGenerate(t *testing.T) {
+	tests := []struct {
+		name              string
+		proposeRuntimeEnv bool
+		metrics           types.ResourceMetrics
+		wantLimit         float64
+		wantRequest       float64
+		wantLowered       bool
+		wantSeverity      string
+		wantEphemeral     float64
+		wantEmptyDirs     map[string]float64
+		wantWarnings      int
+		wantEnv           map[string]string
+	}{
+		{
+			name:         "peak plus buffer",
+			metrics:      types.ResourceMetrics{MemoryUsage: mib(50, 100, 80), CurrentMemory: mi(160), CurrentRequest: mi(64)},
+			wantLimit:    150,
+			wantRequest:  64,
+			wantSeverity: "info",
+		},
+		{
+			name: "server-side summary",
+			metrics: types.ResourceMetrics{
+				MemorySummary: &types.UsageSummary{Peak: 200 * 1024 * 1024, P95: 120 * 1024 * 1024},
+				CurrentMemory: mi(300),
+			},
+			wantLimit:    300,
+			wantSeverity: "info",
+		},
+		{
+			name:         "minimum applies",
+			metrics:      types.ResourceMetrics{MemoryUsage: mib(2, 4), CurrentMemory: mi(512)},
+			wantLimit:    10,
+			wantSeverity: "critical",
+		},
+		{
+			name:         "request lowered to limit",
+			metrics:      types.ResourceMetrics{MemoryUsage: mib(100), CurrentMemory: mi(1024), CurrentRequest: mi(512)},
+			wantLimit:    150,
+			wantRequest:  150,
+			wantLowered:  true,
+			wantSeverity: "critical",
+		},
+		{
+			name:         "no current limit",
+			metrics:      types.ResourceMetrics{MemoryUsage: mib(100)},
+			wantLimit:    150,
+			wantSeverity: "critical",
+		},
+		{
+			name:          "ephemeral storage",
+			metrics:       types.ResourceMetrics{MemoryUsage: mib(100), CurrentMemory: mi(150), EphemeralUsage: mib(10, 200)},
+			wantLimit:     150,
+			wantSeverity:  "info",
+			wantEphemeral: 400,
+		},
+		{
+			name: "emptyDir sized from its own usage",
+			metrics: types.ResourceMetrics{
+				MemoryUsage:   mib(100),
+				CurrentMemory: mi(150),
+				EmptyDirPeaks: map[string]float64{"tmp": 300 * 1024 * 1024, "cache": 1024 * 1024},
+			},
+			wantLimit:     150,
+			wantSeverity:  "info",
+			wantEmptyDirs: map[string]float64{"tmp": 600, "cache": 64},
+		},
+		{
+			name: "jvm heap raises limit",
+			metrics: types.ResourceMetrics{
+				MemoryUsage:   mib(400),
+				CurrentMemory: mi(1024),
+				Runtime:       types.RuntimeSettings{Runtime: "jvm", HeapMax: 768, EnvName: "JAVA_OPTS", EnvValue: "-Xmx768m"},
+			},
+			wantLimit:    960,
+			wantSeverity: "info",
+			wantWarnings: 2,
+		},
+		{
+			name:              "jvm heap proposes env",
+			proposeRuntimeEnv: true,
+			metrics: types.ResourceMetrics{
+				MemoryUsage:   mib(400),
+				CurrentMemory: mi(1024),
+				Runtime:       types.RuntimeSettings{Runtime: "jvm", HeapMax: 768, EnvName: "JAVA_OPTS", EnvValue: "-Xms64m -Xmx768m"},
+			},
+			wantLimit:    600,
+			wantSeverity: "warning",
+			wantWarnings: 1,
+			wantEnv:      map[string]string{"JAVA_OPTS": "-Xms64m -Xmx472m"},
+		},
+		{
+			name: "gomemlimit within limit",
+			metrics: types.ResourceMetrics{
+				MemoryUsage:   mib(400),
+				CurrentMemory: mi(600),
+				Runtime:       types.RuntimeSettings{Runtime: "go", GoMemLimit: 500, EnvName: "GOMEMLIMIT", EnvValue: "500MiB"},
+			},
+			wantLimit:    600,
+			wantSeverity: "info",
+		},
+		{
+			name:              "gomemlimit proposes env",
+			proposeRuntimeEnv: true,
+			metrics: types.ResourceMetrics{
+				MemoryUsage:   mib(100),
+				CurrentMemory: mi(600),
+				Runtime:       types.RuntimeSettings{Runtime: "go", GoMemLimit: 500, EnvName: "GOMEMLIMIT", EnvValue: "500MiB"},
+			},
+			wantLimit:    150,
+			wantSeverity: "critical",
+			wantWarnings: 1,
+			wantEnv:      map[string]string{"GOMEMLIMIT": "135MiB"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine(0.5, 10, 1.0, 64)
+			engine.SetProposeRuntimeEnv(tt.proposeRuntimeEnv)
+
+			rec := engine.Generate(tt.metrics, "Deployment", "app")
+
+			if rec.RecommendedMemory.Value != tt.wantLimit {
+				t.Errorf("limit = %v, want %v", rec.RecommendedMemory.Value, tt.wantLimit)
+			}
+			if rec.RecommendedRequest.Value != tt.wantRequest {
+				t.Errorf("request = %v, want %v", rec.RecommendedRequest.Value, tt.wantRequest)
+			}
+			if rec.RequestLowered != tt.wantLowered {
+				t.Errorf("request lowered = %v, want %v", rec.RequestLowered, tt.wantLowered)
+			}
+			if rec.Severity != tt.wantSeverity {
+				t.Errorf("severity = %q, want %q", rec.Severity, tt.wantSeverity)
+			}
+			if rec.RecommendedEphemeral.Value != tt.wantEphemeral {
+				t.Errorf("ephemeral = %v, want %v", rec.RecommendedEphemeral.Value, tt.wantEphemeral)
+			}
+			if len(rec.RecommendedEmptyDirs) != len(tt.wantEmptyDirs) {
+				t.Errorf("emptyDirs = %v, want %v", rec.RecommendedEmptyDirs, tt.wantEmptyDirs)
+			}
+			for volume, want := range tt.wantEmptyDirs {
+				if got := rec.RecommendedEmptyDirs[volume]; got != mi(want) {
+					t.Errorf("emptyDir %s = %v, want %vMi", volume, got, want)
+				}
+			}
+			if len(rec.RuntimeWarnings) != tt.wantWarnings {
+				t.Errorf("runtime warnings = %q, want %d", rec.RuntimeWarnings, tt.wantWarnings)
+			}
+			if len(rec.ProposedEnv) != len(tt.wantEnv) {
+				t.Fatalf("proposed env = %v, want %v", rec.ProposedEnv, tt.wantEnv)
+			}
+			for name, value := range tt.wantEnv {
+				if rec.ProposedEnv[name] != value {
+					t.Errorf("proposed %s = %q, want %q", name, rec.ProposedEnv[name], value)
+				}
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []types.MetricPoint
+		want   types.UsageSummary
+	}{
+		{name: "empty", want: types.UsageSummary{}},
+		{name: "single", points: mib(8), want: types.UsageSummary{Peak: 8 * 1024 * 1024, P50: 8 * 1024 * 1024, P95: 8 * 1024 * 1024, P99: 8 * 1024 * 1024}},
+		{
+			name:   "interpolated",
+			points: []types.MetricPoint{{Value: 30}, {Value: 10}, {Value: 20}, {Value: 40}, {Value: 0}},
+			want:   types.UsageSummary{Peak: 40, P50: 20, P95: 38, P99: 39.6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Summarize(tt.points)
+			if math.Abs(got.Peak-tt.want.Peak) > 1e-9 || math.Abs(got.P50-tt.want.P50) > 1e-9 ||
+				math.Abs(got.P95-tt.want.P95) > 1e-9 || math.Abs(got.P99-tt.want.P99) > 1e-9 {
+				t.Errorf("Summarize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsageSpread(t *testing.T) {
+	tests := []struct {
+		name             string
+		metrics          types.ResourceMetrics
+		wantLimit        float64
+		wantSizedFor     string
+		wantExtrapolated bool
+		wantSummary      string
+	}{
+		{
+			name: "daemonset sized for busiest node",
+			metrics: types.ResourceMetrics{
+				MemoryUsage: mib(100),
+				NodePeaks:   map[string]float64{"small": 100 * 1024 * 1024, "big": 400 * 1024 * 1024},
+			},
+			wantLimit:    600,
+			wantSizedFor: "big",
+			wantSummary:  "per-node peak 100Mi-400Mi across 2 nodes, sized for big (400Mi)",
+		},
+		{
+			name: "hpa observed at min replicas",
+			metrics: types.ResourceMetrics{
+				MemoryUsage:  mib(300),
+				MinReplicas:  2,
+				ReplicaPeaks: map[int]float64{2: 300 * 1024 * 1024, 5: 200 * 1024 * 1024},
+			},
+			wantLimit:    450,
+			wantSizedFor: "2 replicas",
+			wantSummary:  "per-replica peak 200Mi-300Mi at 2-5 replicas, sized for 2 replicas (300Mi)",
+		},
+		{
+			name: "hpa extrapolated to min replicas",
+			metrics: types.ResourceMetrics{
+				MemoryUsage:  mib(200),
+				MinReplicas:  2,
+				ReplicaPeaks: map[int]float64{4: 200 * 1024 * 1024},
+			},
+			wantLimit:        600,
+			wantSizedFor:     "2 replicas",
+			wantExtrapolated: true,
+			wantSummary:      "per-replica peak 200Mi-200Mi at 4 replicas, sized for 2 replicas (400Mi, extrapolated linearly from 4 replicas)",
+		},
+		{
+			name: "replica peaks ignored without hpa",
+			metrics: types.ResourceMetrics{
+				MemoryUsage:  mib(100),
+				ReplicaPeaks: map[int]float64{1: 500 * 1024 * 1024},
+			},
+			wantLimit: 150,
+		},
+	}
+
+	engine := NewEngine(0.5, 10, 1.0, 64)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := engine.Generate(tt.metrics, "Deployment", "test")
+
+			if rec.RecommendedMemory.Value != tt.wantLimit {
+				t.Errorf("limit = %v, want %v", rec.RecommendedMemory.Value, tt.wantLimit)
+			}
+			if tt.wantSizedFor == "" {
+				if rec.Spread != nil {
+					t.Errorf("spread = %+v, want nil", rec.Spread)
+				}
+				return
+			}
+			if rec.Spread == nil {
+				t.Fatal("spread = nil")
+			}
+			if rec.Spread.SizedFor != tt.wantSizedFor || rec.Spread.Extrapolated != tt.wantExtrapolated {
+				t.Errorf("sized for %q (extrapolated %v), want %q (%v)",
+					rec.Spread.SizedFor, rec.Spread.Extrapolated, tt.wantSizedFor, tt.wantExtrapolated)
+			}
+			if got := FormatSpread(rec.Spread); got != tt.wantSummary {
+				t.Errorf("FormatSpread() = %q, want %q", got, tt.wantSummary)
+			}
+		})
+	}
+}
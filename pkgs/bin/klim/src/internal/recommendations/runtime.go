@@ -0,0 +1,106 @@
+package recommendations
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+
+	"klim/pkg/types"
+)
+
+const (
+	// jvmOverheadRatio and jvmMinOverhead estimate non-heap JVM memory
+	// (metaspace, code cache, thread stacks, direct buffers).
+	jvmOverheadRatio = 0.25
+	jvmMinOverhead   = 128.0
+
+	// goMemLimitRatio keeps GOMEMLIMIT below the container limit so the
+	// runtime's GC reacts before the kernel OOM killer does.
+	goMemLimitRatio = 0.9
+)
+
+var xmxReplacePattern = regexp.MustCompile(`-X(?:mx|X:MaxHeapSize=)\d+[kKmMgGtT]?\b`)
+
+// applyRuntimeConstraints checks the memory recommendation against detected runtime settings.
+// Depending on proposeEnv, conflicts are resolved either by raising the recommended limit or
+// by proposing updated env values that fit within it.
+func (e *Engine) applyRuntimeConstraints(rec *types.Recommendation, settings types.RuntimeSettings) {
+	rec.Runtime = settings.Runtime
+	limit := rec.RecommendedMemory.Value
+
+	switch settings.Runtime {
+	case "jvm":
+		if settings.HeapMax > 0 {
+			overhead := math.Max(settings.HeapMax*jvmOverheadRatio, jvmMinOverhead)
+			required := math.Ceil(settings.HeapMax + overhead)
+			if limit >= required {
+				return
+			}
+
+			rec.RuntimeWarnings = append(rec.RuntimeWarnings, fmt.Sprintf(
+				"recommended limit %dMi is below -Xmx %dMi plus %dMi JVM overhead",
+				int64(limit), int64(settings.HeapMax), int64(overhead)))
+
+			heap := math.Floor(limit - math.Max(limit*jvmOverheadRatio/(1+jvmOverheadRatio), jvmMinOverhead))
+			if e.proposeRuntimeEnv && settings.EnvName != "" && heap > 0 {
+				rec.ProposedEnv = map[string]string{
+					settings.EnvName: xmxReplacePattern.ReplaceAllString(settings.EnvValue, fmt.Sprintf("-Xmx%dm", int64(heap))),
+				}
+			} else {
+				e.raiseMemoryLimit(rec, required)
+			}
+			return
+		}
+
+		if settings.MaxRAMPercentage > 0 {
+			// Heap scales with the limit, so only check that enough is left for non-heap memory
+			nonHeap := limit * (1 - settings.MaxRAMPercentage/100)
+			if nonHeap < jvmMinOverhead {
+				rec.RuntimeWarnings = append(rec.RuntimeWarnings, fmt.Sprintf(
+					"MaxRAMPercentage=%.0f leaves only %dMi for non-heap memory at the recommended limit %dMi",
+					settings.MaxRAMPercentage, int64(nonHeap), int64(limit)))
+			}
+			if current := rec.CurrentMemory.Value; current > 0 && limit < current {
+				rec.RuntimeWarnings = append(rec.RuntimeWarnings, fmt.Sprintf(
+					"max heap shrinks from %dMi to %dMi (MaxRAMPercentage=%.0f)",
+					int64(current*settings.MaxRAMPercentage/100), int64(limit*settings.MaxRAMPercentage/100),
+					settings.MaxRAMPercentage))
+			}
+		}
+
+	case "go":
+		required := math.Ceil(settings.GoMemLimit / goMemLimitRatio)
+		if limit >= required {
+			return
+		}
+
+		rec.RuntimeWarnings = append(rec.RuntimeWarnings, fmt.Sprintf(
+			"recommended limit %dMi leaves too little headroom above GOMEMLIMIT %dMi",
+			int64(limit), int64(settings.GoMemLimit)))
+
+		if e.proposeRuntimeEnv {
+			rec.ProposedEnv = map[string]string{
+				"GOMEMLIMIT": fmt.Sprintf("%dMiB", int64(math.Floor(limit*goMemLimitRatio))),
+			}
+		} else {
+			e.raiseMemoryLimit(rec, required)
+		}
+	}
+}
+
+// raiseMemoryLimit raises the recommended limit to satisfy a runtime constraint.
+func (e *Engine) raiseMemoryLimit(rec *types.Recommendation, required float64) {
+	rec.RuntimeWarnings = append(rec.RuntimeWarnings, fmt.Sprintf("limit raised to %dMi to respect runtime settings", int64(required)))
+	rec.RecommendedMemory.Value = required
+	rec.MemoryChange = calculatePercentageChange(rec.CurrentMemory.Value, required)
+	rec.Severity = determineSeverity(rec.MemoryChange)
+
+	// The request was only lowered to fit the old limit
+	if rec.RequestLowered {
+		rec.RecommendedRequest = rec.CurrentRequest
+		rec.RequestLowered = rec.CurrentRequest.Value > required
+		if rec.RequestLowered {
+			rec.RecommendedRequest = types.ResourceQuantity{Value: required, Unit: "Mi"}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package recommendations
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"klim/pkg/types"
+)
+
+// usageSpread summarises per-node or per-replica-count peaks and picks the usage to size for:
+// the worst node for DaemonSets and the minimum replica count for HPA-managed workloads.
+// It returns nil if no breakdown is available.
+func usageSpread(metrics types.ResourceMetrics) *types.UsageSpread {
+	switch {
+	case len(metrics.NodePeaks) > 0:
+		return nodeSpread(metrics.NodePeaks)
+	case metrics.MinReplicas > 0 && len(metrics.ReplicaPeaks) > 0:
+		return replicaSpread(metrics.ReplicaPeaks, metrics.MinReplicas)
+	default:
+		return nil
+	}
+}
+
+// nodeSpread sizes a DaemonSet for its busiest node.
+func nodeSpread(nodePeaks map[string]float64) *types.UsageSpread {
+	nodes := make([]string, 0, len(nodePeaks))
+	for node := range nodePeaks {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	spread := &types.UsageSpread{Dimension: "node", Peaks: make(map[string]float64, len(nodes))}
+	for i, node := range nodes {
+		peak := nodePeaks[node]
+		spread.Peaks[node] = peak
+		if i == 0 || peak < spread.Min {
+			spread.Min = peak
+		}
+		if i == 0 || peak > spread.Max {
+			spread.Max = peak
+			spread.SizedFor = node
+		}
+	}
+	spread.SizingPeak = spread.Max
+
+	return spread
+}
+
+// replicaSpread sizes an autoscaled workload for its minimum replica count, where each replica
+// carries the largest share of the load. If the workload never ran at minReplicas during the
+// window, the per-replica peak at the lowest observed count is scaled linearly down to it.
+//
+// The extrapolation assumes all memory grows with the load a replica serves, so it overestimates
+// workloads with a large fixed baseline, e.g. 4 replicas at 200Mi become 800Mi at 1 replica.
+// Such spreads are marked Extrapolated and shown as such, so the limit can be checked by hand.
+func replicaSpread(replicaPeaks map[int]float64, minReplicas int) *types.UsageSpread {
+	counts := make([]int, 0, len(replicaPeaks))
+	for count := range replicaPeaks {
+		counts = append(counts, count)
+	}
+	sort.Ints(counts)
+
+	spread := &types.UsageSpread{
+		Dimension: "replicas",
+		Peaks:     make(map[string]float64, len(counts)),
+		SizedFor:  fmt.Sprintf("%d replicas", minReplicas),
+	}
+	for i, count := range counts {
+		peak := replicaPeaks[count]
+		spread.Peaks[strconv.Itoa(count)] = peak
+		if i == 0 || peak < spread.Min {
+			spread.Min = peak
+		}
+		if i == 0 || peak > spread.Max {
+			spread.Max = peak
+		}
+	}
+
+	if peak, ok := replicaPeaks[minReplicas]; ok {
+		spread.SizingPeak = peak
+		return spread
+	}
+
+	lowest := counts[0]
+	spread.SizingPeak = replicaPeaks[lowest]
+	if lowest > minReplicas {
+		spread.SizingPeak = replicaPeaks[lowest] * float64(lowest) / float64(minReplicas)
+		spread.Extrapolated = true
+	}
+
+	return spread
+}
+
+// FormatSpread describes a usage spread in one line, or returns "" if there is nothing to show
+// because usage was only observed on a single node or replica count.
+func FormatSpread(spread *types.UsageSpread) string {
+	if spread == nil || (len(spread.Peaks) < 2 && !spread.Extrapolated) {
+		return ""
+	}
+
+	const mib = 1024 * 1024
+	keys := make([]string, 0, len(spread.Peaks))
+	for key := range spread.Peaks {
+		keys = append(keys, key)
+	}
+
+	unit, across := "node", ""
+	if spread.Dimension == "replicas" {
+		unit = "replica"
+		sort.Slice(keys, func(i, j int) bool {
+			a, _ := strconv.Atoi(keys[i])
+			b, _ := strconv.Atoi(keys[j])
+			return a < b
+		})
+		across = fmt.Sprintf("at %s replicas", keys[0])
+		if len(keys) > 1 {
+			across = fmt.Sprintf("at %s-%s replicas", keys[0], keys[len(keys)-1])
+		}
+	} else {
+		across = fmt.Sprintf("across %d nodes", len(keys))
+	}
+
+	summary := fmt.Sprintf("per-%s peak %.0fMi-%.0fMi %s, sized for %s (%.0fMi",
+		unit, spread.Min/mib, spread.Max/mib, across, spread.SizedFor, spread.SizingPeak/mib)
+	if spread.Extrapolated {
+		summary += fmt.Sprintf(", extrapolated linearly from %s replicas", keys[0])
+	}
+	return summary + ")"
+}
@@ -0,0 +1,132 @@
+// Package testutil provides fakes shared by klim's tests.
+package testutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Series is a canned Prometheus series with one value per minute ending at the query time.
+type Series struct {
+	Labels map[string]string
+	Values []float64
+}
+
+type cannedResponse struct {
+	match  string
+	series []Series
+}
+
+// PrometheusStub is an httptest server implementing the query endpoints of the Prometheus HTTP API.
+// Queries are answered with the first canned response whose match string is contained in the query,
+// or an empty result if none matches.
+type PrometheusStub struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses []cannedResponse
+	queries   []string
+}
+
+// NewPrometheusStub starts a stub server. Callers must Close it.
+func NewPrometheusStub() *PrometheusStub {
+	stub := &PrometheusStub{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/query_range", stub.handle("matrix"))
+	mux.HandleFunc("/api/v1/query", stub.handle("vector"))
+	stub.Server = httptest.NewServer(mux)
+	return stub
+}
+
+// AddSeries registers series returned for queries containing match.
+func (s *PrometheusStub) AddSeries(match string, series ...Series) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, cannedResponse{match: match, series: series})
+}
+
+// Queries returns all queries received so far.
+func (s *PrometheusStub) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+// handle serves range (matrix) or instant (vector) queries.
+func (s *PrometheusStub) handle(resultType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query := r.Form.Get("query")
+
+		s.mu.Lock()
+		s.queries = append(s.queries, query)
+		var series []Series
+		for _, resp := range s.responses {
+			if strings.Contains(query, resp.match) {
+				series = resp.series
+				break
+			}
+		}
+		s.mu.Unlock()
+
+		end := time.Now()
+		if ts := r.Form.Get("end"); ts != "" {
+			end = parseTime(ts, end)
+		} else if ts := r.Form.Get("time"); ts != "" {
+			end = parseTime(ts, end)
+		}
+
+		result := make([]map[string]any, 0, len(series))
+		for _, ser := range series {
+			entry := map[string]any{"metric": ser.Labels}
+			if resultType == "vector" {
+				var last float64
+				if len(ser.Values) > 0 {
+					last = ser.Values[len(ser.Values)-1]
+				}
+				entry["value"] = samplePair(end, last)
+			} else {
+				values := make([]any, 0, len(ser.Values))
+				for i, v := range ser.Values {
+					ts := end.Add(-time.Duration(len(ser.Values)-1-i) * time.Minute)
+					values = append(values, samplePair(ts, v))
+				}
+				entry["values"] = values
+			}
+			result = append(result, entry)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": "success",
+			"data": map[string]any{
+				"resultType": resultType,
+				"result":     result,
+			},
+		})
+	}
+}
+
+// samplePair encodes a sample in the Prometheus API [timestamp, "value"] format.
+func samplePair(ts time.Time, value float64) []any {
+	return []any{float64(ts.UnixMilli()) / 1000, strconv.FormatFloat(value, 'f', -1, 64)}
+}
+
+// parseTime parses a Prometheus API timestamp (RFC3339 or unix seconds).
+func parseTime(s string, fallback time.Time) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.UnixMilli(int64(f * 1000))
+	}
+	return fallback
+}
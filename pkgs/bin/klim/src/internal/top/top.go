@@ -0,0 +1,225 @@
+package top
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+
+	"klim/internal/graph"
+	"klim/pkg/types"
+)
+
+// SortKeys lists the supported sort keys; the first one is the default.
+var SortKeys = []string{"percent", "usage", "limit", "request", "name"}
+
+// historyLength is the number of samples kept per container for sparklines.
+const historyLength = 30
+
+// UsageSource returns the current memory usage of containers.
+type UsageSource interface {
+	QueryContainerUsage(ctx context.Context, namespaces []string) ([]types.ContainerUsage, error)
+}
+
+// View polls a UsageSource and renders a continuously refreshing usage table.
+type View struct {
+	source     UsageSource
+	namespaces []string
+	interval   time.Duration
+	sortKey    string
+	out        io.Writer
+	history    map[string][]types.MetricPoint
+}
+
+// NewView creates a new top view writing to out.
+func NewView(source UsageSource, namespaces []string, interval time.Duration, sortKey string, out io.Writer) *View {
+	return &View{
+		source:     source,
+		namespaces: namespaces,
+		interval:   interval,
+		sortKey:    sortKey,
+		out:        out,
+		history:    make(map[string][]types.MetricPoint),
+	}
+}
+
+// ValidSortKey reports whether key is a supported sort key.
+func ValidSortKey(key string) bool {
+	for _, k := range SortKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Run refreshes the view every interval until ctx is cancelled.
+func (v *View) Run(ctx context.Context) error {
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+
+	for {
+		frame, err := v.Refresh(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// Keep polling on transient errors, the next refresh may succeed
+		fmt.Fprint(v.out, "\033[H\033[2J")
+		if err != nil {
+			fmt.Fprintf(v.out, "Error: %v\n", err)
+		} else {
+			fmt.Fprint(v.out, frame)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh polls the source once, records history and returns the rendered frame.
+func (v *View) Refresh(ctx context.Context) (string, error) {
+	usage, err := v.source.QueryContainerUsage(ctx, v.namespaces)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool, len(usage))
+	for _, u := range usage {
+		key := usageKey(u)
+		seen[key] = true
+
+		points := append(v.history[key], types.MetricPoint{Timestamp: now, Value: u.WorkingSet})
+		if len(points) > historyLength {
+			points = points[len(points)-historyLength:]
+		}
+		v.history[key] = points
+	}
+
+	// Forget containers that went away, e.g. during a rollout
+	for key := range v.history {
+		if !seen[key] {
+			delete(v.history, key)
+		}
+	}
+
+	SortUsage(usage, v.sortKey)
+	return v.render(usage, now)
+}
+
+// render draws the usage table.
+func (v *View) render(usage []types.ContainerUsage, now time.Time) (string, error) {
+	var builder strings.Builder
+
+	namespaces := "all namespaces"
+	if len(v.namespaces) > 0 {
+		namespaces = strings.Join(v.namespaces, ", ")
+	}
+	builder.WriteString(fmt.Sprintf("klim top - %s - %s - every %s, sorted by %s (Ctrl-C to quit)\n\n",
+		now.Format("15:04:05"), namespaces, v.interval, v.sortKey))
+
+	table := tablewriter.NewTable(&builder)
+	table.Header(
+		"Namespace",
+		"Pod",
+		"Container",
+		"Trend",
+		"Working Set",
+		"Request",
+		"Limit",
+		"% Limit",
+	)
+
+	for _, u := range usage {
+		table.Append([]interface{}{
+			u.Namespace,
+			u.Pod,
+			u.Container,
+			graph.GenerateSparkline(v.history[usageKey(u)], historyLength),
+			formatMiB(u.WorkingSet),
+			formatMiB(u.Request),
+			formatMiB(u.Limit),
+			colorPercent(u),
+		})
+	}
+
+	if err := table.Render(); err != nil {
+		return "", fmt.Errorf("failed to render table: %w", err)
+	}
+	return builder.String(), nil
+}
+
+// SortUsage sorts containers by the given key. Numeric keys sort descending;
+// containers without a limit sort last for "percent".
+func SortUsage(usage []types.ContainerUsage, key string) {
+	sort.SliceStable(usage, func(i, j int) bool {
+		a, b := usage[i], usage[j]
+		switch key {
+		case "usage":
+			if a.WorkingSet != b.WorkingSet {
+				return a.WorkingSet > b.WorkingSet
+			}
+		case "limit":
+			if a.Limit != b.Limit {
+				return a.Limit > b.Limit
+			}
+		case "request":
+			if a.Request != b.Request {
+				return a.Request > b.Request
+			}
+		case "percent":
+			pa, pb := percentOfLimit(a), percentOfLimit(b)
+			if pa != pb {
+				return pa > pb
+			}
+		}
+		return usageKey(a) < usageKey(b)
+	})
+}
+
+// percentOfLimit returns working set as percentage of the limit, or -1 without a limit.
+func percentOfLimit(u types.ContainerUsage) float64 {
+	if u.Limit <= 0 {
+		return -1
+	}
+	return u.WorkingSet / u.Limit * 100
+}
+
+// colorPercent formats percent of limit, colored by how close the container is to OOM.
+func colorPercent(u types.ContainerUsage) string {
+	percent := percentOfLimit(u)
+	if percent < 0 {
+		return "N/A"
+	}
+
+	str := fmt.Sprintf("%.1f%%", percent)
+	switch {
+	case percent >= 90:
+		return fmt.Sprintf("\033[31m%s\033[0m", str)
+	case percent >= 75:
+		return fmt.Sprintf("\033[33m%s\033[0m", str)
+	default:
+		return fmt.Sprintf("\033[32m%s\033[0m", str)
+	}
+}
+
+// formatMiB formats bytes as whole MiB, or "-" if unset.
+func formatMiB(bytes float64) string {
+	if bytes <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0fMi", bytes/(1024*1024))
+}
+
+// usageKey identifies a container across refreshes.
+func usageKey(u types.ContainerUsage) string {
+	return fmt.Sprintf("%s/%s/%s", u.Namespace, u.Pod, u.Container)
+}
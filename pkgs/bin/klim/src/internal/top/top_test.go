@@ -0,0 +1,96 @@
+package top
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"klim/pkg/types"
+)
+
+// fakeSource returns a fixed sequence of usage snapshots.
+type fakeSource struct {
+	snapshots [][]types.ContainerUsage
+	calls     int
+}
+
+func (f *fakeSource) QueryContainerUsage(ctx context.Context, namespaces []string) ([]types.ContainerUsage, error) {
+	snapshot := f.snapshots[f.calls]
+	f.calls++
+	return snapshot, nil
+}
+
+func TestSortUsage(t *testing.T) {
+	usage := []types.ContainerUsage{
+		{Namespace: "a", Pod: "small", Container: "app", WorkingSet: 10, Request: 5, Limit: 100},
+		{Namespace: "a", Pod: "nolimit", Container: "app", WorkingSet: 500},
+		{Namespace: "a", Pod: "hot", Container: "app", WorkingSet: 95, Request: 50, Limit: 100},
+		{Namespace: "b", Pod: "big", Container: "app", WorkingSet: 300, Request: 200, Limit: 1000},
+	}
+
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{key: "percent", want: []string{"hot", "big", "small", "nolimit"}},
+		{key: "usage", want: []string{"nolimit", "big", "hot", "small"}},
+		{key: "limit", want: []string{"big", "hot", "small", "nolimit"}},
+		{key: "request", want: []string{"big", "hot", "small", "nolimit"}},
+		{key: "name", want: []string{"hot", "nolimit", "small", "big"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			sorted := append([]types.ContainerUsage(nil), usage...)
+			SortUsage(sorted, tt.key)
+
+			var got []string
+			for _, u := range sorted {
+				got = append(got, u.Pod)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("SortUsage(%s) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshTracksHistory(t *testing.T) {
+	web := types.ContainerUsage{Namespace: "default", Pod: "web-1", Container: "app", Limit: 200 * 1024 * 1024}
+	old := types.ContainerUsage{Namespace: "default", Pod: "web-0", Container: "app"}
+
+	source := &fakeSource{}
+	for i := 1; i <= 3; i++ {
+		web.WorkingSet = float64(i*50) * 1024 * 1024
+		snapshot := []types.ContainerUsage{web}
+		if i < 3 {
+			snapshot = append(snapshot, old)
+		}
+		source.snapshots = append(source.snapshots, snapshot)
+	}
+
+	view := NewView(source, []string{"default"}, time.Second, "percent", io.Discard)
+
+	var frame string
+	for range source.snapshots {
+		var err error
+		frame, err = view.Refresh(context.Background())
+		if err != nil {
+			t.Fatalf("Refresh() error: %v", err)
+		}
+	}
+
+	if got := len(view.history["default/web-1/app"]); got != 3 {
+		t.Errorf("history length = %d, want 3", got)
+	}
+	if _, exists := view.history["default/web-0/app"]; exists {
+		t.Error("history of removed container was not dropped")
+	}
+	for _, want := range []string{"web-1", "150Mi", "200Mi", "75.0%"} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("frame does not contain %q:\n%s", want, frame)
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"math"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -18,12 +24,18 @@ import (
 	"klim/internal/progress"
 	"klim/internal/prometheus"
 	"klim/internal/recommendations"
+	"klim/internal/top"
 	"klim/pkg/types"
 )
 
 var (
-	version = "dev"
-	cfg     = config.DefaultConfig()
+	version    = "dev"
+	cfg        = config.DefaultConfig()
+	sparklines bool
+
+	topContext  string
+	topInterval = 5 * time.Second
+	topSort     string
 )
 
 // durationValue is a custom flag type that supports weeks and days.
@@ -52,8 +64,21 @@ func (d *durationValue) Type() string {
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		// Restore default signal handling so a second Ctrl-C exits immediately
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		// Exit like a shell does for a command killed by SIGINT
+		if errors.Is(err, context.Canceled) {
+			os.Exit(130)
+		}
 		os.Exit(1)
 	}
 }
@@ -75,81 +100,158 @@ var simpleCmd = &cobra.Command{
 	RunE:  runSimple,
 }
 
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Watch live memory usage versus limits",
+	Long: `Polls Prometheus and shows a continuously refreshing table of current working set,
+request, limit and percent of limit per container. Useful for watching a rollout.`,
+	RunE: runTop,
+}
+
 var applyCmd = &cobra.Command{
 	Use:   "apply",
 	Short: "Apply recommendations to HelmRelease manifests",
 	Long: `Analyzes resource usage and updates bjw-s HelmRelease manifests with recommendations.
-Shows a diff and requires confirmation before applying changes.`,
+Shows a diff and requires confirmation before applying changes.
+
+emptyDir sizeLimits of persistence entries are only recommended when Prometheus scrapes
+the k8s-ephemeral-storage-metrics exporter; the kubelet does not export emptyDir usage.`,
 	RunE: runApply,
 }
 
 func addCommonFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&cfg.PrometheusURL, "prometheus", "p", "", "Prometheus URL (auto-discovered if not specified)")
+	cmd.Flags().StringVar(&cfg.PrometheusAccess, "prometheus-access", prometheus.AccessAuto, fmt.Sprintf("How to reach discovered Prometheus (%s)", strings.Join(prometheus.AccessModes, ", ")))
 	cmd.Flags().StringSliceVarP(&cfg.Namespaces, "namespace", "n", []string{}, "Namespaces to analyze (all if not specified)")
 	cmd.Flags().StringVarP(&cfg.LabelSelector, "selector", "l", "", "Label selector to filter pods")
 	cmd.Flags().Var(&durationValue{&cfg.HistoryDuration}, "history-duration", "Historical data duration (e.g., 7d, 2w, 168h, 1w3d) (default 7d)")
 	cmd.Flags().Float64Var(&cfg.MemoryBuffer, "memory-buffer", 0.5, "Memory buffer multiplier (0.5 = 50% buffer above peak)")
 	cmd.Flags().Float64Var(&cfg.MinMemory, "mem-min", 10.0, "Minimum memory recommendation in Mi")
+	cmd.Flags().Float64Var(&cfg.EphemeralBuffer, "ephemeral-buffer", 1.0, "Ephemeral-storage buffer multiplier (1.0 = 100% buffer above peak)")
+	cmd.Flags().Float64Var(&cfg.MinEphemeral, "ephemeral-min", 64.0, "Minimum ephemeral-storage recommendation in Mi")
+	cmd.Flags().Var(&durationValue{&cfg.Timeout}, "timeout", "Overall analysis deadline, partial results are shown when reached (e.g., 5m) (default none)")
+	cmd.Flags().Var(&durationValue{&cfg.QueryTimeout}, "query-timeout", "Timeout for a single Prometheus query (default 1m0s)")
 	cmd.Flags().BoolVarP(&cfg.Verbose, "verbose", "v", false, "Verbose output")
 }
 
 func init() {
 	rootCmd.AddCommand(simpleCmd)
 	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(topCmd)
 
 	cfg.HistoryDuration = 7 * 24 * time.Hour
 
 	// Simple command flags
 	addCommonFlags(simpleCmd)
 	simpleCmd.Flags().StringSliceVarP(&cfg.Contexts, "context", "c", []string{}, "Kubernetes contexts to analyze (current if not specified)")
-	simpleCmd.Flags().StringVarP(&cfg.OutputFormat, "format", "f", "table", "Output format (table, json, yaml, csv, html)")
+	simpleCmd.Flags().StringVarP(&cfg.OutputFormat, "format", "f", "table", "Output format (table, json, yaml, csv, html, markdown)")
 	simpleCmd.Flags().StringVar(&cfg.OutputFile, "fileoutput", "", "Output file (stdout if not specified)")
 	simpleCmd.Flags().IntVar(&cfg.Concurrency, "concurrency", 10, "Number of concurrent pod analyses")
+	simpleCmd.Flags().BoolVar(&sparklines, "sparklines", true, "Fetch full time series for sparklines (table, markdown, html); disable to aggregate in Prometheus")
 
 	// Apply command flags
 	addCommonFlags(applyCmd)
 	applyCmd.Flags().IntVar(&cfg.Concurrency, "concurrency", 8, "Number of concurrent pod analyses")
 	applyCmd.Flags().StringVar(&cfg.GitRepoPath, "git-repo", "", "Path to git repository containing manifests (required)")
+	applyCmd.Flags().BoolVar(&cfg.UpdateRuntimeEnv, "update-runtime-env", false, "Lower -Xmx/GOMEMLIMIT env values to fit the recommended limit instead of raising the limit")
 	applyCmd.MarkFlagRequired("git-repo")
+
+	// Top command flags
+	topCmd.Flags().StringVarP(&cfg.PrometheusURL, "prometheus", "p", "", "Prometheus URL (auto-discovered if not specified)")
+	topCmd.Flags().StringVar(&cfg.PrometheusAccess, "prometheus-access", prometheus.AccessAuto, fmt.Sprintf("How to reach discovered Prometheus (%s)", strings.Join(prometheus.AccessModes, ", ")))
+	topCmd.Flags().StringSliceVarP(&cfg.Namespaces, "namespace", "n", []string{}, "Namespaces to watch (all if not specified)")
+	topCmd.Flags().StringVarP(&topContext, "context", "c", "", "Kubernetes context (current if not specified)")
+	topCmd.Flags().Var(&durationValue{&topInterval}, "interval", "Refresh interval (default 5s)")
+	topCmd.Flags().StringVarP(&topSort, "sort", "s", top.SortKeys[0], fmt.Sprintf("Sort by (%s)", strings.Join(top.SortKeys, ", ")))
+	topCmd.Flags().Var(&durationValue{&cfg.QueryTimeout}, "query-timeout", "Timeout for a single Prometheus query (default 1m0s)")
+	topCmd.Flags().BoolVarP(&cfg.Verbose, "verbose", "v", false, "Verbose output")
 }
 
-func setupAndAnalyze(ctx string) ([]types.Recommendation, error) {
-	// Create Kubernetes client
-	k8sClient, err := kubernetes.NewClient(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+// analysisContext derives the analysis context from the command, applying --timeout.
+func analysisContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	if cfg.Timeout > 0 {
+		return context.WithTimeout(cmd.Context(), cfg.Timeout)
+	}
+	return context.WithCancel(cmd.Context())
+}
+
+// isInterrupted reports whether err was caused by Ctrl-C or the --timeout deadline.
+func isInterrupted(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// reportUnanalyzed prints the workloads that were skipped because analysis was interrupted.
+func reportUnanalyzed(unanalyzed []string, cause error) {
+	fmt.Fprintf(os.Stderr, "\nAnalysis interrupted (%v), showing partial results.\n", cause)
+	if len(unanalyzed) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%d workload(s) were not analysed:\n", len(unanalyzed))
+	for _, key := range unanalyzed {
+		fmt.Fprintf(os.Stderr, "  - %s\n", key)
 	}
+}
 
+// connectPrometheus creates a Prometheus client for --prometheus or the discovered endpoint.
+// The returned close function stops any port-forward and must be called when done.
+func connectPrometheus(ctx context.Context, k8sClient *kubernetes.Client) (*prometheus.Client, func(), error) {
 	// Discover or use Prometheus endpoint
 	prometheusURL := cfg.PrometheusURL
+	transportConfig := k8sClient.Config()
+	closeConn := func() {}
 	if prometheusURL == "" {
 		if cfg.Verbose {
 			fmt.Println("Discovering Prometheus endpoint...")
 		}
-		discovered, err := prometheus.DiscoverPrometheus(k8sClient.Clientset(), k8sClient.Config())
+		conn, err := prometheus.DiscoverPrometheus(ctx, k8sClient.Clientset(), k8sClient.Dynamic(), k8sClient.Config(), cfg.PrometheusAccess)
 		if err != nil {
-			return nil, fmt.Errorf("failed to discover prometheus: %w (use -p to specify manually)", err)
+			return nil, nil, fmt.Errorf("failed to discover prometheus: %w (use -p to specify manually)", err)
+		}
+		prometheusURL = conn.URL
+		closeConn = conn.Close
+		if !conn.Proxied {
+			transportConfig = nil
 		}
-		prometheusURL = discovered
 		if cfg.Verbose {
 			fmt.Printf("Discovered Prometheus at: %s\n", prometheusURL)
 		}
+	} else if cfg.PrometheusAccess == prometheus.AccessDirect {
+		transportConfig = nil
 	}
 
 	// Create Prometheus client
-	promClient, err := prometheus.NewClient(prometheusURL, k8sClient.Config())
+	promClient, err := prometheus.NewClient(prometheusURL, transportConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create prometheus client: %w", err)
+		closeConn()
+		return nil, nil, fmt.Errorf("failed to create prometheus client: %w", err)
+	}
+	promClient.SetTimeout(cfg.QueryTimeout)
+
+	return promClient, closeConn, nil
+}
+
+func setupAndAnalyze(ctx context.Context, kubeContext string) ([]types.Recommendation, error) {
+	// Create Kubernetes client
+	k8sClient, err := kubernetes.NewClient(kubeContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
 	}
 
+	promClient, closeConn, err := connectPrometheus(ctx, k8sClient)
+	if err != nil {
+		return nil, err
+	}
+	defer closeConn()
+
 	// Create recommendation engine
-	engine := recommendations.NewEngine(cfg.MemoryBuffer, cfg.MinMemory)
+	engine := recommendations.NewEngine(cfg.MemoryBuffer, cfg.MinMemory, cfg.EphemeralBuffer, cfg.MinEphemeral)
+	engine.SetProposeRuntimeEnv(cfg.UpdateRuntimeEnv)
 
 	// Create analyzer
 	an := analyzer.NewAnalyzer(k8sClient, promClient, engine, cfg)
 
 	// Get pod count for progress tracker
-	pods, err := k8sClient.GetPods(cfg.Namespaces, cfg.LabelSelector)
+	pods, err := k8sClient.GetPods(ctx, cfg.Namespaces, cfg.LabelSelector)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pods: %w", err)
 	}
@@ -163,7 +265,7 @@ func setupAndAnalyze(ctx string) ([]types.Recommendation, error) {
 	}
 
 	// Run analysis
-	recs, err := an.Analyze()
+	recs, err := an.Analyze(ctx)
 
 	// Stop tracker
 	if !cfg.Verbose {
@@ -172,6 +274,10 @@ func setupAndAnalyze(ctx string) ([]types.Recommendation, error) {
 	}
 
 	if err != nil {
+		if isInterrupted(err) {
+			reportUnanalyzed(an.Unanalyzed(), err)
+			return recs, err
+		}
 		return nil, fmt.Errorf("analysis failed: %w", err)
 	}
 
@@ -188,27 +294,51 @@ func runSimple(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	// Only formats that draw usage graphs need the full time series
+	switch cfg.OutputFormat {
+	case "table", "markdown", "md", "html":
+		cfg.FetchHistory = sparklines
+	default:
+		cfg.FetchHistory = false
+	}
+
+	ctx, cancel := analysisContext(cmd)
+	defer cancel()
+
 	contexts := cfg.Contexts
 	if len(contexts) == 0 {
 		contexts = []string{""}
 	}
 
 	allRecommendations := make([]types.Recommendation, 0)
+	var interrupted error
 
-	for _, ctx := range contexts {
-		if cfg.Verbose && ctx != "" {
-			fmt.Printf("\nAnalyzing context: %s\n", ctx)
+	for _, kubeContext := range contexts {
+		if cfg.Verbose && kubeContext != "" {
+			fmt.Printf("\nAnalyzing context: %s\n", kubeContext)
 		}
 
-		recs, err := setupAndAnalyze(ctx)
-		if err != nil {
+		recs, err := setupAndAnalyze(ctx, kubeContext)
+		if err != nil && !isInterrupted(err) {
 			return err
 		}
 
 		allRecommendations = append(allRecommendations, recs...)
+
+		// Remaining contexts are not analysed once interrupted
+		if err != nil {
+			interrupted = err
+			// An interrupt is not a usage error
+			cmd.SilenceUsage = true
+			break
+		}
 	}
 
 	if len(allRecommendations) == 0 {
+		// The workloads that were not analysed have been listed instead of the hints
+		if interrupted != nil {
+			return fmt.Errorf("analysis interrupted before any recommendation was generated: %w", interrupted)
+		}
 		fmt.Println("No recommendations generated. This might mean:")
 		fmt.Println("  - No running pods found in the specified namespaces")
 		fmt.Println("  - No metrics available in Prometheus for the specified time range")
@@ -225,6 +355,9 @@ func runSimple(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Recommendations written to: %s\n", cfg.OutputFile)
 	}
 
+	if interrupted != nil {
+		return fmt.Errorf("analysis interrupted, recommendations are incomplete: %w", interrupted)
+	}
 	return nil
 }
 
@@ -242,8 +375,14 @@ func runApply(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("--git-repo is required")
 	}
 
-	recs, err := setupAndAnalyze("")
-	if err != nil {
+	// Graphs are shown for every container before applying
+	cfg.FetchHistory = true
+
+	ctx, cancel := analysisContext(cmd)
+	defer cancel()
+
+	recs, err := setupAndAnalyze(ctx, "")
+	if err != nil && !isInterrupted(err) {
 		return err
 	}
 
@@ -306,14 +445,8 @@ func runApply(cmd *cobra.Command, args []string) error {
 
 		// Show memory usage graphs for each container
 		for _, rec := range recs {
-			// Calculate peak for display
-			var peak float64
-			for _, point := range rec.MemoryHistory {
-				valueMiB := point.Value / (1024 * 1024)
-				if valueMiB > peak {
-					peak = valueMiB
-				}
-			}
+			// Peak for display
+			peak := rec.MemoryStats.Peak / (1024 * 1024)
 
 			// Show full graph
 			fmt.Printf("Container: %s\n", rec.Container)
@@ -331,6 +464,37 @@ func runApply(cmd *cobra.Command, args []string) error {
 					int64(math.Ceil(rec.CurrentRequest.Value)),
 					int64(math.Ceil(rec.RecommendedMemory.Value)))
 			}
+
+			// Show usage spread across nodes or replica counts
+			if spread := recommendations.FormatSpread(rec.Spread); spread != "" {
+				fmt.Printf("↕  Memory %s\n\n", spread)
+			}
+
+			// Show runtime conflicts and proposed env updates
+			for _, warning := range rec.RuntimeWarnings {
+				fmt.Printf("⚠️  %s runtime: %s\n", rec.Runtime, warning)
+			}
+			for _, name := range slices.Sorted(maps.Keys(rec.ProposedEnv)) {
+				fmt.Printf("ℹ️  %s will be set to %q\n", name, rec.ProposedEnv[name])
+			}
+			if len(rec.RuntimeWarnings) > 0 || len(rec.ProposedEnv) > 0 {
+				fmt.Println()
+			}
+
+			// Show ephemeral-storage summary when usage data was available
+			if rec.RecommendedEphemeral.Unit != "" {
+				ephemeralPeak := rec.EphemeralStats.Peak / (1024 * 1024)
+				fmt.Printf("Ephemeral storage %s | Peak: %.0fMi | Recommended: %dMi\n\n",
+					graph.GenerateSparkline(rec.EphemeralHistory, 24),
+					ephemeralPeak,
+					int64(math.Ceil(rec.RecommendedEphemeral.Value)))
+
+				if rec.EphemeralRequestLowered {
+					fmt.Printf("ℹ️  Ephemeral-storage request (%dMi) will be lowered to match recommended limit (%dMi)\n\n",
+						int64(math.Ceil(rec.CurrentEphemeralRequest.Value)),
+						int64(math.Ceil(rec.RecommendedEphemeral.Value)))
+				}
+			}
 		}
 
 		// Generate and display diff
@@ -356,3 +520,29 @@ func runApply(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runTop(cmd *cobra.Command, args []string) error {
+	if !top.ValidSortKey(topSort) {
+		return fmt.Errorf("invalid sort key %q (valid: %s)", topSort, strings.Join(top.SortKeys, ", "))
+	}
+	if topInterval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	if err := config.Validate(cfg); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	k8sClient, err := kubernetes.NewClient(topContext)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	promClient, closeConn, err := connectPrometheus(cmd.Context(), k8sClient)
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	view := top.NewView(promClient, cfg.Namespaces, topInterval, topSort, os.Stdout)
+	return view.Run(cmd.Context())
+}
@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // ResourceMetrics holds time-series metrics for a container.
 type ResourceMetrics struct {
@@ -8,8 +11,61 @@ type ResourceMetrics struct {
 	Pod            string
 	Container      string
 	MemoryUsage    []MetricPoint
+	MemorySummary  *UsageSummary // Server-side aggregates, used instead of MemoryUsage when set
 	CurrentMemory  ResourceQuantity
 	CurrentRequest ResourceQuantity
+
+	EphemeralUsage          []MetricPoint
+	EphemeralSummary        *UsageSummary
+	CurrentEphemeral        ResourceQuantity
+	CurrentEphemeralRequest ResourceQuantity
+	EmptyDirPeaks           map[string]float64 // Peak usage per emptyDir volume of the pod, in bytes
+
+	Runtime RuntimeSettings
+
+	NodePeaks    map[string]float64 // DaemonSets: peak usage per node, in bytes
+	ReplicaPeaks map[int]float64    // Autoscaled workloads: peak per-replica usage by running replica count, in bytes
+	MinReplicas  int                // HPA minReplicas (0 if not autoscaled)
+}
+
+// RuntimeSettings describes memory settings of a language runtime detected from a container spec.
+type RuntimeSettings struct {
+	Runtime          string  // "jvm", "go" or "" if none detected
+	HeapMax          float64 // JVM -Xmx/-XX:MaxHeapSize in MiB
+	MaxRAMPercentage float64 // JVM -XX:MaxRAMPercentage
+	GoMemLimit       float64 // GOMEMLIMIT in MiB
+	EnvName          string  // Env var the setting was read from ("" if from args)
+	EnvValue         string  // Original value of EnvName
+}
+
+// UsageSummary holds aggregated usage statistics over the history window, in bytes.
+type UsageSummary struct {
+	Peak float64
+	P50  float64
+	P95  float64
+	P99  float64
+}
+
+// UsageSpread describes how the peak memory usage of a workload container varies across
+// nodes (DaemonSets) or running replica counts (HPA-managed workloads).
+type UsageSpread struct {
+	Dimension    string             // "node" or "replicas"
+	Peaks        map[string]float64 // Peak usage in bytes keyed by node name or replica count
+	Min          float64            // Lowest peak, in bytes
+	Max          float64            // Highest peak, in bytes
+	SizedFor     string             // Node or replica count the recommendation is sized for
+	SizingPeak   float64            // Usage the recommendation is sized for, in bytes
+	Extrapolated bool               // SizingPeak was scaled linearly from the lowest observed replica count down to minReplicas
+}
+
+// ContainerUsage is a point-in-time memory snapshot of a running container, in bytes.
+type ContainerUsage struct {
+	Namespace  string
+	Pod        string
+	Container  string
+	WorkingSet float64
+	Request    float64 // 0 if not set
+	Limit      float64 // 0 if not set
 }
 
 // MetricPoint represents a single metric value at a point in time.
@@ -39,12 +95,30 @@ type Recommendation struct {
 	PodLabels          map[string]string // Pod labels for manifest lookup
 	ManifestPath       string            // Path to HelmRelease manifest
 	RequestLowered     bool              // True if request was lowered to match limit
-	MemoryHistory      []MetricPoint     // Historical memory usage data
+	MemoryHistory      []MetricPoint     // Historical memory usage data (empty when aggregated server-side)
+	MemoryStats        UsageSummary      // Peak and percentiles of memory usage
+
+	CurrentEphemeral            ResourceQuantity
+	CurrentEphemeralRequest     ResourceQuantity
+	RecommendedEphemeral        ResourceQuantity
+	RecommendedEphemeralRequest ResourceQuantity
+	EphemeralChange             float64                     // percentage change
+	EphemeralRequestLowered     bool                        // True if ephemeral request was lowered to match limit
+	EphemeralHistory            []MetricPoint               // Historical ephemeral-storage usage data
+	EphemeralStats              UsageSummary                // Peak and percentiles of ephemeral-storage usage
+	RecommendedEmptyDirs        map[string]ResourceQuantity // sizeLimit per emptyDir volume of the pod
+
+	Runtime         string            // Detected runtime ("jvm", "go")
+	RuntimeWarnings []string          // Conflicts between the recommendation and runtime settings
+	ProposedEnv     map[string]string // Env var updates that keep the runtime within the recommended limit
+
+	Spread *UsageSpread // Per-node or per-replica-count breakdown, nil if not applicable
 }
 
 // Config holds the configuration for klim.
 type Config struct {
 	PrometheusURL     string
+	PrometheusAccess  string // How to reach discovered Prometheus: auto, proxy, port-forward or direct
 	Namespaces        []string
 	Contexts          []string
 	LabelSelector     string
@@ -54,14 +128,26 @@ type Config struct {
 	HistoryDuration   time.Duration
 	MemoryBuffer      float64
 	MinMemory         float64
+	EphemeralBuffer   float64
+	MinEphemeral      float64
 	Verbose           bool
 	JobGroupingLabels []string
 	Concurrency       int
+	FetchHistory      bool          // Fetch full time series for graphs instead of server-side aggregates
+	UpdateRuntimeEnv  bool          // Propose runtime env changes instead of raising limits
+	Timeout           time.Duration // Overall analysis deadline (0 = none)
+	QueryTimeout      time.Duration // Per-query Prometheus timeout
 }
 
 // PrometheusClient defines the interface for querying Prometheus.
 type PrometheusClient interface {
-	QueryMemoryUsage(namespace, pod, container string, duration time.Duration) ([]MetricPoint, error)
-	QueryMemoryUsageByWorkload(namespace, workloadName, container string, duration time.Duration) ([]MetricPoint, error)
-	BulkQueryMemoryUsage(namespaces []string, duration time.Duration) (map[string]map[string][]MetricPoint, error)
+	QueryMemoryUsage(ctx context.Context, namespace, pod, container string, duration time.Duration) ([]MetricPoint, error)
+	QueryMemoryUsageByWorkload(ctx context.Context, namespace, workloadName, container string, duration time.Duration) ([]MetricPoint, error)
+	BulkQueryMemoryUsage(ctx context.Context, namespaces []string, duration time.Duration) (map[string]map[string][]MetricPoint, error)
+	BulkQueryEphemeralStorageUsage(ctx context.Context, namespaces []string, duration time.Duration) (map[string]map[string][]MetricPoint, error)
+	BulkQueryMemorySummary(ctx context.Context, namespaces []string, duration time.Duration) (map[string]map[string]UsageSummary, error)
+	BulkQueryEphemeralStorageSummary(ctx context.Context, namespaces []string, duration time.Duration) (map[string]map[string]UsageSummary, error)
+	BulkQueryMemoryByNode(ctx context.Context, namespaces []string, duration time.Duration) (map[string]map[string]map[string]float64, error)
+	BulkQueryMemoryByReplicas(ctx context.Context, namespaces, workloads []string, duration time.Duration) (map[string]map[string]map[int]float64, error)
+	BulkQueryEmptyDirUsage(ctx context.Context, namespaces []string, duration time.Duration) (map[string]map[string]float64, error)
 }
@@ -80,6 +80,16 @@ var ListMountsCmd = &cobra.Command{
 			log.Fatal("Failed to list mounts", "error", err)
 		}
 
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			if mounts == nil {
+				mounts = []MountInfo{}
+			}
+			if err := ui.PrintStructured(format, mounts); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			return
+		}
+
 		if len(mounts) == 0 {
 			ui.Info("No active kopia mounts found.")
 			return
@@ -125,9 +135,9 @@ func openFileManager(path string) error {
 
 // MountInfo represents information about an active mount.
 type MountInfo struct {
-	MountPoint string
-	Source     string
-	Type       string
+	MountPoint string `json:"mountPoint"`
+	Source     string `json:"source"`
+	Type       string `json:"type"`
 }
 
 // listActiveMounts returns a list of active kopia-related mounts.
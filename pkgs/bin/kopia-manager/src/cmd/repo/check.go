@@ -0,0 +1,165 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
+
+	"charm.land/log/v2"
+	"github.com/spf13/cobra"
+)
+
+// CheckCmd checks backup freshness and health for monitoring
+var CheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check backup freshness and health for monitoring",
+	Long: `Check the latest snapshot of each backup source from this computer (use
+--all for all hosts) and exit with a Nagios plugin status code:
+
+  0 OK        all sources are fresh and healthy
+  1 WARNING   a warning threshold was crossed
+  2 CRITICAL  a source is older than --crit-age or has no complete snapshot
+  3 UNKNOWN   the repository could not be checked or no sources match
+
+A source is checked for the age of its latest complete snapshot, the errors
+that snapshot recorded, and a size change from the average of the snapshots
+before it. --textfile also writes the per-source results as metrics for the
+node_exporter textfile collector.
+
+Examples:
+  km check                                             # Check this host
+  km check --all --crit-age 36h                        # Alert when any host is 36h behind
+  km check --source /home/alice --size-change 30       # One source, stricter size check
+  km check --textfile /var/lib/node_exporter/km.prom   # Also export metrics`,
+	Args: cobra.NoArgs,
+	// The status line printed by CheckExitCode replaces the error and usage
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		km := manager.NewKopiaManager()
+		all, _ := cmd.Flags().GetBool("all")
+		textfile, _ := cmd.Flags().GetString("textfile")
+
+		var opts manager.CheckOptions
+		opts.Source, _ = cmd.Flags().GetString("source")
+		opts.Hostname, _ = cmd.Flags().GetString("host")
+		opts.Username, _ = cmd.Flags().GetString("user")
+		opts.WarnAge, _ = cmd.Flags().GetDuration("warn-age")
+		opts.CritAge, _ = cmd.Flags().GetDuration("crit-age")
+		opts.MaxErrors, _ = cmd.Flags().GetInt("max-errors")
+		opts.SizeChange, _ = cmd.Flags().GetFloat64("size-change")
+
+		if opts.Hostname == "" && !all {
+			hostname, err := os.Hostname()
+			if err != nil {
+				log.Warn("Could not get hostname, checking all hosts", "error", err)
+			}
+			opts.Hostname = hostname
+		}
+
+		report, err := km.CheckHealth(opts)
+		if err != nil {
+			return err
+		}
+
+		if textfile != "" {
+			if err := report.WriteTextfile(textfile); err != nil {
+				log.Error("Failed to write metrics", "error", err)
+			}
+		}
+
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			if err := ui.PrintStructured(format, report); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+		} else {
+			printCheckReport(report)
+		}
+		if report.Status != manager.CheckOK {
+			return checkStatusError(report.Status)
+		}
+		return nil
+	},
+}
+
+// checkStatusError ends a check whose report was printed with its status as exit code
+type checkStatusError manager.CheckStatus
+
+func (e checkStatusError) Error() string {
+	return "backup check status " + manager.CheckStatus(e).String()
+}
+
+// CheckExitCode returns the Nagios exit code for an error of km check. Failures of the
+// check itself, including invalid arguments and setup failures, are reported as UNKNOWN.
+func CheckExitCode(err error) int {
+	var status checkStatusError
+	if errors.As(err, &status) {
+		return int(status)
+	}
+	fmt.Printf("BACKUP %s - %v\n", manager.CheckUnknown, err)
+	return int(manager.CheckUnknown)
+}
+
+// printCheckReport prints the Nagios status line followed by a table of the sources
+func printCheckReport(report *manager.CheckReport) {
+	info := strings.Join(report.Problems, "; ")
+	if report.Status == manager.CheckOK {
+		info = fmt.Sprintf("%d source(s) healthy", len(report.Sources))
+	}
+	fmt.Printf("BACKUP %s - %s\n", report.Status, info)
+
+	if len(report.Sources) == 0 {
+		return
+	}
+
+	headers := []string{"Status", "Source", "Last Snapshot", "Age", "Size", "Errors"}
+	var rows [][]string
+	for _, s := range report.Sources {
+		lastSnapshot, age, size := "never", "-", "-"
+		if s.Latest != nil {
+			lastSnapshot = s.Latest.StartTime.Format("2006-01-02 15:04:05")
+			age = ui.FormatDuration(report.Time.Sub(s.Latest.StartTime))
+			size = ui.FormatSize(s.Latest.TotalSize)
+			if s.SizeChangePercent != 0 {
+				size += fmt.Sprintf(" (%+.1f%%)", s.SizeChangePercent)
+			}
+		}
+		rows = append(rows, []string{
+			checkStatusLabel(s.Status),
+			s.Source,
+			lastSnapshot,
+			age,
+			size,
+			fmt.Sprintf("%d", s.ErrorCount),
+		})
+	}
+	fmt.Println(ui.RenderTable("Backup Health", headers, rows))
+}
+
+// checkStatusLabel colors a check status for the table
+func checkStatusLabel(status manager.CheckStatus) string {
+	switch status {
+	case manager.CheckOK:
+		return ui.SuccessStyle.Render(status.String())
+	case manager.CheckWarning:
+		return ui.WarningStyle.Render(status.String())
+	default:
+		return ui.ErrorStyle.Render(status.String())
+	}
+}
+
+func init() {
+	CheckCmd.Flags().BoolP("all", "A", false, "Check sources from all hosts")
+	CheckCmd.Flags().StringP("host", "H", "", "Only check sources of this hostname")
+	CheckCmd.Flags().StringP("user", "U", "", "Only check sources of this username")
+	CheckCmd.Flags().String("source", "", "Only check this backed up path")
+	CheckCmd.Flags().Duration("warn-age", manager.DefaultCheckWarnAge, "Warn when the latest snapshot is older than this (0 disables)")
+	CheckCmd.Flags().Duration("crit-age", manager.DefaultCheckCritAge, "Critical when the latest snapshot is older than this (0 disables)")
+	CheckCmd.Flags().Int("max-errors", 0, "Warn when the latest snapshot recorded more errors than this")
+	CheckCmd.Flags().Float64("size-change", manager.DefaultCheckSizeChange, "Warn when the size changes by more than this percentage (0 disables)")
+	CheckCmd.Flags().String("textfile", "", "Write metrics to this node_exporter textfile")
+}
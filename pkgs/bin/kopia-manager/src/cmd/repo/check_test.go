@@ -0,0 +1,27 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"kopia-manager/internal/manager"
+)
+
+func TestCheckExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{checkStatusError(manager.CheckWarning), 1},
+		{checkStatusError(manager.CheckCritical), 2},
+		{fmt.Errorf("wrapped: %w", checkStatusError(manager.CheckCritical)), 2},
+		{errors.New("failed to open repository"), 3},
+	}
+
+	for _, tt := range tests {
+		if got := CheckExitCode(tt.err); got != tt.want {
+			t.Errorf("CheckExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
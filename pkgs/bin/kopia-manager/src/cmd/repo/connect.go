@@ -0,0 +1,182 @@
+package repo
+
+import (
+	"errors"
+	"os"
+
+	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
+
+	"charm.land/log/v2"
+	"github.com/spf13/cobra"
+)
+
+// RepoCmd groups the commands creating and connecting repositories
+var RepoCmd = &cobra.Command{
+	Use:   "repo",
+	Short: "Create, connect or disconnect a repository",
+	Long: `Create, connect or disconnect the repository of the selected profile.
+
+The repository config and password are written to the paths of the profile
+(--profile, or the one selected with "km profile use"), so add a profile
+first to manage another repository:
+
+  km profile add offsite
+  km --profile offsite repo create s3 --bucket backups --endpoint minio.lan:9000`,
+}
+
+// RepoCreateCmd creates a new repository
+var RepoCreateCmd = &cobra.Command{
+	Use:   "create <filesystem|s3>",
+	Short: "Create a new repository and connect to it",
+	Long: `Create a new repository and connect to it.
+
+The password is taken from the usual sources (--password-command,
+the password file, $KOPIA_PASSWORD, ...). If none is set, a random
+password is generated and saved to the password file of the profile.
+
+Examples:
+  km repo create filesystem --path /mnt/backup/kopia
+  km repo create s3 --bucket backups --endpoint s3.eu-central-1.amazonaws.com
+  km repo create s3 --bucket backups --endpoint localhost:9000 --disable-tls   # Local MinIO`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: manager.StorageTypes,
+	Run: func(cmd *cobra.Command, args []string) {
+		km := manager.NewKopiaManager()
+		opts := storageFlags(cmd, args[0])
+
+		password, err := km.RepositoryPassword()
+		generated := errors.Is(err, manager.ErrNoPassword)
+		switch {
+		case generated:
+			if password, err = manager.GeneratePassword(); err != nil {
+				log.Fatal("Failed to create repository", "error", err)
+			}
+			// Save it first so the password is never lost
+			if err := km.SavePassword(password); err != nil {
+				log.Fatal("Failed to create repository", "error", err)
+			}
+		case err != nil:
+			log.Fatal("Failed to create repository", "error", err)
+		}
+
+		if err := km.CreateRepository(opts, password); err != nil {
+			log.Fatal("Failed to create repository", "error", err)
+		}
+
+		ui.Successf("Created repository at %s", opts)
+		ui.Infof("Config: %s", km.ConfigPath)
+		if generated {
+			ui.Infof("Generated a password and saved it to %s", km.PasswordPath)
+			ui.Warning("Keep a copy of the password somewhere safe; without it the backups cannot be restored.")
+		}
+	},
+}
+
+// RepoConnectCmd connects to an existing repository
+var RepoConnectCmd = &cobra.Command{
+	Use:   "connect <filesystem|s3>",
+	Short: "Connect to an existing repository",
+	Long: `Connect to an existing repository.
+
+The password is taken from the usual sources (--password-command,
+the password file, $KOPIA_PASSWORD, ...). If none is set, it is prompted
+for and saved to the password file of the profile once the connection works.
+
+Examples:
+  km repo connect filesystem --path /mnt/backup/kopia
+  km repo connect s3 --bucket backups --endpoint localhost:9000 --disable-tls`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: manager.StorageTypes,
+	Run: func(cmd *cobra.Command, args []string) {
+		km := manager.NewKopiaManager()
+		opts := storageFlags(cmd, args[0])
+
+		password, err := km.RepositoryPassword()
+		prompted := errors.Is(err, manager.ErrNoPassword)
+		switch {
+		case prompted:
+			if password, err = ui.ReadPassword("Repository password: "); err != nil {
+				log.Fatal("Failed to connect", "error", err)
+			}
+		case err != nil:
+			log.Fatal("Failed to connect", "error", err)
+		}
+
+		if err := km.ConnectRepository(opts, password); err != nil {
+			log.Fatal("Failed to connect", "error", err)
+		}
+		if prompted {
+			if err := km.SavePassword(password); err != nil {
+				log.Fatal("Failed to save password", "error", err)
+			}
+		}
+
+		ui.Successf("Connected to repository at %s", opts)
+		ui.Infof("Config: %s", km.ConfigPath)
+		if prompted {
+			ui.Infof("Password saved to %s", km.PasswordPath)
+		}
+	},
+}
+
+// RepoDisconnectCmd disconnects from the repository
+var RepoDisconnectCmd = &cobra.Command{
+	Use:   "disconnect",
+	Short: "Disconnect from the repository and remove its local caches",
+	Long: `Remove the repository config and the local caches of the selected profile.
+The repository itself and the password file are left alone.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		km := manager.NewKopiaManager()
+
+		if err := km.DisconnectRepository(); err != nil {
+			log.Fatal("Failed to disconnect", "error", err)
+		}
+		ui.Successf("Disconnected %s", km.ConfigPath)
+	},
+}
+
+// storageFlags collects the storage options of create and connect
+func storageFlags(cmd *cobra.Command, storageType string) manager.StorageOptions {
+	opts := manager.StorageOptions{Type: storageType}
+	opts.Path, _ = cmd.Flags().GetString("path")
+	opts.Bucket, _ = cmd.Flags().GetString("bucket")
+	opts.Prefix, _ = cmd.Flags().GetString("prefix")
+	opts.Endpoint, _ = cmd.Flags().GetString("endpoint")
+	opts.Region, _ = cmd.Flags().GetString("region")
+	opts.AccessKeyID, _ = cmd.Flags().GetString("access-key")
+	opts.SecretAccessKey, _ = cmd.Flags().GetString("secret-access-key")
+	opts.SessionToken, _ = cmd.Flags().GetString("session-token")
+	opts.DisableTLS, _ = cmd.Flags().GetBool("disable-tls")
+	opts.DisableTLSVerification, _ = cmd.Flags().GetBool("disable-tls-verification")
+
+	// Keep secrets out of the shell history and the --help defaults
+	if opts.AccessKeyID == "" {
+		opts.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if opts.SecretAccessKey == "" {
+		opts.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if opts.SessionToken == "" {
+		opts.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	return opts
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{RepoCreateCmd, RepoConnectCmd} {
+		cmd.Flags().String("path", "", "Filesystem: repository directory")
+		cmd.Flags().String("bucket", "", "S3: bucket name")
+		cmd.Flags().String("prefix", "", "S3: prefix of the repository objects in the bucket")
+		cmd.Flags().String("endpoint", "s3.amazonaws.com", "S3: endpoint host[:port]")
+		cmd.Flags().String("region", "", "S3: region")
+		cmd.Flags().String("access-key", "", "S3: access key ID (default $AWS_ACCESS_KEY_ID)")
+		cmd.Flags().String("secret-access-key", "", "S3: secret access key (default $AWS_SECRET_ACCESS_KEY)")
+		cmd.Flags().String("session-token", "", "S3: session token (default $AWS_SESSION_TOKEN)")
+		cmd.Flags().Bool("disable-tls", false, "S3: use plain HTTP, e.g. for a local MinIO")
+		cmd.Flags().Bool("disable-tls-verification", false, "S3: don't verify the TLS certificate")
+	}
+
+	RepoCmd.AddCommand(RepoCreateCmd, RepoConnectCmd, RepoDisconnectCmd)
+}
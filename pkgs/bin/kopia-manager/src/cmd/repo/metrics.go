@@ -0,0 +1,100 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"kopia-manager/internal/manager"
+
+	"charm.land/log/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+)
+
+// ServeMetricsCmd serves repository and backup unit metrics for Prometheus
+var ServeMetricsCmd = &cobra.Command{
+	Use:   "serve-metrics",
+	Short: "Serve backup metrics for Prometheus",
+	Long: `Keep the repository open and serve metrics on /metrics for Prometheus:
+snapshot counts, the time, size and errors of the latest snapshot, and the
+dedup ratio of each source, plus the state of the kopia systemd units.
+
+The metrics are refreshed every --interval and served from memory between
+refreshes. The dedup ratio is the total size of the snapshots of a source
+over the size of the distinct files they reference; it is only recomputed
+when the snapshots of the source change.
+
+Examples:
+  km serve-metrics                                # Listen on :9852
+  km serve-metrics --listen 127.0.0.1:9852        # Local scrapes only
+  km serve-metrics --interval 15m                 # Refresh less often`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		listen, _ := cmd.Flags().GetString("listen")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		if interval <= 0 {
+			log.Fatal("The refresh interval must be positive", "interval", interval)
+		}
+
+		km := manager.NewKopiaManager()
+		km.KeepOpen()
+		defer km.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		exporter := manager.NewMetricsExporter(km)
+		if err := exporter.Refresh(ctx); err != nil {
+			log.Warn("Failed to refresh metrics", "error", err)
+		}
+
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(exporter)
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+		server := &http.Server{
+			Addr:              listen,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := exporter.Refresh(ctx); err != nil {
+						log.Warn("Failed to refresh metrics", "error", err)
+					}
+				}
+			}
+		}()
+
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		log.Info("Serving metrics", "address", listen, "interval", interval)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Metrics server failed", "error", err)
+		}
+	},
+}
+
+func init() {
+	ServeMetricsCmd.Flags().String("listen", ":9852", "Address to serve metrics on")
+	ServeMetricsCmd.Flags().Duration("interval", manager.DefaultMetricsInterval, "How often to refresh the metrics")
+}
@@ -1,7 +1,10 @@
 package repo
 
 import (
+	"os"
+
 	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
 
 	"charm.land/log/v2"
 	"github.com/spf13/cobra"
@@ -25,16 +28,56 @@ var MaintenanceCmd = &cobra.Command{
 var VerifyCmd = &cobra.Command{
 	Use:   "verify",
 	Short: "Verify repository integrity",
+	Long: `Walk the tree of every snapshot and check that all files and directories it
+references are intact in the repository. Objects shared between snapshots are
+checked once; a broken object is reported for every snapshot that uses it.
+
+--verify-percent also downloads that percentage of the contents from storage,
+bypassing the local cache, which decrypts them and checks their hashes.
+Without it only the presence of the contents is checked.
+
+Examples:
+  km verify                                  # Verify all snapshots
+  km verify -H laptop --source /home/alice   # One source
+  km verify --verify-percent 5 --parallel 16 # Also download 5% of the data`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		km := manager.NewKopiaManager()
 
-		if err := km.VerifyRepository(); err != nil {
+		var opts manager.VerifyOptions
+		opts.Source, _ = cmd.Flags().GetString("source")
+		opts.Hostname, _ = cmd.Flags().GetString("host")
+		opts.Username, _ = cmd.Flags().GetString("user")
+		opts.Parallelism, _ = cmd.Flags().GetInt("parallel")
+		opts.SamplePercent, _ = cmd.Flags().GetFloat64("verify-percent")
+
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			report, err := km.VerifySnapshots(opts, nil)
+			if err != nil {
+				log.Fatal("Verification failed", "error", err)
+			}
+			if err := ui.PrintStructured(format, report); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			if report.Failed > 0 {
+				os.Exit(1)
+			}
+			return
+		}
+
+		if err := km.VerifyRepository(opts); err != nil {
 			log.Fatal("Verification failed", "error", err)
 		}
 	},
 }
 
 func init() {
+	VerifyCmd.Flags().StringP("host", "H", "", "Only verify snapshots of this hostname")
+	VerifyCmd.Flags().StringP("user", "U", "", "Only verify snapshots of this username")
+	VerifyCmd.Flags().String("source", "", "Only verify snapshots of this backed up path")
+	VerifyCmd.Flags().Int("parallel", manager.DefaultVerifyParallelism, "Number of objects to verify at once")
+	VerifyCmd.Flags().Float64("verify-percent", 0, "Percentage of contents to download and hash (0-100)")
+
 	// Wire flags locally to maintenance command
 	if MaintenanceCmd.Flags().Lookup("unsafe") == nil {
 		MaintenanceCmd.Flags().BoolP("unsafe", "u", false, "Run unsafe maintenance (skip safety checks)")
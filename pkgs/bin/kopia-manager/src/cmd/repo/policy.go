@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
 
 	"charm.land/log/v2"
 	"github.com/spf13/cobra"
@@ -19,6 +20,17 @@ var PolicyCmd = &cobra.Command{
 		km := manager.NewKopiaManager()
 		path := args[0]
 
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			info, err := km.PolicyInfo(path)
+			if err != nil {
+				log.Fatal("Failed to get policy", "error", err)
+			}
+			if err := ui.PrintStructured(format, info); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			return
+		}
+
 		policy, err := km.ShowPolicy(path)
 		if err != nil {
 			log.Fatal("Failed to get policy", "error", err)
@@ -26,23 +38,193 @@ var PolicyCmd = &cobra.Command{
 
 		fmt.Print(policy)
 	},
-	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ValidArgsFunction: completeSourcePaths,
+}
+
+// PolicySetCmd edits the policy of a path, user, host or the global policy
+var PolicySetCmd = &cobra.Command{
+	Use:   "set [target]",
+	Short: "Change retention, compression, ignore rules or scheduling",
+	Long: `Change the policy defined for a target. The target is a path on this host,
+user@host:/path, user@host, @host, or --global for the global policy.
+Settings not given are left unchanged; "inherit" removes a setting so the
+parent policy applies again.
+
+Examples:
+  km policy set --global --keep-daily 14 --keep-weekly 8      # Global retention
+  km policy set /home/alice --keep-latest 20                  # Path retention
+  km policy set @laptop --compression zstd                    # Host compression
+  km policy set /home/alice --add-ignore '*.iso' --add-ignore node_modules
+  km policy set /home/alice --snapshot-interval 6h --snapshot-times 03:00,15:00
+  km policy set /home/alice --keep-daily inherit              # Use the parent value again
+
+Use "km policy preview" with the same retention flags to see which snapshots
+a change would expire before applying it.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		km := manager.NewKopiaManager()
+		target := policyTarget(cmd, args)
+
+		changes := manager.PolicyChanges{Retention: retentionFlags(cmd)}
+		changes.Compression, _ = cmd.Flags().GetString("compression")
+		changes.AddIgnore, _ = cmd.Flags().GetStringArray("add-ignore")
+		changes.RemoveIgnore, _ = cmd.Flags().GetStringArray("remove-ignore")
+		changes.ClearIgnore, _ = cmd.Flags().GetBool("clear-ignore")
+		changes.SnapshotInterval, _ = cmd.Flags().GetString("snapshot-interval")
+		changes.SnapshotTimes, _ = cmd.Flags().GetString("snapshot-times")
+
+		applied, err := km.SetPolicy(target, changes)
+		if err != nil {
+			log.Fatal("Failed to set policy", "error", err)
+		}
+
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			result := struct {
+				Target  string   `json:"target"`
+				Changes []string `json:"changes"`
+			}{target, applied}
+			if result.Changes == nil {
+				result.Changes = []string{}
+			}
+			if err := ui.PrintStructured(format, result); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			return
+		}
+
+		if len(applied) == 0 {
+			ui.Info("Policy already up to date.")
+			return
+		}
+		ui.Successf("Updated policy for %s", target)
+		for _, change := range applied {
+			ui.Item(change)
+		}
+	},
+	ValidArgsFunction: completeSourcePaths,
+}
+
+// PolicyPreviewCmd shows which snapshots the retention policy would expire
+var PolicyPreviewCmd = &cobra.Command{
+	Use:   "preview [target]",
+	Short: "Preview which snapshots the retention policy would expire",
+	Long: `Apply the retention policy to the existing snapshots of every source
+covered by the target and show which would be kept and which would expire.
+Nothing is deleted.
+
+Retention flags preview a change before "km policy set" applies it.
+
+Examples:
+  km policy preview /home/alice                       # Current policy
+  km policy preview --global --keep-daily 7           # What if global kept 7 dailies
+  km policy preview @laptop --keep-latest 3 --json    # Machine-readable output`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
 		km := manager.NewKopiaManager()
-		snapshots, err := km.ListSnapshots("", "")
+		target := policyTarget(cmd, args)
+
+		previews, err := km.PreviewRetention(target, manager.PolicyChanges{Retention: retentionFlags(cmd)})
 		if err != nil {
-			return nil, cobra.ShellCompDirectiveNoFileComp
+			log.Fatal("Failed to preview retention", "error", err)
 		}
-		// Deduplicate paths
-		pathSet := make(map[string]struct{})
-		for _, snap := range snapshots {
-			pathSet[snap.Source] = struct{}{}
+
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			if err := ui.PrintStructured(format, previews); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			return
 		}
-		var completions []string
-		for path := range pathSet {
-			if strings.HasPrefix(path, toComplete) {
-				completions = append(completions, path)
+
+		total, expired := 0, 0
+		for _, preview := range previews {
+			headers := []string{"Action", "ID", "Time", "Size", "Reasons"}
+			var rows [][]string
+			for _, e := range preview.Entries {
+				action := ui.SuccessStyle.Render("keep")
+				if !e.Keep {
+					action = ui.ErrorStyle.Render("expire")
+				}
+				rows = append(rows, []string{
+					action,
+					e.Snapshot.ID,
+					e.Snapshot.StartTime.Format("2006-01-02 15:04:05"),
+					ui.FormatSize(e.Snapshot.TotalSize),
+					strings.Join(e.Reasons, ", "),
+				})
 			}
+			title := fmt.Sprintf("%s (retention %s)", preview.Source, preview.Retention)
+			fmt.Println(ui.RenderTable(title, headers, rows))
+			total += len(preview.Entries)
+			expired += preview.Expired
 		}
-		return completions, cobra.ShellCompDirectiveNoFileComp
+
+		ui.Summaryf("%d of %d snapshots would expire", expired, total)
 	},
+	ValidArgsFunction: completeSourcePaths,
+}
+
+// policyTarget returns the policy target from the arguments or --global
+func policyTarget(cmd *cobra.Command, args []string) string {
+	global, _ := cmd.Flags().GetBool("global")
+	switch {
+	case global && len(args) > 0:
+		log.Fatal("Use either a target or --global, not both")
+	case global:
+		return "global"
+	case len(args) == 0:
+		log.Fatal("A target or --global is required")
+	}
+	return args[0]
+}
+
+// retentionFlags collects the --keep-* flags that were given
+func retentionFlags(cmd *cobra.Command) map[string]string {
+	retention := make(map[string]string)
+	for _, name := range manager.RetentionSettings {
+		if cmd.Flags().Changed("keep-" + name) {
+			retention[name], _ = cmd.Flags().GetString("keep-" + name)
+		}
+	}
+	return retention
+}
+
+// completeSourcePaths completes backed up paths
+func completeSourcePaths(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	km := manager.NewKopiaManager()
+	snapshots, err := km.ListSnapshots("", "")
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	// Deduplicate paths
+	pathSet := make(map[string]struct{})
+	for _, snap := range snapshots {
+		pathSet[snap.Source] = struct{}{}
+	}
+	var completions []string
+	for path := range pathSet {
+		if strings.HasPrefix(path, toComplete) {
+			completions = append(completions, path)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{PolicySetCmd, PolicyPreviewCmd} {
+		cmd.Flags().Bool("global", false, "Target the global policy")
+		for _, name := range manager.RetentionSettings {
+			cmd.Flags().String("keep-"+name, "", fmt.Sprintf("Number of %s snapshots to keep, or %q", name, manager.PolicyInherit))
+		}
+	}
+
+	PolicySetCmd.Flags().String("compression", "", "Compression algorithm (e.g. zstd, s2-default, none), or \"inherit\"")
+	PolicySetCmd.Flags().StringArray("add-ignore", nil, "Add an ignore rule (repeatable)")
+	PolicySetCmd.Flags().StringArray("remove-ignore", nil, "Remove an ignore rule (repeatable)")
+	PolicySetCmd.Flags().Bool("clear-ignore", false, "Remove all ignore rules before adding new ones")
+	PolicySetCmd.Flags().String("snapshot-interval", "", "Interval between snapshots (e.g. 6h), or \"inherit\"")
+	PolicySetCmd.Flags().String("snapshot-times", "", "Comma-separated times of day to snapshot (e.g. 03:00,15:00), or \"inherit\"")
+
+	PolicyPreviewCmd.Flags().BoolP("json", "j", false, "Output as JSON (same as --output json)")
+
+	PolicyCmd.AddCommand(PolicySetCmd, PolicyPreviewCmd)
 }
@@ -0,0 +1,185 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
+
+	"charm.land/log/v2"
+	"github.com/spf13/cobra"
+)
+
+// ProfileCmd groups the commands managing repository profiles
+var ProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage named repository profiles",
+	Long: `Profiles name the kopia config and password file of a repository so one km
+can manage several repositories. Select a profile for a single command with
+--profile, or make it the default with "km profile use". Without a profile km
+uses ~/.config/kopia/repository.config.
+
+Profiles are stored in ` + manager.ProfileConfigPath() + `.`,
+}
+
+// ProfileListCmd lists the repository profiles
+var ProfileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List repository profiles",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles, err := manager.LoadProfiles()
+		if err != nil {
+			log.Fatal("Failed to load profiles", "error", err)
+		}
+		list := profiles.List()
+
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			if err := ui.PrintStructured(format, list); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			return
+		}
+
+		if len(list) == 0 {
+			ui.Info("No profiles defined; using ~/.config/kopia/repository.config.")
+			ui.Help("Use 'km profile add' to add one.")
+			return
+		}
+
+		headers := []string{"", "Name", "Config", "Password"}
+		var rows [][]string
+		for _, p := range list {
+			marker := ""
+			if p.Current {
+				marker = "*"
+			}
+			password := ui.ShortenPath(p.PasswordPath)
+			if p.PasswordCommand != "" {
+				password = "$(" + p.PasswordCommand + ")"
+			}
+			rows = append(rows, []string{marker, p.Name, ui.ShortenPath(p.ConfigPath), password})
+		}
+		fmt.Println(ui.RenderTable("Profiles", headers, rows))
+	},
+}
+
+// ProfileAddCmd adds a repository profile
+var ProfileAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a repository profile",
+	Long: `Add a profile for the repository given by the global --config,
+--password-file and --password-command flags. They default to
+~/.config/kopia/<name>.config and ~/.config/kopia/<name>.password.
+
+Examples:
+  km profile add local-nas --config ~/.config/kopia/repository.config --password-file ~/.config/kopia/repository.password
+  km profile add offsite-b2 --use     # Uses ~/.config/kopia/offsite-b2.{config,password}
+  km profile add offsite-b2 --password-command "pass show kopia/b2"`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		use, _ := cmd.Flags().GetBool("use")
+
+		profiles, err := manager.LoadProfiles()
+		if err != nil {
+			log.Fatal("Failed to load profiles", "error", err)
+		}
+
+		kopiaDir := filepath.Dir(manager.DefaultProfile().ConfigPath)
+		profile := manager.Profile{
+			Name:         name,
+			ConfigPath:   filepath.Join(kopiaDir, name+".config"),
+			PasswordPath: filepath.Join(kopiaDir, name+".password"),
+		}
+		if path, _ := cmd.Flags().GetString("config"); path != "" {
+			profile.ConfigPath = path
+		}
+		if path, _ := cmd.Flags().GetString("password-file"); path != "" {
+			profile.PasswordPath = path
+		}
+		if command, _ := cmd.Flags().GetString("password-command"); command != "" {
+			profile.PasswordCommand = command
+			if !cmd.Flags().Changed("password-file") {
+				profile.PasswordPath = ""
+			}
+		}
+
+		if err := profiles.Add(profile); err != nil {
+			log.Fatal("Failed to add profile", "error", err)
+		}
+		if use {
+			if err := profiles.Use(name); err != nil {
+				log.Fatal("Failed to select profile", "error", err)
+			}
+		}
+		if err := profiles.Save(); err != nil {
+			log.Fatal("Failed to save profiles", "error", err)
+		}
+
+		ui.Successf("Added profile %s", name)
+		added, _ := profiles.Get(name)
+		for _, path := range []string{added.ConfigPath, added.PasswordPath} {
+			if path == "" {
+				continue
+			}
+			if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+				ui.Warningf("%s does not exist yet", path)
+			}
+		}
+		if use {
+			ui.Infof("Now using profile %s", name)
+		}
+	},
+}
+
+// ProfileUseCmd selects the default repository profile
+var ProfileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Use a repository profile by default",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles, err := manager.LoadProfiles()
+		if err != nil {
+			log.Fatal("Failed to load profiles", "error", err)
+		}
+		if err := profiles.Use(args[0]); err != nil {
+			log.Fatal("Failed to select profile", "error", err)
+		}
+		if err := profiles.Save(); err != nil {
+			log.Fatal("Failed to save profiles", "error", err)
+		}
+		ui.Successf("Now using profile %s", args[0])
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return CompleteProfiles(cmd, args, toComplete)
+	},
+}
+
+// CompleteProfiles completes profile names
+func CompleteProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	profiles, err := manager.LoadProfiles()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, p := range profiles.List() {
+		if strings.HasPrefix(p.Name, toComplete) {
+			completions = append(completions, p.Name)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	ProfileAddCmd.Flags().Bool("use", false, "Also make it the default profile")
+
+	ProfileCmd.AddCommand(ProfileListCmd, ProfileAddCmd, ProfileUseCmd)
+}
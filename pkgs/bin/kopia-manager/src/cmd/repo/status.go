@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
 
 	"charm.land/log/v2"
 	"github.com/spf13/cobra"
@@ -15,6 +16,18 @@ var StatusCmd = &cobra.Command{
 	Short: "Show repository status",
 	Run: func(cmd *cobra.Command, args []string) {
 		km := manager.NewKopiaManager()
+
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			status, err := km.RepositoryStatus()
+			if err != nil {
+				log.Fatal("Failed to get status", "error", err)
+			}
+			if err := ui.PrintStructured(format, status); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			return
+		}
+
 		status, err := km.GetStatus()
 		if err != nil {
 			log.Fatal("Failed to get status", "error", err)
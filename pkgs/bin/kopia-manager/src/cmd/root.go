@@ -6,26 +6,79 @@ import (
 	"kopia-manager/cmd/services"
 	"kopia-manager/cmd/snapshot"
 	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
 
+	"charm.land/log/v2"
 	"github.com/spf13/cobra"
 )
 
 var (
 	// Global flags
-	configFile   string
-	passwordFile string
+	configFile      string
+	passwordFile    string
+	passwordCommand string
+	profileName     string
+	outputFormat    string
 )
 
 // Root command
 var rootCmd = &cobra.Command{
 	Use:   manager.AppName,
 	Short: "Kopia backup manager with Go library interface",
-	Long:  `Manages Kopia repository operations, snapshots, and systemd backup services using the official Kopia Go library.`,
+	Long: `Manages Kopia repository operations, snapshots, and systemd backup services using the official Kopia Go library.
+
+The repository password is taken from the first of these that is set:
+--password-command or --password-file (default
+~/.config/kopia/repository.password), or the same settings of the profile,
+$KOPIA_PASSWORD, the systemd credential "kopia-password", or the keyring entry
+kopia stores with --persist-credentials.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := ui.ValidateOutputFormat(outputFormat); err != nil {
+			return err
+		}
+		if err := ui.CheckOutputSupported(cmd); err != nil {
+			return err
+		}
+		return selectConnection()
+	},
 }
 
-// Execute runs the root command
-func Execute() error {
-	return rootCmd.Execute()
+// selectConnection points the manager at the repository of the selected profile.
+// --config, --password-file and --password-command override the profile.
+func selectConnection() error {
+	profiles, err := manager.LoadProfiles()
+	if err != nil {
+		return err
+	}
+	profile, err := profiles.Resolve(profileName)
+	if err != nil {
+		return err
+	}
+	if configFile != "" {
+		profile.ConfigPath = configFile
+	}
+	if passwordFile != "" {
+		profile.PasswordPath = passwordFile
+	}
+	if passwordCommand != "" {
+		profile.PasswordCommand = passwordCommand
+	}
+	manager.SetConnection(profile)
+	return nil
+}
+
+// Execute runs the root command and returns the process exit code
+func Execute() int {
+	cmd, err := rootCmd.ExecuteC()
+	if err == nil {
+		return 0
+	}
+	// Monitoring expects UNKNOWN, not 1, whenever the check itself fails
+	if cmd == repo.CheckCmd {
+		return repo.CheckExitCode(err)
+	}
+	log.Error("Command failed", "error", err)
+	return 1
 }
 
 // addIfMissing safely adds subcommands only if they aren't already registered
@@ -54,28 +107,49 @@ func addIfMissing(parent *cobra.Command, cmds ...*cobra.Command) {
 func init() {
 	// Root-level persistent flags (guarded to avoid redefinition)
 	if rootCmd.PersistentFlags().Lookup("config") == nil {
-		rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "Config file path")
+		rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "Kopia repository config file path")
 	}
 	if rootCmd.PersistentFlags().Lookup("password-file") == nil {
-		rootCmd.PersistentFlags().StringVarP(&passwordFile, "password-file", "p", "", "Password file path")
+		rootCmd.PersistentFlags().StringVarP(&passwordFile, "password-file", "p", "", "Repository password file path")
+	}
+	if rootCmd.PersistentFlags().Lookup("password-command") == nil {
+		rootCmd.PersistentFlags().StringVar(&passwordCommand, "password-command", "", "Command printing the repository password (e.g. \"pass show kopia\")")
+	}
+	if rootCmd.PersistentFlags().Lookup("profile") == nil {
+		rootCmd.PersistentFlags().StringVarP(&profileName, "profile", "P", "", "Repository profile to use (see 'km profile list')")
+		_ = rootCmd.RegisterFlagCompletionFunc("profile", repo.CompleteProfiles)
+	}
+	if rootCmd.PersistentFlags().Lookup("output") == nil {
+		rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", ui.OutputText, "Output format for commands that print data: text, json or yaml (others reject json and yaml)")
+		_ = rootCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(ui.OutputFormats, cobra.ShellCompDirectiveNoFileComp))
 	}
 
 	// Wire subcommands (guarded to avoid duplicates)
 	addIfMissing(
 		rootCmd,
 		repo.StatusCmd,
+		repo.CheckCmd,
+		repo.ServeMetricsCmd,
 		snapshot.ListCmd,
 		snapshot.BackupCmd,
 		snapshot.RestoreCmd,
 		snapshot.DeleteCmd,
 		snapshot.InfoCmd,
 		snapshot.DiffCmd,
+		snapshot.FindCmd,
+		snapshot.HistoryCmd,
+		snapshot.PinCmd,
+		snapshot.UnpinCmd,
+		snapshot.TagCmd,
+		snapshot.DescribeCmd,
 		mount.MountCmd,
 		mount.UnmountCmd,
 		repo.PolicyCmd,
 		snapshot.EstimateCmd,
 		repo.MaintenanceCmd,
 		repo.VerifyCmd,
+		repo.ProfileCmd,
+		repo.RepoCmd,
 		services.ServicesCmd,
 		services.StartBackupCmd,
 		services.LogsCmd,
@@ -84,6 +158,33 @@ func init() {
 		completionCmd,
 	)
 
+	// Commands printing data in --output json and yaml; the others only print text
+	ui.SupportStructuredOutput(
+		repo.StatusCmd,
+		repo.CheckCmd,
+		repo.VerifyCmd,
+		repo.PolicyCmd,
+		repo.PolicySetCmd,
+		repo.PolicyPreviewCmd,
+		repo.ProfileListCmd,
+		snapshot.ListCmd,
+		snapshot.BackupCmd,
+		snapshot.RestoreCmd,
+		snapshot.InfoCmd,
+		snapshot.DiffCmd,
+		snapshot.FindCmd,
+		snapshot.HistoryCmd,
+		snapshot.PinCmd,
+		snapshot.UnpinCmd,
+		snapshot.TagAddCmd,
+		snapshot.TagRemoveCmd,
+		snapshot.DescribeCmd,
+		snapshot.EstimateCmd,
+		services.ServicesCmd,
+		services.ListServicesCmd,
+		mount.ListMountsCmd,
+	)
+
 	// Completion for restore command
 	if snapshot.RestoreCmd != nil && snapshot.RestoreCmd.ValidArgsFunction == nil {
 		snapshot.RestoreCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -94,8 +195,12 @@ func init() {
 				}
 				return getAvailableSnapshotIDsWithFlags(cmd), cobra.ShellCompDirectiveNoFileComp
 			}
-			// Second arg is target directory
-			return nil, cobra.ShellCompDirectiveDefault
+			if len(args) == 1 {
+				// Second arg is target directory
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			// Remaining args are paths inside the snapshot
+			return nil, cobra.ShellCompDirectiveNoFileComp
 		}
 	}
 
@@ -123,6 +228,18 @@ func init() {
 		}
 	}
 
+	// Completion for commands that change snapshot metadata (snapshot ID first)
+	for _, c := range []*cobra.Command{snapshot.PinCmd, snapshot.UnpinCmd, snapshot.TagAddCmd, snapshot.TagRemoveCmd, snapshot.DescribeCmd} {
+		if c != nil && c.ValidArgsFunction == nil {
+			c.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				if len(args) == 0 {
+					return getAvailableSnapshotIDs(), cobra.ShellCompDirectiveNoFileComp
+				}
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+		}
+	}
+
 	// Completion for diff command (snapshot IDs for both arguments)
 	if snapshot.DiffCmd != nil && snapshot.DiffCmd.ValidArgsFunction == nil {
 		snapshot.DiffCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -184,4 +301,25 @@ func init() {
 			return nil, cobra.ShellCompDirectiveDefault
 		}
 	}
+	// Completions run without PersistentPreRunE, so they select the profile themselves
+	completeWithConnection(rootCmd)
+}
+
+// completeWithConnection makes the argument completions of cmd and its subcommands
+// use the repository of --profile, --config and the password flags. Cobra parses
+// the flags for shell completion but doesn't run PersistentPreRunE.
+func completeWithConnection(cmd *cobra.Command) {
+	for _, c := range cmd.Commands() {
+		completeWithConnection(c)
+	}
+	complete := cmd.ValidArgsFunction
+	if complete == nil {
+		return
+	}
+	cmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if err := selectConnection(); err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return complete(cmd, args, toComplete)
+	}
 }
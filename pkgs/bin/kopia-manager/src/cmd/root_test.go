@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kopia-manager/cmd/repo"
+	"kopia-manager/internal/manager"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestExecuteExitCodes(t *testing.T) {
+	// Keep profiles and password files of the machine out of the test
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("KOPIA_PASSWORD", "")
+	t.Setenv("CREDENTIALS_DIRECTORY", "")
+	missingConfig := filepath.Join(t.TempDir(), "repository.config")
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "check with an argument", args: []string{"check", "extra"}, want: 3},
+		{name: "check with an invalid flag", args: []string{"check", "--warn-age", "soon"}, want: 3},
+		{name: "check with an unknown profile", args: []string{"check", "--profile", "missing"}, want: 3},
+		{name: "check without a repository", args: []string{"check", "--config", missingConfig, "--password-file", missingConfig}, want: 3},
+		{name: "check with an invalid output format", args: []string{"check", "-o", "xml"}, want: 3},
+		{name: "other commands fail with 1", args: []string{"maintenance", "-o", "json"}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(resetFlags)
+			rootCmd.SetArgs(tt.args)
+			if got := Execute(); got != tt.want {
+				t.Errorf("Execute(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+// resetFlags restores the flags used by the tests to their defaults between runs
+func resetFlags() {
+	reset := func(f *pflag.Flag) {
+		_ = f.Value.Set(f.DefValue)
+		f.Changed = false
+	}
+	rootCmd.PersistentFlags().VisitAll(reset)
+	repo.CheckCmd.Flags().VisitAll(reset)
+	repo.MaintenanceCmd.Flags().VisitAll(reset)
+}
+
+func TestCompletionSelectsProfile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	profiles, err := manager.LoadProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"home", "nas"} {
+		if err := profiles.Add(manager.Profile{Name: name, ConfigPath: filepath.Join("/srv", name+".config")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := profiles.Use("home"); err != nil {
+		t.Fatal(err)
+	}
+	if err := profiles.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// probe completes with the config path of the repository it would open
+	probe := &cobra.Command{
+		Use: "probe",
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{manager.NewKopiaManager().ConfigPath}, cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	rootCmd.AddCommand(probe)
+	t.Cleanup(func() { rootCmd.RemoveCommand(probe) })
+	completeWithConnection(probe)
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "current profile", args: []string{"probe", ""}, want: "/srv/home.config"},
+		{name: "profile flag", args: []string{"--profile", "nas", "probe", ""}, want: "/srv/nas.config"},
+		{name: "config flag", args: []string{"probe", "--config", "/tmp/other.config", ""}, want: "/tmp/other.config"},
+		{name: "unknown profile", args: []string{"probe", "--profile", "missing", ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(resetFlags)
+			var out bytes.Buffer
+			rootCmd.SetOut(&out)
+			t.Cleanup(func() { rootCmd.SetOut(nil) })
+			rootCmd.SetArgs(append([]string{cobra.ShellCompNoDescRequestCmd}, tt.args...))
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("completion error: %v", err)
+			}
+
+			// The output is one completion per line followed by the directive
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			got := strings.Join(lines[:len(lines)-1], "\n")
+			if got != tt.want {
+				t.Errorf("completions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
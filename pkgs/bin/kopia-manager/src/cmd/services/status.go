@@ -19,6 +19,18 @@ var ServicesCmd = &cobra.Command{
 	Short: "Show status of systemd services and timers",
 	Run: func(cmd *cobra.Command, args []string) {
 		km := manager.NewKopiaManager()
+
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			status, err := km.SystemdStatus()
+			if err != nil {
+				log.Fatal("Failed to get services status", "error", err)
+			}
+			if err := ui.PrintStructured(format, status); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			return
+		}
+
 		status, err := km.GetServicesStatus()
 		if err != nil {
 			log.Fatal("Failed to get services status", "error", err)
@@ -33,6 +45,18 @@ var ListServicesCmd = &cobra.Command{
 	Short: "List available backup services and their timers",
 	Run: func(cmd *cobra.Command, args []string) {
 		km := manager.NewKopiaManager()
+
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			statuses, err := km.BackupServiceStatuses()
+			if err != nil {
+				log.Fatal("Failed to list backup services", "error", err)
+			}
+			if err := ui.PrintStructured(format, statuses); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			return
+		}
+
 		services, err := km.ListBackupServices()
 		if err != nil {
 			log.Fatal("Failed to list backup services", "error", err)
@@ -2,9 +2,11 @@ package snapshot
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
 
 	"charm.land/log/v2"
 	"github.com/spf13/cobra"
@@ -14,13 +16,67 @@ import (
 var BackupCmd = &cobra.Command{
 	Use:   "backup [paths...]",
 	Short: "Create a backup of specified paths",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Create a backup of specified paths.
+
+The exclude and one-file-system flags apply to this run only, on top of the
+stored policies (see "km policy").
+
+Up to --parallel paths are backed up at once. A failed path doesn't stop the
+others: the snapshots of the other paths are saved, as are partial snapshots
+of paths with unreadable files. Ctrl-C cancels the running uploads and saves
+them as incomplete snapshots, which the next backup continues from. The exit
+status is non-zero if any path failed.
+
+Hooks run through "sh -c" once per path, in that directory and with the path
+in $KOPIA_SOURCE_PATH. If the pre-hook fails the path is not backed up. The
+post-hook runs after the upload even if it failed; if the post-hook fails the
+snapshot is kept but tagged hook:post-failed. Hook output is recorded in the
+snapshot description.
+
+Examples:
+  km backup ~/Documents --exclude '*.tmp' --exclude node_modules
+  km backup / --one-file-system --exclude-file ~/.config/km/excludes
+  km backup /var/lib/app --pre-hook 'app-ctl dump > "$KOPIA_SOURCE_PATH/dump.sql"'`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		km := manager.NewKopiaManager()
-		description := fmt.Sprintf("Manual backup: %s", time.Now().Format("2006-01-02 15:04:05"))
+		opts := manager.BackupOptions{
+			Description: fmt.Sprintf("Manual backup: %s", time.Now().Format("2006-01-02 15:04:05")),
+		}
+		opts.Exclude, _ = cmd.Flags().GetStringArray("exclude")
+		opts.ExcludeFiles, _ = cmd.Flags().GetStringArray("exclude-file")
+		opts.OneFileSystem, _ = cmd.Flags().GetBool("one-file-system")
+		opts.Parallel, _ = cmd.Flags().GetInt("parallel")
+		opts.PreHook, _ = cmd.Flags().GetString("pre-hook")
+		opts.PostHook, _ = cmd.Flags().GetString("post-hook")
 
-		if err := km.CreateBackup(args, description); err != nil {
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			results, err := km.BackupSources(args, opts)
+			if err != nil {
+				log.Fatal("Backup failed", "error", err)
+			}
+			if err := ui.PrintStructured(format, results); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			for _, result := range results {
+				if result.Failed() {
+					os.Exit(1)
+				}
+			}
+			return
+		}
+
+		if err := km.CreateBackup(args, opts); err != nil {
 			log.Fatal("Backup failed", "error", err)
 		}
 	},
 }
+
+func init() {
+	BackupCmd.Flags().StringArrayP("exclude", "e", nil, "Exclude files matching a .kopiaignore pattern (repeatable)")
+	BackupCmd.Flags().StringArray("exclude-file", nil, "Read exclude patterns from a file, one per line (repeatable)")
+	BackupCmd.Flags().BoolP("one-file-system", "x", false, "Don't cross filesystem boundaries")
+	BackupCmd.Flags().Int("parallel", manager.DefaultBackupParallel, "Number of paths to back up at once")
+	BackupCmd.Flags().String("pre-hook", "", "Shell command to run before each path is backed up")
+	BackupCmd.Flags().String("post-hook", "", "Shell command to run after each path is backed up")
+}
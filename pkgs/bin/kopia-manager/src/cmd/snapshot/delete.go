@@ -22,13 +22,16 @@ var DeleteCmd = &cobra.Command{
 	Long: `Delete snapshots from the repository.
 
 Without --all flag: Deletes a specific snapshot by ID
-With --all flag: Deletes all snapshots, optionally filtered by --host and --user
+With --all flag: Deletes all snapshots, optionally filtered by --host, --user
+and --tag. Pinned snapshots are kept; unpin them first to delete them.
 
 Examples:
   km delete 12e9406f405955816e93                     # Delete specific snapshot
   km delete --all downloads                          # Delete all snapshots from "downloads" backup group
   km delete --all                                    # Delete ALL snapshots (requires confirmation)
-  km delete --all --host default --user attic        # Delete all snapshots for a specific host/user`,
+  km delete --all --host default --user attic        # Delete all snapshots for a specific host/user
+  km delete --all --tag experiment                   # Delete all snapshots tagged "experiment"
+  km delete --all downloads --tag reason:pre-upgrade # Only tagged snapshots of the "downloads" group`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		km := manager.NewKopiaManager()
@@ -36,16 +39,17 @@ Examples:
 		if deleteAll {
 			hostname, _ := cmd.Flags().GetString("host")
 			username, _ := cmd.Flags().GetString("user")
+			tags, _ := cmd.Flags().GetStringArray("tag")
 
 			if len(args) == 0 {
 				// Delete all snapshots, optionally filtered by host/user
-				if err := km.DeleteSnapshot("", true, hostname, username); err != nil {
+				if err := km.DeleteSnapshot("", true, hostname, username, tags); err != nil {
 					log.Fatal("Delete all failed", "error", err)
 				}
 			} else {
 				// Delete all snapshots from a specific backup group
 				backupName := args[0]
-				if err := km.DeleteBackupGroup(backupName); err != nil {
+				if err := km.DeleteBackupGroup(backupName, tags); err != nil {
 					log.Fatal("Delete backup group failed", "error", err)
 				}
 			}
@@ -66,7 +70,7 @@ Examples:
 			return
 		}
 
-		if err := km.DeleteSnapshot(snapshotID, false, "", ""); err != nil {
+		if err := km.DeleteSnapshot(snapshotID, false, "", "", nil); err != nil {
 			log.Fatal("Delete failed", "error", err)
 		}
 	},
@@ -76,4 +80,5 @@ func init() {
 	DeleteCmd.Flags().BoolVarP(&deleteAll, "all", "a", false, "Delete all snapshots")
 	DeleteCmd.Flags().StringP("host", "H", "", "Filter snapshots by hostname")
 	DeleteCmd.Flags().StringP("user", "U", "", "Filter snapshots by username")
+	DeleteCmd.Flags().StringArray("tag", nil, "Only delete snapshots with this tag, as key or key:value (repeatable)")
 }
@@ -0,0 +1,41 @@
+package snapshot
+
+import (
+	"strings"
+
+	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
+	"kopia-manager/internal/util"
+
+	"charm.land/log/v2"
+	"github.com/spf13/cobra"
+)
+
+// DescribeCmd replaces the description of a snapshot
+var DescribeCmd = &cobra.Command{
+	Use:   "describe [snapshot-id] [text...]",
+	Short: "Change the description of a snapshot",
+	Long: `Replace the description of a snapshot. An empty text clears it.
+
+The backup group shown by "km list" comes from the description
+("Automated backup: <name>" or "Manual backup: <name>"), so changing it may
+move the snapshot to another group.
+
+Examples:
+  km describe 12e9406f "Before the database migration"
+  km describe 12e9406f "Manual backup: documents"   # Keep it in the documents group
+  km describe 12e9406f ""                           # Clear the description`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		km := manager.NewKopiaManager()
+
+		update, err := km.DescribeSnapshot(args[0], strings.Join(args[1:], " "))
+		if err != nil {
+			log.Fatal("Describe failed", "error", err)
+		}
+		reportSnapshotUpdate(cmd, "Updated description of", update)
+		if update.Changed && ui.OutputFormat(cmd) == ui.OutputText {
+			ui.Infof("Backup group: %s", util.ExtractBackupName(update.Snapshot))
+		}
+	},
+}
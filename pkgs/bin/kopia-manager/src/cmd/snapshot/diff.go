@@ -4,33 +4,101 @@ import (
 	"fmt"
 
 	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
 
 	"charm.land/log/v2"
 
 	"github.com/spf13/cobra"
 )
 
-// diffCmd compares two snapshots and prints the diff
+// DiffCmd compares two snapshots and prints the changed files
 var DiffCmd = &cobra.Command{
 	Use:   "diff [snapshot-id-1] [snapshot-id-2]",
 	Short: "Compare two snapshots",
-	Args:  cobra.ExactArgs(2),
+	Long: `Compare two snapshots file by file.
+
+Files are matched by path and reported as added, removed or modified
+(content changed, detected by object ID), together with size deltas.
+
+Examples:
+  km diff 12e9406f 7a3b2c1d                        # List all changed files
+  km diff 12e9406f 7a3b2c1d --path etc/nginx       # Only files below etc/nginx
+  km diff 12e9406f 7a3b2c1d --path '*.conf'        # Glob relative to the snapshot root
+  km diff 12e9406f 7a3b2c1d --summary              # Only show counts and total size delta
+  km diff 12e9406f 7a3b2c1d --json                 # Machine-readable output`,
+	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		km := manager.NewKopiaManager()
 		snapshot1 := args[0]
 		snapshot2 := args[1]
+		pathFilter, _ := cmd.Flags().GetString("path")
+		summaryOnly, _ := cmd.Flags().GetBool("summary")
 
-		diff, err := km.DiffSnapshots(snapshot1, snapshot2)
+		diff, err := km.DiffSnapshots(snapshot1, snapshot2, manager.DiffOptions{PathFilter: pathFilter})
 		if err != nil {
-			log.Error("Failed to diff snapshots", "error", err)
+			log.Fatal("Failed to diff snapshots", "error", err)
+		}
+
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			if summaryOnly {
+				diff.Entries = nil
+			}
+			if err := ui.PrintStructured(format, diff); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
 			return
 		}
 
-		fmt.Print(diff)
+		if !summaryOnly && len(diff.Entries) > 0 {
+			headers := []string{"Change", "Path", "Old Size", "New Size", "Delta"}
+			var rows [][]string
+			for _, e := range diff.Entries {
+				rows = append(rows, []string{
+					changeSymbol(e.Change),
+					e.Path,
+					sizeOrDash(e.OldSize, e.Change != manager.ChangeAdded),
+					sizeOrDash(e.NewSize, e.Change != manager.ChangeRemoved),
+					ui.FormatSizeDelta(e.SizeDelta),
+				})
+			}
+			title := fmt.Sprintf("%s → %s", diff.From.ID, diff.To.ID)
+			fmt.Println(ui.RenderTable(title, headers, rows))
+		}
+
+		if len(diff.Entries) == 0 {
+			ui.Info("No differences found.")
+			return
+		}
+
+		ui.Summaryf("%d added, %d removed, %d modified, size delta %s",
+			diff.Added, diff.Removed, diff.Modified, ui.FormatSizeDelta(diff.SizeDelta))
 	},
 }
 
+// changeSymbol renders a diff change kind for the table
+func changeSymbol(change string) string {
+	switch change {
+	case manager.ChangeAdded:
+		return ui.SuccessStyle.Render("+ added")
+	case manager.ChangeRemoved:
+		return ui.ErrorStyle.Render("- removed")
+	default:
+		return ui.WarningStyle.Render("~ modified")
+	}
+}
+
+// sizeOrDash formats a size, or "-" if the file does not exist on that side
+func sizeOrDash(size int64, exists bool) string {
+	if !exists {
+		return "-"
+	}
+	return ui.FormatSize(size)
+}
+
 func init() {
 	DiffCmd.Flags().StringP("host", "H", "", "Filter snapshots by hostname")
 	DiffCmd.Flags().StringP("user", "U", "", "Filter snapshots by username")
+	DiffCmd.Flags().String("path", "", "Only compare entries at or below this path, or matching this glob")
+	DiffCmd.Flags().BoolP("summary", "s", false, "Only show counts and total size delta")
+	DiffCmd.Flags().BoolP("json", "j", false, "Output as JSON (same as --output json)")
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
 
 	"charm.land/log/v2"
 	"github.com/spf13/cobra"
@@ -18,6 +19,17 @@ var EstimateCmd = &cobra.Command{
 		km := manager.NewKopiaManager()
 		uploadSpeed, _ := cmd.Flags().GetInt("upload-speed")
 
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			estimates, err := km.EstimateBackups(args, uploadSpeed)
+			if err != nil {
+				log.Fatal("Failed to estimate backup size", "error", err)
+			}
+			if err := ui.PrintStructured(format, estimates); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			return
+		}
+
 		estimate, err := km.EstimateBackupSize(args, uploadSpeed)
 		if err != nil {
 			log.Fatal("Failed to estimate backup size", "error", err)
@@ -0,0 +1,86 @@
+package snapshot
+
+import (
+	"fmt"
+
+	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
+
+	"charm.land/log/v2"
+	"github.com/spf13/cobra"
+)
+
+// FindCmd searches snapshots for files and directories
+var FindCmd = &cobra.Command{
+	Use:   "find [pattern]",
+	Short: "Find files across all snapshots",
+	Long: `Search the entries of every snapshot for a name or path pattern.
+
+A pattern without a slash is matched against file and directory names
+anywhere in the snapshot. A pattern with a slash is a glob matched against
+the whole path, either relative to the snapshot root or absolute below the
+backed up directory.
+
+Examples:
+  km find nginx.conf                       # Every snapshot containing a file named nginx.conf
+  km find '*.sqlite' --source /home/alice  # Only snapshots of /home/alice
+  km find /etc/foo                         # Which snapshots contain /etc/foo
+  km find 'etc/*/conf.d' --json            # Machine-readable output`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		km := manager.NewKopiaManager()
+
+		matches, err := km.FindEntries(args[0], findOptions(cmd))
+		if err != nil {
+			log.Fatal("Find failed", "error", err)
+		}
+
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			if err := ui.PrintStructured(format, matches); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			return
+		}
+
+		if len(matches) == 0 {
+			ui.Info("No matching entries found.")
+			return
+		}
+
+		headers := []string{"Path", "Snapshot", "Time", "Size", "Modified"}
+		var rows [][]string
+		paths := make(map[string]bool)
+		for _, m := range matches {
+			name := m.Path
+			if m.IsDir {
+				name += "/"
+			}
+			paths[m.Path] = true
+			rows = append(rows, []string{
+				name,
+				m.Snapshot.ID,
+				m.Snapshot.StartTime.Format("2006-01-02 15:04:05"),
+				ui.FormatSize(m.Size),
+				m.ModTime.Format("2006-01-02 15:04:05"),
+			})
+		}
+		fmt.Println(ui.RenderTable(fmt.Sprintf("Entries matching '%s'", args[0]), headers, rows))
+		ui.Summaryf("%d matches of %d paths", len(matches), len(paths))
+	},
+}
+
+// findOptions reads the snapshot selection flags shared by find and history
+func findOptions(cmd *cobra.Command) manager.FindOptions {
+	var opts manager.FindOptions
+	opts.Source, _ = cmd.Flags().GetString("source")
+	opts.Hostname, _ = cmd.Flags().GetString("host")
+	opts.Username, _ = cmd.Flags().GetString("user")
+	return opts
+}
+
+func init() {
+	FindCmd.Flags().StringP("host", "H", "", "Filter snapshots by hostname")
+	FindCmd.Flags().StringP("user", "U", "", "Filter snapshots by username")
+	FindCmd.Flags().String("source", "", "Only search snapshots of this backed up path")
+	FindCmd.Flags().BoolP("json", "j", false, "Output as JSON (same as --output json)")
+}
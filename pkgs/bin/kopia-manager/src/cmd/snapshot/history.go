@@ -0,0 +1,119 @@
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
+
+	"charm.land/log/v2"
+	"github.com/spf13/cobra"
+)
+
+// HistoryCmd lists the versions of a file across snapshots
+var HistoryCmd = &cobra.Command{
+	Use:   "history [path]",
+	Short: "Show every version of a file across snapshots",
+	Long: `List every distinct version of a file across the snapshots of the backup
+source containing it, with the snapshots and times each version was seen.
+
+The path is absolute, or relative to the snapshot root when only one source
+matches (narrow it down with --source, --host and --user). A version that
+comes back after a change is listed again as a new version.
+
+Use --cat N to print version N, or --restore N to write it to --target
+(default: the file name in the current directory).
+
+Examples:
+  km history /etc/nginx/nginx.conf                        # List versions
+  km history /etc/nginx/nginx.conf --cat 3                # Print version 3
+  km history /etc/nginx/nginx.conf --restore 2            # Write version 2 to ./nginx.conf
+  km history /etc/nginx/nginx.conf --restore 2 --target /etc/nginx/nginx.conf --force`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		km := manager.NewKopiaManager()
+		catVersion, _ := cmd.Flags().GetInt("cat")
+		restoreVersion, _ := cmd.Flags().GetInt("restore")
+		target, _ := cmd.Flags().GetString("target")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if catVersion > 0 && restoreVersion > 0 {
+			log.Fatal("Use either --cat or --restore, not both")
+		}
+
+		history, err := km.FileHistory(args[0], findOptions(cmd))
+		if err != nil {
+			log.Fatal("Failed to get file history", "error", err)
+		}
+
+		switch {
+		case catVersion > 0:
+			version := selectVersion(history, catVersion)
+			if err := km.CatFile(version.FirstSeen.ID, history.FullPath(), os.Stdout); err != nil {
+				log.Fatal("Failed to read file", "error", err)
+			}
+			return
+
+		case restoreVersion > 0:
+			version := selectVersion(history, restoreVersion)
+			if target == "" {
+				target = path.Base(history.FullPath())
+			} else if info, err := os.Stat(target); err == nil && info.IsDir() {
+				target = filepath.Join(target, path.Base(history.FullPath()))
+			}
+			if err := km.RestoreFile(version.FirstSeen.ID, history.FullPath(), target, force); err != nil {
+				log.Fatal("Restore failed", "error", err)
+			}
+			ui.Successf("Restored version %d of %s to %s", version.Version, history.FullPath(), target)
+			return
+		}
+
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			if err := ui.PrintStructured(format, history); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			return
+		}
+
+		headers := []string{"Version", "Snapshot", "First Seen", "Last Seen", "Seen In", "Size"}
+		var rows [][]string
+		for _, v := range history.Versions {
+			rows = append(rows, []string{
+				fmt.Sprintf("%d", v.Version),
+				v.FirstSeen.ID,
+				v.FirstSeen.StartTime.Format("2006-01-02 15:04:05"),
+				v.LastSeen.StartTime.Format("2006-01-02 15:04:05"),
+				fmt.Sprintf("%d snapshots", v.Snapshots),
+				ui.FormatSize(v.Size),
+			})
+		}
+		fmt.Println(ui.RenderTable(history.FullPath(), headers, rows))
+
+		ui.Summaryf("%d versions", len(history.Versions))
+		if history.Missing > 0 {
+			ui.Notef("Missing from %d snapshots of %s", history.Missing, history.Source)
+		}
+	},
+}
+
+// selectVersion returns version n of the history or exits if it doesn't exist
+func selectVersion(history *manager.FileHistory, n int) manager.FileVersion {
+	if n > len(history.Versions) {
+		log.Fatal("No such version", "version", n, "versions", len(history.Versions))
+	}
+	return history.Versions[n-1]
+}
+
+func init() {
+	HistoryCmd.Flags().StringP("host", "H", "", "Filter snapshots by hostname")
+	HistoryCmd.Flags().StringP("user", "U", "", "Filter snapshots by username")
+	HistoryCmd.Flags().String("source", "", "Backed up path containing the file")
+	HistoryCmd.Flags().Int("cat", 0, "Print the content of this version")
+	HistoryCmd.Flags().Int("restore", 0, "Restore this version")
+	HistoryCmd.Flags().String("target", "", "File or directory to restore to (default: file name in the current directory)")
+	HistoryCmd.Flags().Bool("force", false, "Overwrite the target if it exists")
+	HistoryCmd.Flags().BoolP("json", "j", false, "Output as JSON (same as --output json)")
+}
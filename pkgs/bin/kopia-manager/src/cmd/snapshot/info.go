@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
 
 	"charm.land/log/v2"
 	"github.com/spf13/cobra"
@@ -18,6 +19,17 @@ var InfoCmd = &cobra.Command{
 		km := manager.NewKopiaManager()
 		snapshotID := args[0]
 
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			details, err := km.SnapshotDetails(snapshotID)
+			if err != nil {
+				log.Fatal("Failed to get snapshot info", "error", err)
+			}
+			if err := ui.PrintStructured(format, details); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			return
+		}
+
 		info, err := km.GetSnapshotInfo(snapshotID)
 		if err != nil {
 			log.Error("Failed to get snapshot info", "error", err)
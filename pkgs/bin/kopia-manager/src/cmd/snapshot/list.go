@@ -22,6 +22,7 @@ var ListCmd = &cobra.Command{
 		all, _ := cmd.Flags().GetBool("all")
 		host, _ := cmd.Flags().GetString("host")
 		user, _ := cmd.Flags().GetString("user")
+		tags, _ := cmd.Flags().GetStringArray("tag")
 		km := manager.NewKopiaManager()
 
 		// Determine which hostname to filter by
@@ -46,8 +47,30 @@ var ListCmd = &cobra.Command{
 			log.Fatal("Failed to list snapshots", "error", err)
 		}
 
+		if len(tags) > 0 {
+			filtered := snapshots[:0]
+			for _, snap := range snapshots {
+				if snap.MatchesTags(tags) {
+					filtered = append(filtered, snap)
+				}
+			}
+			snapshots = filtered
+		}
+
+		if format := ui.OutputFormat(cmd); format != ui.OutputText {
+			sort.Slice(snapshots, func(i, j int) bool {
+				return snapshots[i].StartTime.After(snapshots[j].StartTime)
+			})
+			if err := ui.PrintStructured(format, snapshots); err != nil {
+				log.Fatal("Failed to write output", "error", err)
+			}
+			return
+		}
+
 		if len(snapshots) == 0 {
-			if hostname != "" {
+			if len(tags) > 0 {
+				ui.Infof("No snapshots found with tag %s.", strings.Join(tags, ", "))
+			} else if hostname != "" {
 				ui.Infof("No snapshots found for host '%s'. Use --all to see snapshots from other hosts.", hostname)
 			} else {
 				ui.Info("No snapshots found.")
@@ -87,7 +110,7 @@ var ListCmd = &cobra.Command{
 			}
 			title := fmt.Sprintf("%s @ %s (%d snapshot%s)", backupName, hostUserKey, len(snaps), pluralSuffix)
 
-			headers := []string{"ID", "Path", "Time", "Size"}
+			headers := []string{"ID", "Path", "Time", "Size", "Tags"}
 			var rows [][]string
 
 			for _, snap := range snaps {
@@ -96,6 +119,7 @@ var ListCmd = &cobra.Command{
 					ui.ShortenPath(snap.Source),
 					snap.StartTime.Format("2006-01-02 15:04:05"),
 					ui.FormatSize(snap.TotalSize),
+					snapshotLabels(snap),
 				})
 			}
 
@@ -104,8 +128,19 @@ var ListCmd = &cobra.Command{
 	},
 }
 
+// snapshotLabels renders the pins and tags of a snapshot for the list table
+func snapshotLabels(snap manager.SnapshotSummary) string {
+	var labels []string
+	if len(snap.Pins) > 0 {
+		labels = append(labels, ui.WarningStyle.Render("pinned"))
+	}
+	labels = append(labels, snap.TagList()...)
+	return strings.Join(labels, ", ")
+}
+
 func init() {
 	ListCmd.Flags().BoolP("all", "A", false, "Show snapshots from all hosts")
 	ListCmd.Flags().StringP("host", "H", "", "Filter snapshots by hostname")
 	ListCmd.Flags().StringP("user", "U", "", "Filter snapshots by username")
+	ListCmd.Flags().StringArray("tag", nil, "Only list snapshots with this tag, as key or key:value (repeatable)")
 }
@@ -0,0 +1,81 @@
+package snapshot
+
+import (
+	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
+
+	"charm.land/log/v2"
+	"github.com/spf13/cobra"
+)
+
+// PinCmd pins a snapshot so retention never expires it
+var PinCmd = &cobra.Command{
+	Use:   "pin [snapshot-id]",
+	Short: "Pin a snapshot to protect it from retention",
+	Long: `Pin a snapshot so that retention policies never expire it and
+"km delete --all" skips it. A snapshot stays pinned while it has at least
+one pin reason.
+
+Examples:
+  km pin 12e9406f                              # Pin with the default reason
+  km pin 12e9406f --reason before-upgrade      # Pin with a reason`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		km := manager.NewKopiaManager()
+		reasons, _ := cmd.Flags().GetStringArray("reason")
+
+		update, err := km.PinSnapshot(args[0], reasons)
+		if err != nil {
+			log.Fatal("Pin failed", "error", err)
+		}
+		reportSnapshotUpdate(cmd, "Pinned", update)
+	},
+}
+
+// UnpinCmd removes pins from a snapshot
+var UnpinCmd = &cobra.Command{
+	Use:   "unpin [snapshot-id]",
+	Short: "Remove pins from a snapshot",
+	Long: `Remove pin reasons from a snapshot, or all of them when no --reason is
+given, so that retention policies apply to it again.
+
+Examples:
+  km unpin 12e9406f                            # Remove all pins
+  km unpin 12e9406f --reason before-upgrade    # Remove one pin`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		km := manager.NewKopiaManager()
+		reasons, _ := cmd.Flags().GetStringArray("reason")
+
+		update, err := km.UnpinSnapshot(args[0], reasons)
+		if err != nil {
+			log.Fatal("Unpin failed", "error", err)
+		}
+		reportSnapshotUpdate(cmd, "Unpinned", update)
+	},
+}
+
+// reportSnapshotUpdate prints the outcome of a snapshot metadata change
+func reportSnapshotUpdate(cmd *cobra.Command, action string, update *manager.SnapshotUpdate) {
+	if format := ui.OutputFormat(cmd); format != ui.OutputText {
+		if err := ui.PrintStructured(format, update); err != nil {
+			log.Fatal("Failed to write output", "error", err)
+		}
+		return
+	}
+
+	if !update.Changed {
+		ui.Infof("Snapshot %s is unchanged.", update.PreviousID)
+		return
+	}
+
+	ui.Successf("%s snapshot %s", action, update.Snapshot.ID)
+	if update.PreviousID != update.Snapshot.ID {
+		ui.Notef("Snapshot ID changed from %s", update.PreviousID)
+	}
+}
+
+func init() {
+	PinCmd.Flags().StringArray("reason", nil, "Pin reason (repeatable, default \""+manager.DefaultPinReason+"\")")
+	UnpinCmd.Flags().StringArray("reason", nil, "Pin reason to remove (repeatable, default: all)")
+}
@@ -1,7 +1,11 @@
 package snapshot
 
 import (
+	"fmt"
+	"strings"
+
 	"kopia-manager/internal/manager"
+	"kopia-manager/internal/ui"
 
 	"charm.land/log/v2"
 	"github.com/spf13/cobra"
@@ -9,43 +13,140 @@ import (
 
 // RestoreCmd restores a specific snapshot or an entire backup group
 var RestoreCmd = &cobra.Command{
-	Use:   "restore [snapshot-id-or-backup-name] [target-directory]",
+	Use:   "restore [snapshot-id-or-backup-name] [target-directory] [paths...]",
 	Short: "Restore a snapshot or latest snapshots from a backup group to target directory",
 	Long: `Restore a snapshot or backup group to a target directory.
 
 Without --all flag: Restores a specific snapshot by ID
 With --all flag: Restores all snapshots from a backup group
 
+Paths after the target directory restore only those entries of the snapshot.
+They are relative to the snapshot root (absolute paths below the backed up
+directory work too) and may be glob patterns; directories are restored with
+everything below them.
+
+Use --dry-run to list what would be written and which existing files in the
+target directory would be overwritten.
+
+By default owners and permissions are not restored and existing files are
+overwritten. Use --preserve-owners (usually needs root) and --preserve-perms
+for full system restores, and --no-overwrite to keep existing files. With
+either flag, owners or permissions that cannot be set fail the restore.
+--shallow N writes Kopia placeholder files for entries more than N levels
+below the target instead of their content.
+
+A target ending in .tar, .tar.gz/.tgz or .zip is written as an archive
+instead of a directory; --format overrides the detection.
+
 Examples:
-  km restore 12e9406f405955816e93 /restore/path    # Restore specific snapshot
-  km restore --all downloads /restore/path        # Restore backup group
+  km restore 12e9406f405955816e93 /restore/path              # Restore specific snapshot
+  km restore 12e9406f /restore/path etc/nginx               # Restore one directory
+  km restore 12e9406f /restore/path 'docs/*.md' notes.txt   # Restore matching files
+  km restore 12e9406f /restore/path etc --dry-run           # Show what would be restored
+  km restore 12e9406f / --preserve-owners --preserve-perms  # Full system restore
+  km restore 12e9406f /restore/path --no-overwrite          # Keep existing files
+  km restore 12e9406f home.tar.gz home/alice                # Write a tar.gz archive
+  km restore --all downloads /restore/path                  # Restore backup group
 
 Tab completion:
   - Without --all: Shows snapshot IDs
   - With --all: Shows backup group names`,
-	Args: cobra.ExactArgs(2),
+	Args: cobra.MinimumNArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		km := manager.NewKopiaManager()
 		identifier := args[0]
 		targetDir := args[1]
 		restoreAll, _ := cmd.Flags().GetBool("all")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		opts := manager.RestoreOptions{Paths: args[2:]}
+		opts.PreserveOwners, _ = cmd.Flags().GetBool("preserve-owners")
+		opts.PreservePermissions, _ = cmd.Flags().GetBool("preserve-perms")
+		opts.NoOverwrite, _ = cmd.Flags().GetBool("no-overwrite")
+		opts.Parallel, _ = cmd.Flags().GetInt("parallel")
+		opts.Format, _ = cmd.Flags().GetString("format")
+		if cmd.Flags().Changed("shallow") {
+			opts.Shallow = true
+			opts.ShallowDepth, _ = cmd.Flags().GetInt32("shallow")
+		}
+
+		if format := ui.OutputFormat(cmd); format != ui.OutputText && !dryRun {
+			log.Fatal("Restore failed", "error", fmt.Sprintf("--output %s is only supported with --dry-run", format))
+		}
 
 		if restoreAll {
+			if dryRun {
+				log.Fatal("Restore failed", "error", "--dry-run is only supported when restoring a single snapshot")
+			}
 			// Restore all snapshots from a backup group
-			if err := km.RestoreBackupGroup(identifier, targetDir); err != nil {
+			if err := km.RestoreBackupGroup(identifier, targetDir, opts); err != nil {
 				log.Fatal("Restore failed", "error", err)
 			}
-		} else {
-			// Restore single snapshot
-			if err := km.RestoreSnapshot(identifier, targetDir); err != nil {
+			return
+		}
+
+		if dryRun {
+			plan, err := km.PlanRestore(identifier, targetDir, opts)
+			if err != nil {
 				log.Fatal("Restore failed", "error", err)
 			}
+			if format := ui.OutputFormat(cmd); format != ui.OutputText {
+				if err := ui.PrintStructured(format, plan); err != nil {
+					log.Fatal("Failed to write output", "error", err)
+				}
+				return
+			}
+			printRestorePlan(plan)
+			return
+		}
+
+		// Restore single snapshot
+		if err := km.RestoreSnapshot(identifier, targetDir, opts); err != nil {
+			log.Fatal("Restore failed", "error", err)
 		}
 	},
 }
 
+// printRestorePlan renders the files a restore would write
+func printRestorePlan(plan *manager.RestorePlan) {
+	if len(plan.Entries) == 0 {
+		ui.Info("No entries match the given paths.")
+		return
+	}
+
+	headers := []string{"Action", "Path", "Size"}
+	var rows [][]string
+	for _, e := range plan.Entries {
+		rows = append(rows, []string{restoreActionLabel(e.Action), e.Path, ui.FormatSize(e.Size)})
+	}
+	title := fmt.Sprintf("Restore %s → %s (dry run)", plan.Snapshot.ID, plan.TargetDir)
+	fmt.Println(ui.RenderTable(title, headers, rows))
+
+	created := len(plan.Entries) - plan.Overwrites - plan.Skipped
+	ui.Summaryf("%d files selected, %s (%d new, %d overwritten, %d kept)",
+		len(plan.Entries), ui.FormatSize(plan.TotalSize), created, plan.Overwrites, plan.Skipped)
+}
+
+// restoreActionLabel renders a restore plan action for the table
+func restoreActionLabel(action string) string {
+	switch action {
+	case manager.RestoreActionOverwrite:
+		return ui.WarningStyle.Render("overwrite")
+	case manager.RestoreActionSkip:
+		return ui.NoteStyle.Render("keep")
+	default:
+		return ui.SuccessStyle.Render("create")
+	}
+}
+
 func init() {
 	RestoreCmd.Flags().BoolP("all", "a", false, "Restore all snapshots from backup group")
+	RestoreCmd.Flags().BoolP("dry-run", "n", false, "List what would be restored without writing anything")
+	RestoreCmd.Flags().Bool("preserve-owners", false, "Restore file owners (usually requires root)")
+	RestoreCmd.Flags().Bool("preserve-perms", false, "Restore file permissions")
+	RestoreCmd.Flags().Bool("no-overwrite", false, "Keep files that already exist in the target")
+	RestoreCmd.Flags().Int("parallel", manager.DefaultRestoreParallel, "Number of files to restore concurrently")
+	RestoreCmd.Flags().Int32("shallow", 0, "Write placeholders for entries more than this many levels below the target")
+	RestoreCmd.Flags().String("format", manager.RestoreFormatAuto, "Output format: "+strings.Join(manager.RestoreFormats, ", "))
 	RestoreCmd.Flags().StringP("host", "H", "", "Filter snapshots by hostname")
 	RestoreCmd.Flags().StringP("user", "U", "", "Filter snapshots by username")
 }
@@ -0,0 +1,58 @@
+package snapshot
+
+import (
+	"kopia-manager/internal/manager"
+
+	"charm.land/log/v2"
+	"github.com/spf13/cobra"
+)
+
+// TagCmd groups the commands that change snapshot tags
+var TagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Add or remove snapshot tags",
+	Long: `Tags are key:value labels on a snapshot; the value may be empty. They are
+shown by "km list" and select snapshots with "km list --tag" and
+"km delete --all --tag".
+
+Examples:
+  km tag add 12e9406f reason:pre-upgrade keep   # Add two tags
+  km tag remove 12e9406f reason                 # Remove a tag by key
+  km list --tag reason:pre-upgrade              # List tagged snapshots`,
+}
+
+// TagAddCmd adds tags to a snapshot
+var TagAddCmd = &cobra.Command{
+	Use:   "add [snapshot-id] [key:value...]",
+	Short: "Add tags to a snapshot, replacing existing values",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		km := manager.NewKopiaManager()
+
+		update, err := km.TagSnapshot(args[0], args[1:])
+		if err != nil {
+			log.Fatal("Tag failed", "error", err)
+		}
+		reportSnapshotUpdate(cmd, "Tagged", update)
+	},
+}
+
+// TagRemoveCmd removes tags from a snapshot
+var TagRemoveCmd = &cobra.Command{
+	Use:   "remove [snapshot-id] [key...]",
+	Short: "Remove tags from a snapshot",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		km := manager.NewKopiaManager()
+
+		update, err := km.UntagSnapshot(args[0], args[1:])
+		if err != nil {
+			log.Fatal("Untag failed", "error", err)
+		}
+		reportSnapshotUpdate(cmd, "Untagged", update)
+	},
+}
+
+func init() {
+	TagCmd.AddCommand(TagAddCmd, TagRemoveCmd)
+}
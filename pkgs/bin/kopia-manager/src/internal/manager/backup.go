@@ -0,0 +1,379 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"kopia-manager/internal/ui"
+
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+// DefaultBackupParallel is the number of paths backed up concurrently by default
+const DefaultBackupParallel = 2
+
+// BackupResult is the outcome of backing up one path. A path with an error may still
+// have a snapshot, e.g. one that is incomplete or misses unreadable files.
+type BackupResult struct {
+	Path        string        `json:"path"`
+	SnapshotID  string        `json:"snapshotId,omitempty"`
+	Incomplete  string        `json:"incomplete,omitempty"`
+	Files       int64         `json:"files"`
+	Size        int64         `json:"size"`
+	NewFiles    int32         `json:"newFiles"`
+	CachedFiles int32         `json:"cachedFiles"`
+	Duration    time.Duration `json:"duration"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// Failed reports whether the path wasn't fully backed up
+func (r BackupResult) Failed() bool {
+	return r.Error != ""
+}
+
+// CreateBackup backs up the specified paths with a progress bar and prints a result
+// table. Failed paths don't stop the others; an error is returned if any failed.
+func (sm *SnapshotManager) CreateBackup(paths []string, opts BackupOptions) error {
+	progress := newBackupProgress(fmt.Sprintf("Backing up %d path(s)", len(paths)))
+	results, err := sm.backupSources(paths, opts, progress)
+	progress.Finish()
+	if err != nil {
+		return err
+	}
+
+	headers := []string{"Path", "Snapshot", "Files", "Size", "New", "Cached", "Time", "Result"}
+	var rows [][]string
+	failed := 0
+	for _, r := range results {
+		result := "ok"
+		if r.Failed() {
+			failed++
+			result = r.Error
+		}
+		snapshotID := r.SnapshotID
+		if snapshotID == "" {
+			snapshotID = "-"
+		}
+		rows = append(rows, []string{
+			ui.ShortenPath(r.Path),
+			snapshotID,
+			fmt.Sprintf("%d", r.Files),
+			ui.FormatSize(r.Size),
+			fmt.Sprintf("%d", r.NewFiles),
+			fmt.Sprintf("%d", r.CachedFiles),
+			ui.FormatDuration(r.Duration),
+			result,
+		})
+	}
+	fmt.Println(ui.RenderTable("Backup Results", headers, rows))
+
+	if failed > 0 {
+		ui.Warningf("Backed up %d/%d paths", len(results)-failed, len(results))
+		return fmt.Errorf("%d of %d paths failed", failed, len(results))
+	}
+	ui.Successf("Backed up %d/%d paths", len(results), len(results))
+	return nil
+}
+
+// BackupSources backs up the specified paths without printing progress
+func (sm *SnapshotManager) BackupSources(paths []string, opts BackupOptions) ([]BackupResult, error) {
+	return sm.backupSources(paths, opts, nil)
+}
+
+// backupSources backs up the paths in a single write session, opts.Parallel at a time.
+// The error is only set if the session itself fails; the results hold per path errors.
+//
+// SIGINT and SIGTERM cancel the uploads; kopia then returns incomplete snapshots,
+// which are saved so the next backup can pick up where this one stopped.
+func (sm *SnapshotManager) backupSources(paths []string, opts BackupOptions, progress *backupProgress) ([]BackupResult, error) {
+	excludes, err := opts.excludeRules()
+	if err != nil {
+		return nil, err
+	}
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = DefaultBackupParallel
+	}
+
+	ctx := context.Background()
+	r, err := sm.km.openRepository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close(ctx)
+
+	cancel := newUploadCanceler()
+	defer cancel.stop()
+
+	sessionOpts := repo.WriteSessionOptions{Purpose: "snapshot"}
+	if progress != nil {
+		sessionOpts.OnUpload = progress.UploadedBytes
+	}
+
+	results := make([]BackupResult, len(paths))
+	err = repo.WriteSession(ctx, r, sessionOpts, func(ctx context.Context, w repo.RepositoryWriter) error {
+		b := &sourceBackup{
+			w:          w,
+			clientOpts: r.ClientOptions(),
+			opts:       opts,
+			excludes:   excludes,
+			cancel:     cancel,
+		}
+
+		// Register every source with the progress first, so the total covers all of them
+		sources := make([]*preparedSource, len(paths))
+		for i, path := range paths {
+			results[i].Path = path
+			sources[i] = b.prepare(ctx, path, progress, &results[i])
+		}
+
+		sem := make(chan struct{}, parallel)
+		var wg sync.WaitGroup
+		for i := range paths {
+			if results[i].Failed() {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				b.run(ctx, sources[i], &results[i])
+			}()
+		}
+		wg.Wait()
+
+		// Failed paths are in the results; keep the snapshots of the others
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// sourceBackup backs up the sources of a write session
+type sourceBackup struct {
+	w          repo.RepositoryWriter
+	clientOpts repo.ClientOptions
+	opts       BackupOptions
+	excludes   []string
+	cancel     *uploadCanceler
+}
+
+// preparedSource is a source with its previous snapshots
+type preparedSource struct {
+	sourceInfo snapshot.SourceInfo
+	previous   []*snapshot.Manifest
+	progress   *sourceProgress
+}
+
+// prepare looks up the source and its previous snapshots; errors are set on result
+func (b *sourceBackup) prepare(ctx context.Context, path string, progress *backupProgress, result *BackupResult) *preparedSource {
+	sourceInfo, err := snapshot.ParseSourceInfo(path, b.clientOpts.Hostname, b.clientOpts.Username)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to parse source info: %v", err)
+		return nil
+	}
+
+	// Files unchanged since the previous snapshots are not hashed again
+	previous, err := snapshot.FindPreviousManifests(ctx, b.w, sourceInfo, nil)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to find previous snapshots: %v", err)
+		return nil
+	}
+
+	return &preparedSource{
+		sourceInfo: sourceInfo,
+		previous:   previous,
+		progress:   progress.source(previous),
+	}
+}
+
+// run backs up a prepared source and saves its snapshot, even a partial one
+func (b *sourceBackup) run(ctx context.Context, src *preparedSource, result *BackupResult) {
+	sourceInfo, progress := src.sourceInfo, src.progress
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+		// A source that fails before its upload no longer counts towards the total
+		progress.finished.Store(true)
+	}()
+
+	// Get or create policy for this path, with the exclusions of this run
+	override, err := policyOverride(ctx, b.w, sourceInfo, b.excludes, b.opts.OneFileSystem)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	policyTree, err := policy.TreeForSourceWithOverride(ctx, b.w, sourceInfo, override)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to get policy: %v", err)
+		return
+	}
+
+	entry, err := localfs.NewEntry(sourceInfo.Path)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to access path: %v", err)
+		return
+	}
+
+	u := upload.NewUploader(b.w)
+	u.Progress = progress
+	if !b.cancel.add(u) {
+		result.Error = "canceled"
+		return
+	}
+	defer b.cancel.remove(u)
+
+	var hookLog []string
+	if b.opts.PreHook != "" {
+		output, err := runHook(ctx, b.opts.PreHook, sourceInfo.Path)
+		if err != nil {
+			result.Error = hookDescription("pre-hook", output, err)
+			return
+		}
+		if output != "" {
+			hookLog = append(hookLog, hookDescription("pre-hook", output, nil))
+		}
+	}
+
+	// Don't start uploading if canceled while the pre-hook ran
+	var manifest *snapshot.Manifest
+	uploadErr := errCanceled
+	if !u.IsCanceled() {
+		manifest, uploadErr = u.Upload(ctx, entry, policyTree, sourceInfo, src.previous...)
+	}
+
+	// The post-hook also runs after a failed upload, e.g. to thaw a frozen filesystem
+	var postErr error
+	if b.opts.PostHook != "" {
+		var output string
+		output, postErr = runHook(ctx, b.opts.PostHook, sourceInfo.Path)
+		if postErr != nil || output != "" {
+			hookLog = append(hookLog, hookDescription("post-hook", output, postErr))
+		}
+	}
+
+	if errors.Is(uploadErr, errCanceled) {
+		result.Error = "canceled"
+		return
+	}
+	if uploadErr != nil {
+		result.Error = fmt.Sprintf("failed to upload: %v", uploadErr)
+		return
+	}
+
+	if b.opts.Description != "" {
+		manifest.Description = b.opts.Description
+	}
+	if len(hookLog) > 0 {
+		// The first line stays the backup name, see extractBackupName
+		manifest.Description = strings.Join(append([]string{manifest.Description}, hookLog...), "\n")
+	}
+	if postErr != nil {
+		if manifest.Tags == nil {
+			manifest.Tags = make(map[string]string)
+		}
+		manifest.Tags[hookTag] = "post-failed"
+	}
+
+	if _, err := snapshot.SaveSnapshot(ctx, b.w, manifest); err != nil {
+		result.Error = fmt.Sprintf("failed to save snapshot: %v", err)
+		return
+	}
+
+	counters := progress.Snapshot()
+	result.SnapshotID = string(manifest.ID)
+	result.Incomplete = manifest.IncompleteReason
+	summary := manifest.RootEntry.DirSummary
+	if summary != nil {
+		result.Files = summary.TotalFileCount
+		result.Size = summary.TotalFileSize
+	}
+	result.NewFiles = counters.TotalHashedFiles
+	result.CachedFiles = counters.TotalCachedFiles
+
+	switch {
+	case manifest.IncompleteReason != "":
+		result.Error = "incomplete snapshot: " + manifest.IncompleteReason
+	case summary != nil && summary.FatalErrorCount > 0:
+		result.Error = fmt.Sprintf("%d entries could not be read", summary.FatalErrorCount)
+		if len(summary.FailedEntries) > 0 {
+			result.Error += fmt.Sprintf(", e.g. %s: %s", summary.FailedEntries[0].EntryPath, summary.FailedEntries[0].Error)
+		}
+	case postErr != nil:
+		result.Error = fmt.Sprintf("post-hook failed: %v", postErr)
+	}
+}
+
+// errCanceled is the upload error of a source canceled before its upload started
+var errCanceled = errors.New("canceled")
+
+// uploadCanceler cancels the running uploads on SIGINT or SIGTERM. A second signal
+// gets the default handling and ends the process.
+type uploadCanceler struct {
+	signals chan os.Signal
+	done    chan struct{}
+
+	mu        sync.Mutex
+	canceled  bool
+	uploaders map[*upload.Uploader]struct{}
+}
+
+func newUploadCanceler() *uploadCanceler {
+	c := &uploadCanceler{
+		signals:   make(chan os.Signal, 1),
+		done:      make(chan struct{}),
+		uploaders: make(map[*upload.Uploader]struct{}),
+	}
+	signal.Notify(c.signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-c.signals:
+			signal.Stop(c.signals)
+			c.mu.Lock()
+			c.canceled = true
+			for u := range c.uploaders {
+				u.Cancel()
+			}
+			c.mu.Unlock()
+		case <-c.done:
+		}
+	}()
+	return c
+}
+
+// add registers a running upload; it returns false once the backup was canceled
+func (c *uploadCanceler) add(u *upload.Uploader) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.canceled {
+		return false
+	}
+	c.uploaders[u] = struct{}{}
+	return true
+}
+
+func (c *uploadCanceler) remove(u *upload.Uploader) {
+	c.mu.Lock()
+	delete(c.uploaders, u)
+	c.mu.Unlock()
+}
+
+func (c *uploadCanceler) stop() {
+	signal.Stop(c.signals)
+	close(c.done)
+}
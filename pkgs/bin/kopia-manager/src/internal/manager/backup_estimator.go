@@ -23,6 +23,17 @@ func NewBackupEstimator(km *KopiaManager) *BackupEstimator {
 	return &BackupEstimator{km: km}
 }
 
+// EstimateBackups estimates the size of a backup for each path without printing progress.
+// Paths that can't be fully read are returned with Error set.
+func (be *BackupEstimator) EstimateBackups(paths []string, uploadSpeed int) ([]BackupEstimate, error) {
+	ctx := context.Background()
+	estimates := make([]BackupEstimate, 0, len(paths))
+	for _, path := range paths {
+		estimates = append(estimates, be.estimate(ctx, path, uploadSpeed))
+	}
+	return estimates, nil
+}
+
 // EstimateBackupSize estimates the size of a backup for given paths
 func (be *BackupEstimator) EstimateBackupSize(paths []string, uploadSpeed int) (string, error) {
 	ctx := context.Background()
@@ -34,38 +45,55 @@ func (be *BackupEstimator) EstimateBackupSize(paths []string, uploadSpeed int) (
 		spin := ui.NewSpinner(fmt.Sprintf("Calculating size for path [%d/%d]: %s", i+1, len(paths), ui.ShortenPath(path)))
 		spin.Start()
 
-		totalSize, totalFiles, err := be.calculatePathSize(ctx, path)
+		estimate := be.estimate(ctx, path, uploadSpeed)
 
-		if err != nil {
+		if estimate.Error != "" {
 			spin.Fail(fmt.Sprintf("Failed to calculate size for %s", ui.ShortenPath(path)))
-			log.Warn("Failed to fully calculate size for path", "path", path, "error", err)
+			log.Warn("Failed to fully calculate size for path", "path", path, "error", estimate.Error)
 			log.Info("Continuing with partial results")
 			// Add a row with error indication
 			rows = append(rows, []string{path, "Error", "N/A", "N/A", "N/A"})
 			continue
 		}
 
-		spin.Success(fmt.Sprintf("Calculated: %s, %d files", ui.FormatSize(totalSize), totalFiles))
-
-		// Estimate upload time based on upload speed (in MB/s)
-		var estimatedTime time.Duration
-		if uploadSpeed > 0 {
-			estimatedSeconds := totalSize / (int64(uploadSpeed) * 1024 * 1024)
-			estimatedTime = time.Duration(estimatedSeconds) * time.Second
-		}
+		spin.Success(fmt.Sprintf("Calculated: %s, %d files", ui.FormatSize(estimate.TotalSize), estimate.TotalFiles))
 
 		rows = append(rows, []string{
 			path,
-			fmt.Sprintf("%d", totalFiles),
-			ui.FormatSize(totalSize),
-			ui.FormatSize(totalSize) + " (no dedup)",
-			fmt.Sprintf("%v", estimatedTime),
+			fmt.Sprintf("%d", estimate.TotalFiles),
+			ui.FormatSize(estimate.TotalSize),
+			ui.FormatSize(estimate.EstimatedUploadSize) + " (no dedup)",
+			fmt.Sprintf("%v", estimate.EstimatedTime),
 		})
 	}
 
 	return ui.RenderTable("Backup Size Estimates", headers, rows), nil
 }
 
+// estimate calculates the size of one path and the time to upload it
+func (be *BackupEstimator) estimate(ctx context.Context, path string, uploadSpeed int) BackupEstimate {
+	estimate := BackupEstimate{Path: path}
+
+	totalSize, totalFiles, err := be.calculatePathSize(ctx, path)
+	if err != nil {
+		estimate.Error = err.Error()
+		return estimate
+	}
+
+	estimate.TotalSize = totalSize
+	estimate.TotalFiles = totalFiles
+	// Without deduplication information the whole size is uploaded
+	estimate.EstimatedUploadSize = totalSize
+
+	// Estimate upload time based on upload speed (in MB/s)
+	if uploadSpeed > 0 {
+		estimatedSeconds := totalSize / (int64(uploadSpeed) * 1024 * 1024)
+		estimate.EstimatedTime = time.Duration(estimatedSeconds) * time.Second
+	}
+
+	return estimate
+}
+
 // calculatePathSize recursively calculates the total size and file count for a path
 func (be *BackupEstimator) calculatePathSize(ctx context.Context, path string) (int64, int64, error) {
 	entry, err := localfs.NewEntry(path)
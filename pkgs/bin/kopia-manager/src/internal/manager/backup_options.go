@@ -0,0 +1,119 @@
+package manager
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// maxHookOutput limits how much hook output is kept in the snapshot description
+const maxHookOutput = 1024
+
+// hookTag marks a snapshot whose post-hook failed, e.g. "km list --tag hook:post-failed"
+const hookTag = snapshotTagPrefix + "hook"
+
+// BackupOptions adjusts a single backup run. The stored policies are left unchanged.
+type BackupOptions struct {
+	Description string
+	// Exclude adds ignore rules in .kopiaignore syntax, relative to each source
+	Exclude []string
+	// ExcludeFiles are files with one ignore rule per line; '#' starts a comment
+	ExcludeFiles []string
+	// OneFileSystem doesn't cross filesystem boundaries
+	OneFileSystem bool
+	// Parallel is the number of paths backed up concurrently, DefaultBackupParallel if zero
+	Parallel int
+	// PreHook runs before each source is uploaded; if it fails the source is skipped
+	PreHook string
+	// PostHook runs after each source is uploaded, even if the upload failed; if it
+	// fails the snapshot is kept but tagged hook:post-failed
+	PostHook string
+}
+
+// excludeRules returns the ignore rules of Exclude and ExcludeFiles
+func (o BackupOptions) excludeRules() ([]string, error) {
+	rules := slices.Clone(o.Exclude)
+	for _, path := range o.ExcludeFiles {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read exclude file: %w", err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			rules = append(rules, line)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read exclude file %s: %w", path, err)
+		}
+	}
+	return rules, nil
+}
+
+// policyOverride returns the policy defined for the source with the exclusions added,
+// or nil if there is nothing to override. Parent and subdirectory policies still apply.
+func policyOverride(ctx context.Context, r repo.Repository, si snapshot.SourceInfo, excludes []string, oneFileSystem bool) (*policy.Policy, error) {
+	if len(excludes) == 0 && !oneFileSystem {
+		return nil, nil
+	}
+
+	pol, err := policy.GetDefinedPolicy(ctx, r, si)
+	if errors.Is(err, policy.ErrPolicyNotFound) {
+		pol = &policy.Policy{}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get policy: %w", err)
+	}
+
+	// Ignore rules are not merged: the first list in the hierarchy wins, so the override
+	// starts from the effective rules to keep those of the global, host and user policies
+	effective, _, _, err := policy.GetEffectivePolicy(ctx, r, si)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy: %w", err)
+	}
+	pol.FilesPolicy.IgnoreRules = append(slices.Clone(effective.FilesPolicy.IgnoreRules), excludes...)
+	if oneFileSystem {
+		pol.FilesPolicy.OneFileSystem = policy.NewOptionalBool(true)
+	}
+	return pol, nil
+}
+
+// runHook runs a hook command for a source and returns its combined output.
+// Like kopia's snapshot actions it runs in the source directory, with the source
+// path in $KOPIA_SOURCE_PATH.
+func runHook(ctx context.Context, command, sourcePath string) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = sourcePath
+	cmd.Env = append(os.Environ(), "KOPIA_SOURCE_PATH="+sourcePath)
+	output, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(output)), err
+}
+
+// hookDescription formats hook output for the snapshot description, keeping its end
+func hookDescription(name, output string, err error) string {
+	if len(output) > maxHookOutput {
+		output = "..." + output[len(output)-maxHookOutput:]
+	}
+
+	line := name
+	if err != nil {
+		line += fmt.Sprintf(" failed (%v)", err)
+	}
+	if output != "" {
+		line += ": " + output
+	}
+	return line
+}
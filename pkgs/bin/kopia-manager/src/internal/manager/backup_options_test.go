@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBackupExcludeKeepsPolicyIgnoreRules(t *testing.T) {
+	km := newTestManager(t)
+	if _, err := km.SetPolicy("global", PolicyChanges{AddIgnore: []string{"*.tmp"}}); err != nil {
+		t.Fatalf("SetPolicy() error: %v", err)
+	}
+
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "notes.txt"), "keep")
+	writeFile(t, filepath.Join(src, "scratch.tmp"), "ignored by the global policy")
+	writeFile(t, filepath.Join(src, "build", "out.o"), "excluded for this run")
+	id := backup(t, km, src, BackupOptions{Exclude: []string{"build/"}})
+
+	plan, err := km.PlanRestore(id, t.TempDir(), RestoreOptions{})
+	if err != nil {
+		t.Fatalf("PlanRestore() error: %v", err)
+	}
+	var got []string
+	for _, e := range plan.Entries {
+		got = append(got, e.Path)
+	}
+	if want := []string{"notes.txt"}; !slices.Equal(got, want) {
+		t.Errorf("snapshot files = %v, want %v", got, want)
+	}
+}
+
+func TestBackupHooks(t *testing.T) {
+	km := newTestManager(t)
+
+	tests := []struct {
+		name     string
+		pre      string
+		post     string
+		wantErr  string
+		wantSnap bool
+		// wantDescription may refer to the source directory as $SRC
+		wantDescription string
+		wantTags        map[string]string
+	}{
+		{name: "successful hooks", pre: "true", post: "true", wantSnap: true},
+		{name: "failing pre-hook skips the source", pre: "exit 1", post: "true", wantErr: "pre-hook failed"},
+		{name: "failing post-hook tags the snapshot", pre: "true", post: "echo thaw; exit 1", wantErr: "post-hook failed", wantSnap: true,
+			wantDescription: "\npost-hook failed (exit status 1): thaw", wantTags: map[string]string{"hook": "post-failed"}},
+		{name: "source path and working directory", pre: `echo "$KOPIA_SOURCE_PATH"; pwd`, wantSnap: true,
+			wantDescription: "\npre-hook: $SRC\n$SRC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := t.TempDir()
+			writeFile(t, filepath.Join(src, "data.txt"), "data")
+
+			results, err := km.BackupSources([]string{src}, BackupOptions{PreHook: tt.pre, PostHook: tt.post})
+			if err != nil {
+				t.Fatalf("BackupSources() error: %v", err)
+			}
+			result := results[0]
+			if tt.wantErr == "" && result.Failed() || !strings.Contains(result.Error, tt.wantErr) {
+				t.Fatalf("backup error = %q, want %q", result.Error, tt.wantErr)
+			}
+			if (result.SnapshotID != "") != tt.wantSnap {
+				t.Fatalf("snapshot ID = %q, want a snapshot: %v", result.SnapshotID, tt.wantSnap)
+			}
+			if !tt.wantSnap {
+				return
+			}
+
+			snapshots, err := km.ListSnapshots("", "")
+			if err != nil {
+				t.Fatalf("ListSnapshots() error: %v", err)
+			}
+			i := slices.IndexFunc(snapshots, func(s SnapshotSummary) bool { return s.ID == result.SnapshotID })
+			if i < 0 {
+				t.Fatalf("snapshot %s not listed", result.SnapshotID)
+			}
+			snap := snapshots[i]
+			if want := strings.ReplaceAll(tt.wantDescription, "$SRC", src); snap.Description != want {
+				t.Errorf("description = %q, want %q", snap.Description, want)
+			}
+			if !maps.Equal(snap.Tags, tt.wantTags) {
+				t.Errorf("tags = %v, want %v", snap.Tags, tt.wantTags)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package manager
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"kopia-manager/internal/ui"
+
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+// backupProgressInterval is how often the progress bar is redrawn
+const backupProgressInterval = 100 * time.Millisecond
+
+// backupProgress shows the combined progress of the sources of a backup run on a
+// ui.SimpleProgressBar.
+//
+// The total is the size of the previous snapshot of each source, so the ETA assumes the
+// sources haven't grown much since. Without a previous snapshot kopia's own estimate is used.
+type backupProgress struct {
+	// uploaded counts the bytes written by the write session, which the sources share
+	uploaded atomic.Int64
+
+	mu          sync.Mutex
+	bar         *ui.SimpleProgressBar
+	sources     []*sourceProgress
+	currentFile string
+	done        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// newBackupProgress starts drawing the progress of a backup run
+func newBackupProgress(title string) *backupProgress {
+	p := &backupProgress{
+		bar:  ui.NewSimpleProgressBar(title, 0),
+		done: make(chan struct{}),
+	}
+	p.wg.Add(1)
+	go p.render()
+	return p
+}
+
+// source registers the progress of a source; previous are the snapshots passed to the
+// uploader. A nil backupProgress returns a source that only counts.
+func (p *backupProgress) source(previous []*snapshot.Manifest) *sourceProgress {
+	s := &sourceProgress{parent: p}
+
+	var latest *snapshot.Manifest
+	for _, m := range previous {
+		if latest == nil || m.StartTime.After(latest.StartTime) {
+			latest = m
+		}
+	}
+	if latest != nil {
+		s.knownSize = true
+		s.expected.Store(latest.Stats.TotalFileSize)
+	}
+
+	if p != nil {
+		p.mu.Lock()
+		p.sources = append(p.sources, s)
+		p.mu.Unlock()
+	}
+	return s
+}
+
+// UploadedBytes is the OnUpload callback of the write session
+func (p *backupProgress) UploadedBytes(numBytes int64) {
+	p.uploaded.Add(numBytes)
+}
+
+func (p *backupProgress) setCurrentFile(fname string) {
+	p.mu.Lock()
+	p.currentFile = fname
+	p.mu.Unlock()
+}
+
+// Finish stops redrawing and prints the final state. It is called once the written
+// data is flushed, so the uploaded bytes are complete.
+func (p *backupProgress) Finish() {
+	close(p.done)
+	p.wg.Wait()
+}
+
+// render redraws the progress bar until Finish is called
+func (p *backupProgress) render() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(backupProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.bar.SetTotal(p.update(false))
+			p.bar.Finish()
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.update(true)
+			p.bar.Print()
+			p.mu.Unlock()
+		}
+	}
+}
+
+// update feeds the counters of all sources to the progress bar and returns the bytes
+// read so far; the current file is only shown while running. Called with mu held.
+func (p *backupProgress) update(running bool) int64 {
+	stats := p.stats()
+	p.bar.SetTotal(stats.total)
+	p.bar.Update(stats.processed)
+
+	status := stats.String()
+	if running && p.currentFile != "" {
+		status += " • " + p.currentFile
+	}
+	p.bar.SetStatus(status)
+	return stats.processed
+}
+
+// backupStats are the combined counters of the sources of a backup run
+type backupStats struct {
+	// processed is the number of bytes read, hashed or found in the cache
+	processed int64
+	// total is the expected size of the sources
+	total    int64
+	uploaded int64
+	files    int32
+	cached   int32
+	finished int
+	sources  int
+}
+
+// stats sums the counters of all sources. Called with mu held.
+func (p *backupProgress) stats() backupStats {
+	stats := backupStats{uploaded: p.uploaded.Load(), sources: len(p.sources)}
+	for _, s := range p.sources {
+		c := s.Snapshot()
+		stats.processed += c.TotalHashedBytes + c.TotalCachedBytes
+		stats.total += s.size()
+		stats.files += c.TotalHashedFiles + c.TotalCachedFiles
+		stats.cached += c.TotalCachedFiles
+		if s.finished.Load() {
+			stats.finished++
+		}
+	}
+	return stats
+}
+
+// String formats the file counts and uploaded bytes for the progress bar status
+func (s backupStats) String() string {
+	status := fmt.Sprintf("%d files (%d new, %d cached) • %s uploaded",
+		s.files, s.files-s.cached, s.cached, ui.FormatSize(s.uploaded))
+	if s.sources > 1 {
+		status = fmt.Sprintf("%d/%d paths • %s", s.finished, s.sources, status)
+	}
+	return status
+}
+
+// sourceProgress is the upload.Progress of a single source
+type sourceProgress struct {
+	upload.CountingUploadProgress
+
+	parent    *backupProgress
+	knownSize bool
+	expected  atomic.Int64
+	finished  atomic.Bool
+}
+
+// Enabled requests kopia's estimate of the source size, which is only needed
+// without a previous snapshot
+func (s *sourceProgress) Enabled() bool {
+	return !s.knownSize
+}
+
+// UploadFinished implements upload.Progress
+func (s *sourceProgress) UploadFinished() {
+	s.CountingUploadProgress.UploadFinished()
+	s.finished.Store(true)
+}
+
+// EstimatedDataSize implements upload.Progress
+func (s *sourceProgress) EstimatedDataSize(numFiles, numBytes int64) {
+	s.CountingUploadProgress.EstimatedDataSize(numFiles, numBytes)
+	if !s.knownSize {
+		s.expected.Store(numBytes)
+	}
+}
+
+// HashingFile implements upload.Progress
+func (s *sourceProgress) HashingFile(fname string) {
+	s.CountingUploadProgress.HashingFile(fname)
+	if s.parent != nil {
+		s.parent.setCurrentFile(fname)
+	}
+}
+
+// CachedFile implements upload.Progress
+func (s *sourceProgress) CachedFile(fname string, numBytes int64) {
+	s.CountingUploadProgress.CachedFile(fname, numBytes)
+	if s.parent != nil {
+		s.parent.setCurrentFile(fname)
+	}
+}
+
+// size returns the expected size of the source, or what was read once it is finished
+func (s *sourceProgress) size() int64 {
+	if s.finished.Load() {
+		c := s.Snapshot()
+		return c.TotalHashedBytes + c.TotalCachedBytes
+	}
+	return s.expected.Load()
+}
@@ -0,0 +1,95 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestBackupProgressStats(t *testing.T) {
+	previous := func(sizes ...int64) []*snapshot.Manifest {
+		var manifests []*snapshot.Manifest
+		for i, size := range sizes {
+			manifests = append(manifests, &snapshot.Manifest{
+				StartTime: fs.UTCTimestamp(i),
+				Stats:     snapshot.Stats{TotalFileSize: size},
+			})
+		}
+		return manifests
+	}
+
+	tests := []struct {
+		name       string
+		run        func(p *backupProgress)
+		want       backupStats
+		wantStatus string
+	}{
+		{
+			name:       "nothing read",
+			run:        func(p *backupProgress) { p.source(nil) },
+			want:       backupStats{sources: 1},
+			wantStatus: "0 files (0 new, 0 cached) • 0 B uploaded",
+		},
+		{
+			name: "cached and new files",
+			run: func(p *backupProgress) {
+				s := p.source(previous(5000, 1000))
+				s.CachedFile("a", 300)
+				s.CachedFile("b", 100)
+				s.HashedBytes(200)
+				s.FinishedHashingFile("c", 200)
+				p.UploadedBytes(2048)
+			},
+			want:       backupStats{processed: 600, total: 1000, uploaded: 2048, files: 3, cached: 2, sources: 1},
+			wantStatus: "3 files (1 new, 2 cached) • 2.00 KB uploaded",
+		},
+		{
+			name: "estimate without a previous snapshot",
+			run: func(p *backupProgress) {
+				s := p.source(nil)
+				s.EstimatedDataSize(3, 400)
+				s.HashedBytes(100)
+			},
+			want:       backupStats{processed: 100, total: 400, sources: 1},
+			wantStatus: "0 files (0 new, 0 cached) • 0 B uploaded",
+		},
+		{
+			name: "previous size wins over the estimate",
+			run: func(p *backupProgress) {
+				p.source(previous(1000)).EstimatedDataSize(3, 400)
+			},
+			want:       backupStats{total: 1000, sources: 1},
+			wantStatus: "0 files (0 new, 0 cached) • 0 B uploaded",
+		},
+		{
+			name: "finished sources count what was read",
+			run: func(p *backupProgress) {
+				done := p.source(previous(1000))
+				done.HashedBytes(50)
+				done.FinishedHashingFile("a", 50)
+				done.UploadFinished()
+
+				running := p.source(previous(700))
+				running.CachedFile("b", 70)
+			},
+			want:       backupStats{processed: 120, total: 750, files: 2, cached: 1, finished: 1, sources: 2},
+			wantStatus: "1/2 paths • 2 files (1 new, 1 cached) • 0 B uploaded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &backupProgress{}
+			tt.run(p)
+
+			got := p.stats()
+			if got != tt.want {
+				t.Errorf("stats() = %+v, want %+v", got, tt.want)
+			}
+			if status := got.String(); status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package manager
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupKeepsGoingAfterFailedPath(t *testing.T) {
+	km := newTestManager(t)
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "data.txt"), "data")
+	missing := filepath.Join(t.TempDir(), "missing")
+	paths := []string{missing, src}
+
+	for _, parallel := range []int{1, 2} {
+		results, err := km.BackupSources(paths, BackupOptions{Parallel: parallel})
+		if err != nil {
+			t.Fatalf("BackupSources(parallel %d) error: %v", parallel, err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("BackupSources(parallel %d) returned %d results, want 2", parallel, len(results))
+		}
+		if r := results[0]; !r.Failed() || r.SnapshotID != "" {
+			t.Errorf("parallel %d: result of the missing path = %+v, want an error and no snapshot", parallel, r)
+		}
+		if r := results[1]; r.Failed() || r.SnapshotID == "" || r.Files != 1 {
+			t.Errorf("parallel %d: result of %s = %+v, want a snapshot of 1 file", parallel, src, r)
+		}
+	}
+
+	err := km.CreateBackup(paths, BackupOptions{})
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 paths failed") {
+		t.Errorf("CreateBackup() error = %v, want 1 of 2 paths failed", err)
+	}
+}
@@ -0,0 +1,201 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"charm.land/log/v2"
+	"github.com/zalando/go-keyring"
+)
+
+// PasswordEnvVar is the environment variable kopia reads the repository password from
+const PasswordEnvVar = "KOPIA_PASSWORD"
+
+// SystemdCredentialName is the credential a systemd unit passes the password in,
+// e.g. LoadCredential=kopia-password:/run/secrets/kopia
+const SystemdCredentialName = "kopia-password"
+
+// ErrNoPassword is returned by a provider that has no password to offer
+var ErrNoPassword = errors.New("no repository password found")
+
+// CredentialProvider supplies the repository password
+type CredentialProvider interface {
+	// Name describes where the password comes from
+	Name() string
+	// Password returns the password, or ErrNoPassword if the source is not set up
+	Password(ctx context.Context) (string, error)
+}
+
+// EnvCredentials reads the password from an environment variable
+type EnvCredentials struct {
+	Variable string
+}
+
+func (c EnvCredentials) Name() string {
+	return "$" + c.Variable
+}
+
+func (c EnvCredentials) Password(ctx context.Context) (string, error) {
+	password, ok := os.LookupEnv(c.Variable)
+	if !ok || password == "" {
+		return "", ErrNoPassword
+	}
+	return password, nil
+}
+
+// SystemdCredentials reads the password from a systemd service credential
+type SystemdCredentials struct {
+	Credential string
+}
+
+func (c SystemdCredentials) Name() string {
+	return "systemd credential " + c.Credential
+}
+
+func (c SystemdCredentials) Password(ctx context.Context) (string, error) {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return "", ErrNoPassword
+	}
+	return readPasswordFile(filepath.Join(dir, c.Credential))
+}
+
+// CommandCredentials runs a shell command, e.g. "pass show kopia", and uses its output
+type CommandCredentials struct {
+	Command string
+}
+
+func (c CommandCredentials) Name() string {
+	return "password command"
+}
+
+func (c CommandCredentials) Password(ctx context.Context) (string, error) {
+	if c.Command == "" {
+		return "", ErrNoPassword
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	cmd.Stdin = os.Stdin // allow pinentry style prompts
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+
+	// Like pass, only the first line is the password
+	password, _, _ := strings.Cut(string(output), "\n")
+	password = strings.TrimSpace(password)
+	if password == "" {
+		return "", fmt.Errorf("printed no password")
+	}
+	return password, nil
+}
+
+// FileCredentials reads the password from a plaintext file
+type FileCredentials struct {
+	Path string
+}
+
+func (c FileCredentials) Name() string {
+	return "password file " + c.Path
+}
+
+func (c FileCredentials) Password(ctx context.Context) (string, error) {
+	if c.Path == "" {
+		return "", ErrNoPassword
+	}
+	return readPasswordFile(c.Path)
+}
+
+// KeyringCredentials reads the password kopia stored in the Secret Service keyring
+// for a config file with "kopia repository connect --persist-credentials"
+type KeyringCredentials struct {
+	ConfigPath string
+}
+
+func (c KeyringCredentials) Name() string {
+	return "keyring"
+}
+
+func (c KeyringCredentials) Password(ctx context.Context) (string, error) {
+	current, err := user.Current()
+	if err != nil {
+		return "", ErrNoPassword
+	}
+
+	// Without a Secret Service (e.g. on a headless server) there is simply no keyring password
+	password, err := keyring.Get(keyringItemID(c.ConfigPath), current.Username)
+	if err != nil {
+		return "", ErrNoPassword
+	}
+	return password, nil
+}
+
+// keyringItemID names the keyring item of a config file the way kopia does
+func keyringItemID(configPath string) string {
+	h := sha256.Sum256([]byte(configPath))
+	return fmt.Sprintf("%v-%x", filepath.Base(configPath), h[0:8])
+}
+
+// readPasswordFile reads a password file; a missing file offers no password
+func readPasswordFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNoPassword
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read password file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// CredentialChain tries each provider in turn until one has a password
+type CredentialChain []CredentialProvider
+
+func (c CredentialChain) Name() string {
+	names := make([]string, 0, len(c))
+	for _, p := range c {
+		names = append(names, p.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+func (c CredentialChain) Password(ctx context.Context) (string, error) {
+	for _, p := range c {
+		password, err := p.Password(ctx)
+		if errors.Is(err, ErrNoPassword) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", p.Name(), err)
+		}
+		log.Debug("Using repository password", "source", p.Name())
+		return password, nil
+	}
+	return "", fmt.Errorf("%w (tried %s)", ErrNoPassword, c.Name())
+}
+
+// credentials returns the password sources of the repository, in order of precedence.
+// The command and file of the flags or profile come first so a selected profile isn't
+// overridden by a password the environment holds for another repository.
+func (km *KopiaManager) credentials() CredentialProvider {
+	return CredentialChain{
+		CommandCredentials{Command: km.PasswordCommand},
+		FileCredentials{Path: km.PasswordPath},
+		EnvCredentials{Variable: PasswordEnvVar},
+		SystemdCredentials{Credential: SystemdCredentialName},
+		KeyringCredentials{ConfigPath: km.ConfigPath},
+	}
+}
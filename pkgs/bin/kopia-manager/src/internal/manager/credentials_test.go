@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestCredentialPrecedence(t *testing.T) {
+	keyring.MockInit()
+	current, err := user.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "repository.config")
+	passwordPath := filepath.Join(dir, "repository.password")
+	writeFile(t, passwordPath, "from-file\n")
+	credentialsDir := filepath.Join(dir, "credentials")
+	writeFile(t, filepath.Join(credentialsDir, SystemdCredentialName), "from-systemd")
+	if err := keyring.Set(keyringItemID(configPath), current.Username, "from-keyring"); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	tests := []struct {
+		name           string
+		command        string
+		passwordPath   string
+		env            string
+		credentialsDir string
+		configPath     string
+		want           string
+		wantErr        error
+		wantCommandErr bool
+	}{
+		{name: "command before everything", command: "echo from-command", passwordPath: passwordPath, env: "from-env", credentialsDir: credentialsDir, configPath: configPath, want: "from-command"},
+		{name: "file before environment", passwordPath: passwordPath, env: "from-env", credentialsDir: credentialsDir, configPath: configPath, want: "from-file"},
+		{name: "environment without file", passwordPath: missing, env: "from-env", credentialsDir: credentialsDir, configPath: configPath, want: "from-env"},
+		{name: "systemd credential", passwordPath: missing, credentialsDir: credentialsDir, configPath: configPath, want: "from-systemd"},
+		{name: "keyring last", passwordPath: missing, configPath: configPath, want: "from-keyring"},
+		{name: "no password", passwordPath: missing, configPath: missing, wantErr: ErrNoPassword},
+		{name: "failing command stops the chain", command: "exit 1", passwordPath: passwordPath, configPath: configPath, wantCommandErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(PasswordEnvVar, tt.env)
+			t.Setenv("CREDENTIALS_DIRECTORY", tt.credentialsDir)
+			km := &KopiaManager{ConfigPath: tt.configPath, PasswordPath: tt.passwordPath, PasswordCommand: tt.command}
+
+			got, err := km.credentials().Password(context.Background())
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Password() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantCommandErr:
+				if err == nil || errors.Is(err, ErrNoPassword) {
+					t.Fatalf("Password() error = %v, want the command failure", err)
+				}
+			case err != nil:
+				t.Fatalf("Password() error: %v", err)
+			case got != tt.want:
+				t.Errorf("Password() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
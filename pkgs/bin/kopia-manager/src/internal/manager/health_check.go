@@ -0,0 +1,288 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/kopia/kopia/snapshot"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CheckStatus is a health check result using Nagios plugin exit codes
+type CheckStatus int
+
+// Check results, ordered by severity
+const (
+	CheckOK CheckStatus = iota
+	CheckWarning
+	CheckCritical
+	CheckUnknown
+)
+
+// Default thresholds for km check
+const (
+	DefaultCheckWarnAge    = 24 * time.Hour
+	DefaultCheckCritAge    = 36 * time.Hour
+	DefaultCheckSizeChange = 50.0
+	// sizeBaselineSnapshots is how many earlier snapshots the size is compared with
+	sizeBaselineSnapshots = 5
+)
+
+// String returns the Nagios name of the status
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckOK:
+		return "OK"
+	case CheckWarning:
+		return "WARNING"
+	case CheckCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// MarshalText encodes the status by name
+func (s CheckStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// worse returns the more severe of two statuses
+func (s CheckStatus) worse(other CheckStatus) CheckStatus {
+	if other > s {
+		return other
+	}
+	return s
+}
+
+// CheckOptions selects the sources to check and the thresholds to apply
+type CheckOptions struct {
+	FindOptions
+	WarnAge    time.Duration
+	CritAge    time.Duration
+	MaxErrors  int
+	SizeChange float64 // percent; 0 disables the size check
+}
+
+// SourceCheck is the health of one backup source
+type SourceCheck struct {
+	Source            string           `json:"source"`
+	Path              string           `json:"path"`
+	Hostname          string           `json:"hostname"`
+	Username          string           `json:"username"`
+	Status            CheckStatus      `json:"status"`
+	Problems          []string         `json:"problems,omitempty"`
+	Snapshots         int              `json:"snapshots"`
+	Latest            *SnapshotSummary `json:"latest,omitempty"`
+	AgeSeconds        int64            `json:"ageSeconds"`
+	ErrorCount        int32            `json:"errorCount"`
+	SizeChangePercent float64          `json:"sizeChangePercent"`
+}
+
+// CheckReport is the result of km check
+type CheckReport struct {
+	Status   CheckStatus   `json:"status"`
+	Time     time.Time     `json:"time"`
+	Problems []string      `json:"problems,omitempty"`
+	Sources  []SourceCheck `json:"sources"`
+}
+
+// CheckHealth evaluates the age, errors and size of the latest snapshot of each source
+func (ro *RepositoryOps) CheckHealth(opts CheckOptions) (*CheckReport, error) {
+	ctx := context.Background()
+	r, err := ro.km.openRepository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close(ctx)
+
+	report := &CheckReport{Time: time.Now(), Sources: []SourceCheck{}}
+
+	sources, err := filterSources(ctx, r, opts.FindOptions)
+	if err != nil {
+		report.Status = CheckUnknown
+		report.Problems = append(report.Problems, err.Error())
+		return report, nil
+	}
+
+	for _, source := range sources {
+		snapshots, err := sortedSnapshots(ctx, r, source)
+		if err != nil {
+			return nil, err
+		}
+
+		check := checkSource(source, snapshots, report.Time, opts)
+		report.Status = report.Status.worse(check.Status)
+		for _, problem := range check.Problems {
+			report.Problems = append(report.Problems, fmt.Sprintf("%s: %s", check.Source, problem))
+		}
+		report.Sources = append(report.Sources, check)
+	}
+
+	return report, nil
+}
+
+// checkSource applies the thresholds to the snapshots of a source, oldest first
+func checkSource(source snapshot.SourceInfo, snapshots []*snapshot.Manifest, now time.Time, opts CheckOptions) SourceCheck {
+	check := SourceCheck{
+		Source:    source.String(),
+		Path:      source.Path,
+		Hostname:  source.Host,
+		Username:  source.UserName,
+		Snapshots: len(snapshots),
+	}
+
+	problem := func(status CheckStatus, format string, args ...any) {
+		check.Status = check.Status.worse(status)
+		check.Problems = append(check.Problems, fmt.Sprintf(format, args...))
+	}
+
+	// Checkpoints of an interrupted backup don't count as a fresh backup
+	var complete []*snapshot.Manifest
+	for _, snap := range snapshots {
+		if snap.IncompleteReason == "" {
+			complete = append(complete, snap)
+		}
+	}
+	if len(snapshots) > 0 && snapshots[len(snapshots)-1].IncompleteReason != "" {
+		problem(CheckWarning, "latest snapshot is incomplete (%s)", snapshots[len(snapshots)-1].IncompleteReason)
+	}
+
+	if len(complete) == 0 {
+		problem(CheckCritical, "no complete snapshot")
+		return check
+	}
+
+	latest := complete[len(complete)-1]
+	summary := manifestToSummary(latest)
+	check.Latest = &summary
+	check.ErrorCount = latest.Stats.ErrorCount
+
+	age := now.Sub(latest.StartTime.ToTime())
+	check.AgeSeconds = int64(age.Seconds())
+	switch {
+	case opts.CritAge > 0 && age > opts.CritAge:
+		problem(CheckCritical, "last snapshot %s ago (critical after %s)", formatAge(age), formatAge(opts.CritAge))
+	case opts.WarnAge > 0 && age > opts.WarnAge:
+		problem(CheckWarning, "last snapshot %s ago (warning after %s)", formatAge(age), formatAge(opts.WarnAge))
+	}
+
+	if int(check.ErrorCount) > opts.MaxErrors {
+		problem(CheckWarning, "last snapshot had %d errors", check.ErrorCount)
+	}
+
+	// Compare with the average size of the snapshots before it
+	baseline := complete[:len(complete)-1]
+	if len(baseline) > sizeBaselineSnapshots {
+		baseline = baseline[len(baseline)-sizeBaselineSnapshots:]
+	}
+	var total int64
+	for _, snap := range baseline {
+		total += snap.Stats.TotalFileSize
+	}
+	if len(baseline) > 0 && total > 0 {
+		average := float64(total) / float64(len(baseline))
+		check.SizeChangePercent = math.Round((float64(latest.Stats.TotalFileSize)-average)/average*1000) / 10
+		if opts.SizeChange > 0 && math.Abs(check.SizeChangePercent) > opts.SizeChange {
+			problem(CheckWarning, "size changed by %+.1f%% from the average of the last %d snapshots", check.SizeChangePercent, len(baseline))
+		}
+	}
+
+	return check
+}
+
+// formatAge renders a duration to the minute, e.g. "1d12h", "5h30m" or "40m"
+func formatAge(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// Registry returns a Prometheus registry holding the metrics of the report
+func (report *CheckReport) Registry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(report)
+	return reg
+}
+
+// WriteTextfile writes the report for the node_exporter textfile collector.
+// The file is replaced atomically so node_exporter never reads a partial file.
+func (report *CheckReport) WriteTextfile(path string) error {
+	if err := prometheus.WriteToTextfile(path, report.Registry()); err != nil {
+		return fmt.Errorf("failed to write textfile %s: %w", path, err)
+	}
+	return nil
+}
+
+var (
+	sourceLabels = []string{"path", "hostname", "username"}
+
+	checkStatusDesc = prometheus.NewDesc("km_check_status",
+		"Overall backup check status (0 OK, 1 warning, 2 critical, 3 unknown).", nil, nil)
+	checkTimestampDesc = prometheus.NewDesc("km_check_timestamp_seconds",
+		"Time of the backup check.", nil, nil)
+	sourceStatusDesc = prometheus.NewDesc("km_source_check_status",
+		"Backup check status of the source (0 OK, 1 warning, 2 critical, 3 unknown).", sourceLabels, nil)
+	sourceSnapshotsDesc = prometheus.NewDesc("km_source_snapshots",
+		"Number of snapshots of the source.", sourceLabels, nil)
+	sourceLastTimestampDesc = prometheus.NewDesc("km_source_last_snapshot_timestamp_seconds",
+		"Start time of the latest complete snapshot.", sourceLabels, nil)
+	sourceLastAgeDesc = prometheus.NewDesc("km_source_last_snapshot_age_seconds",
+		"Age of the latest complete snapshot at check time.", sourceLabels, nil)
+	sourceLastSizeDesc = prometheus.NewDesc("km_source_last_snapshot_size_bytes",
+		"Total file size of the latest complete snapshot.", sourceLabels, nil)
+	sourceLastFilesDesc = prometheus.NewDesc("km_source_last_snapshot_files",
+		"Number of files in the latest complete snapshot.", sourceLabels, nil)
+	sourceLastErrorsDesc = prometheus.NewDesc("km_source_last_snapshot_errors",
+		"Errors recorded by the latest complete snapshot.", sourceLabels, nil)
+	sourceSizeChangeDesc = prometheus.NewDesc("km_source_size_change_percent",
+		"Size change of the latest snapshot from the average of the ones before it.", sourceLabels, nil)
+)
+
+// Describe implements prometheus.Collector
+func (report *CheckReport) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		checkStatusDesc, checkTimestampDesc, sourceStatusDesc, sourceSnapshotsDesc,
+		sourceLastTimestampDesc, sourceLastAgeDesc, sourceLastSizeDesc, sourceLastFilesDesc,
+		sourceLastErrorsDesc, sourceSizeChangeDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (report *CheckReport) Collect(ch chan<- prometheus.Metric) {
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+
+	gauge(checkStatusDesc, float64(report.Status))
+	gauge(checkTimestampDesc, float64(report.Time.Unix()))
+
+	for _, s := range report.Sources {
+		labels := []string{s.Path, s.Hostname, s.Username}
+		gauge(sourceStatusDesc, float64(s.Status), labels...)
+		gauge(sourceSnapshotsDesc, float64(s.Snapshots), labels...)
+		if s.Latest == nil {
+			continue
+		}
+		gauge(sourceLastTimestampDesc, float64(s.Latest.StartTime.Unix()), labels...)
+		gauge(sourceLastAgeDesc, float64(s.AgeSeconds), labels...)
+		gauge(sourceLastSizeDesc, float64(s.Latest.TotalSize), labels...)
+		gauge(sourceLastFilesDesc, float64(s.Latest.FileCount), labels...)
+		gauge(sourceLastErrorsDesc, float64(s.ErrorCount), labels...)
+		gauge(sourceSizeChangeDesc, s.SizeChangePercent, labels...)
+	}
+}
@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestCheckSource(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	source := snapshot.SourceInfo{Host: "nas", UserName: "root", Path: "/srv"}
+	opts := CheckOptions{WarnAge: 24 * time.Hour, CritAge: 36 * time.Hour, SizeChange: 50}
+
+	// snap is a snapshot taken age before now
+	snap := func(age time.Duration, size int64) *snapshot.Manifest {
+		m := &snapshot.Manifest{Source: source, StartTime: fs.UTCTimestampFromTime(now.Add(-age))}
+		m.Stats.TotalFileSize = size
+		return m
+	}
+	withErrors := snap(time.Hour, 100)
+	withErrors.Stats.ErrorCount = 2
+	incomplete := snap(time.Hour, 100)
+	incomplete.IncompleteReason = "canceled"
+
+	tests := []struct {
+		name       string
+		snapshots  []*snapshot.Manifest
+		opts       *CheckOptions
+		want       CheckStatus
+		sizeChange float64
+	}{
+		{name: "fresh", snapshots: []*snapshot.Manifest{snap(2*time.Hour, 100)}, want: CheckOK},
+		{name: "no snapshots", want: CheckCritical},
+		{name: "older than warn age", snapshots: []*snapshot.Manifest{snap(25*time.Hour, 100)}, want: CheckWarning},
+		{name: "older than crit age", snapshots: []*snapshot.Manifest{snap(37*time.Hour, 100)}, want: CheckCritical},
+		{
+			name:      "age checks disabled",
+			snapshots: []*snapshot.Manifest{snap(100*time.Hour, 100)},
+			opts:      &CheckOptions{SizeChange: 50},
+			want:      CheckOK,
+		},
+		{name: "errors above the maximum", snapshots: []*snapshot.Manifest{withErrors}, want: CheckWarning},
+		{
+			name:      "errors within the maximum",
+			snapshots: []*snapshot.Manifest{withErrors},
+			opts:      &CheckOptions{WarnAge: 24 * time.Hour, MaxErrors: 2},
+			want:      CheckOK,
+		},
+		{
+			name:      "latest incomplete falls back to the last complete one",
+			snapshots: []*snapshot.Manifest{snap(2*time.Hour, 100), incomplete},
+			want:      CheckWarning,
+		},
+		{name: "only incomplete snapshots", snapshots: []*snapshot.Manifest{incomplete}, want: CheckCritical},
+		{
+			name:       "size change within threshold",
+			snapshots:  []*snapshot.Manifest{snap(3*time.Hour, 100), snap(2*time.Hour, 300), snap(time.Hour, 280)},
+			want:       CheckOK,
+			sizeChange: 40,
+		},
+		{
+			name:       "size drop beyond threshold",
+			snapshots:  []*snapshot.Manifest{snap(3*time.Hour, 100), snap(2*time.Hour, 300), snap(time.Hour, 50)},
+			want:       CheckWarning,
+			sizeChange: -75,
+		},
+		{
+			name:       "size check disabled",
+			snapshots:  []*snapshot.Manifest{snap(2*time.Hour, 100), snap(time.Hour, 1000)},
+			opts:       &CheckOptions{WarnAge: 24 * time.Hour},
+			want:       CheckOK,
+			sizeChange: 900,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := opts
+			if tt.opts != nil {
+				o = *tt.opts
+			}
+			check := checkSource(source, tt.snapshots, now, o)
+			if check.Status != tt.want {
+				t.Errorf("status = %s (%q), want %s", check.Status, check.Problems, tt.want)
+			}
+			if check.Status != CheckOK && len(check.Problems) == 0 {
+				t.Errorf("status %s without a problem", check.Status)
+			}
+			if check.SizeChangePercent != tt.sizeChange {
+				t.Errorf("size change = %v%%, want %v%%", check.SizeChangePercent, tt.sizeChange)
+			}
+		})
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	km := newTestManager(t)
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "a.txt"), "a")
+	backup(t, km, src, BackupOptions{})
+
+	report, err := km.CheckHealth(CheckOptions{WarnAge: time.Hour, CritAge: 2 * time.Hour})
+	if err != nil {
+		t.Fatalf("CheckHealth() error: %v", err)
+	}
+	if report.Status != CheckOK || len(report.Sources) != 1 {
+		t.Errorf("report = %+v, want one healthy source", report)
+	}
+
+	// A filter matching nothing makes the check itself fail
+	report, err = km.CheckHealth(CheckOptions{FindOptions: FindOptions{Source: "/nonexistent"}})
+	if err != nil {
+		t.Fatalf("CheckHealth() error: %v", err)
+	}
+	if report.Status != CheckUnknown {
+		t.Errorf("status = %s, want UNKNOWN when no sources match", report.Status)
+	}
+}
@@ -0,0 +1,186 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// testPassword is the password of the repositories created by newTestManager
+const testPassword = "secret"
+
+// newTestManager creates a filesystem repository in a temporary directory and returns
+// a KopiaManager connected to it. The connection stays open until the test ends.
+func newTestManager(t *testing.T) *KopiaManager {
+	t.Helper()
+	return newTestManagerWithOptions(t, &repo.ConnectOptions{})
+}
+
+// newTestManagerWithOptions is newTestManager with connect options, e.g. for caching.
+// The storage is in the "repo" directory next to the config file.
+func newTestManagerWithOptions(t *testing.T, opts *repo.ConnectOptions) *KopiaManager {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	st, err := filesystem.New(ctx, &filesystem.Options{Path: filepath.Join(dir, "repo")}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Initialize(ctx, st, &repo.NewRepositoryOptions{}, testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	configPath := filepath.Join(dir, "repository.config")
+	if err := repo.Connect(ctx, configPath, st, testPassword, opts); err != nil {
+		t.Fatal(err)
+	}
+	passwordPath := filepath.Join(dir, "repository.password")
+	writeFile(t, passwordPath, testPassword)
+
+	km := &KopiaManager{ConfigPath: configPath, PasswordPath: passwordPath}
+	km.KeepOpen()
+	t.Cleanup(km.Close)
+	return km
+}
+
+// backup snapshots dir and returns the snapshot ID
+func backup(t *testing.T, km *KopiaManager, dir string, opts BackupOptions) string {
+	t.Helper()
+	results, err := km.BackupSources([]string{dir}, opts)
+	if err != nil {
+		t.Fatalf("BackupSources() error: %v", err)
+	}
+	if results[0].Failed() {
+		t.Fatalf("backup of %s failed: %s", dir, results[0].Error)
+	}
+	return results[0].SnapshotID
+}
+
+// snapshotRoot opens the root directory of a snapshot; it is closed when the test ends
+func snapshotRoot(t *testing.T, km *KopiaManager, id string) fs.Directory {
+	t.Helper()
+	ctx := context.Background()
+	r, err := km.openRepository(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close(ctx) })
+
+	snap, err := snapshot.LoadSnapshot(ctx, r, manifest.ID(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := snapshotfs.SnapshotRoot(r, snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(root.Close)
+	return root.(fs.Directory)
+}
+
+// writeFile creates a file and its parent directories
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// openEntries tracks the entries handed out below a directory that were not closed yet
+type openEntries struct {
+	mu      sync.Mutex
+	entries map[*trackedEntry]string
+}
+
+func (o *openEntries) dir(d fs.Directory) fs.Directory {
+	return &trackedDirectory{Directory: d, entries: o}
+}
+
+// track records a child entry as open until it is closed
+func (o *openEntries) track(relPath string, e fs.Entry) fs.Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.entries == nil {
+		o.entries = make(map[*trackedEntry]string)
+	}
+	tracked := &trackedEntry{Entry: e, entries: o}
+	o.entries[tracked] = relPath
+	if d, ok := e.(fs.Directory); ok {
+		return &trackedDirectory{Directory: d, entries: o, relPath: relPath, tracked: tracked}
+	}
+	return tracked
+}
+
+func (o *openEntries) closed(e *trackedEntry) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.entries, e)
+}
+
+func (o *openEntries) open() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var paths []string
+	for _, p := range o.entries {
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+type trackedEntry struct {
+	fs.Entry
+	entries *openEntries
+}
+
+func (e *trackedEntry) Close() {
+	e.entries.closed(e)
+	e.Entry.Close()
+}
+
+// trackedDirectory tracks the children it hands out; the root directory itself is untracked
+type trackedDirectory struct {
+	fs.Directory
+	entries *openEntries
+	relPath string
+	tracked *trackedEntry
+}
+
+func (d *trackedDirectory) Close() {
+	if d.tracked != nil {
+		d.entries.closed(d.tracked)
+	}
+	d.Directory.Close()
+}
+
+func (d *trackedDirectory) Child(ctx context.Context, name string) (fs.Entry, error) {
+	child, err := d.Directory.Child(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return d.entries.track(path.Join(d.relPath, name), child), nil
+}
+
+func (d *trackedDirectory) Iterate(ctx context.Context) (fs.DirectoryIterator, error) {
+	children, err := fs.GetAllEntries(ctx, d.Directory)
+	if err != nil {
+		return nil, err
+	}
+	for i, child := range children {
+		children[i] = d.entries.track(path.Join(d.relPath, child.Name()), child)
+	}
+	return fs.StaticIterator(children, nil), nil
+}
@@ -1,55 +1,133 @@
 package manager
 
+import "io"
+
 // This file provides backward-compatible wrapper methods for existing code during migration to specialized managers.
 
 func (km *KopiaManager) ListSnapshots(hostname, username string) ([]SnapshotSummary, error) {
 	return km.Snapshots().ListSnapshots(hostname, username)
 }
 
+func (km *KopiaManager) SnapshotDetails(snapshotID string) (*SnapshotDetails, error) {
+	return km.Snapshots().SnapshotDetails(snapshotID)
+}
+
 func (km *KopiaManager) GetSnapshotInfo(snapshotID string) (string, error) {
 	return km.Snapshots().GetSnapshotInfo(snapshotID)
 }
 
-func (km *KopiaManager) CreateBackup(paths []string, description string) error {
-	return km.Snapshots().CreateBackup(paths, description)
+func (km *KopiaManager) CreateBackup(paths []string, opts BackupOptions) error {
+	return km.Snapshots().CreateBackup(paths, opts)
+}
+
+func (km *KopiaManager) BackupSources(paths []string, opts BackupOptions) ([]BackupResult, error) {
+	return km.Snapshots().BackupSources(paths, opts)
+}
+
+func (km *KopiaManager) RestoreSnapshot(snapshotID, targetDir string, opts RestoreOptions) error {
+	return km.Snapshots().RestoreSnapshot(snapshotID, targetDir, opts)
+}
+
+func (km *KopiaManager) PlanRestore(snapshotID, targetDir string, opts RestoreOptions) (*RestorePlan, error) {
+	return km.Snapshots().PlanRestore(snapshotID, targetDir, opts)
+}
+
+func (km *KopiaManager) DeleteSnapshot(snapshotID string, allFlag bool, hostname, username string, tags []string) error {
+	return km.Snapshots().DeleteSnapshot(snapshotID, allFlag, hostname, username, tags)
+}
+
+func (km *KopiaManager) DeleteBackupGroup(backupName string, tags []string) error {
+	return km.Snapshots().DeleteBackupGroup(backupName, tags)
+}
+
+func (km *KopiaManager) PinSnapshot(snapshotID string, reasons []string) (*SnapshotUpdate, error) {
+	return km.Snapshots().PinSnapshot(snapshotID, reasons)
+}
+
+func (km *KopiaManager) UnpinSnapshot(snapshotID string, reasons []string) (*SnapshotUpdate, error) {
+	return km.Snapshots().UnpinSnapshot(snapshotID, reasons)
+}
+
+func (km *KopiaManager) TagSnapshot(snapshotID string, tags []string) (*SnapshotUpdate, error) {
+	return km.Snapshots().TagSnapshot(snapshotID, tags)
+}
+
+func (km *KopiaManager) UntagSnapshot(snapshotID string, keys []string) (*SnapshotUpdate, error) {
+	return km.Snapshots().UntagSnapshot(snapshotID, keys)
+}
+
+func (km *KopiaManager) DescribeSnapshot(snapshotID, description string) (*SnapshotUpdate, error) {
+	return km.Snapshots().DescribeSnapshot(snapshotID, description)
+}
+
+func (km *KopiaManager) DiffSnapshots(snapshot1, snapshot2 string, opts DiffOptions) (*SnapshotDiff, error) {
+	return km.Snapshots().DiffSnapshots(snapshot1, snapshot2, opts)
+}
+
+func (km *KopiaManager) FindEntries(pattern string, opts FindOptions) ([]FindMatch, error) {
+	return km.Snapshots().FindEntries(pattern, opts)
 }
 
-func (km *KopiaManager) RestoreSnapshot(snapshotID, targetDir string) error {
-	return km.Snapshots().RestoreSnapshot(snapshotID, targetDir)
+func (km *KopiaManager) FileHistory(filePath string, opts FindOptions) (*FileHistory, error) {
+	return km.Snapshots().FileHistory(filePath, opts)
 }
 
-func (km *KopiaManager) DeleteSnapshot(snapshotID string, allFlag bool, hostname, username string) error {
-	return km.Snapshots().DeleteSnapshot(snapshotID, allFlag, hostname, username)
+func (km *KopiaManager) CatFile(snapshotID, filePath string, w io.Writer) error {
+	return km.Snapshots().CatFile(snapshotID, filePath, w)
 }
 
-func (km *KopiaManager) DeleteBackupGroup(backupName string) error {
-	return km.Snapshots().DeleteBackupGroup(backupName)
+func (km *KopiaManager) RestoreFile(snapshotID, filePath, target string, overwrite bool) error {
+	return km.Snapshots().RestoreFile(snapshotID, filePath, target, overwrite)
 }
 
-func (km *KopiaManager) DiffSnapshots(snapshot1, snapshot2 string) (string, error) {
-	return km.Snapshots().DiffSnapshots(snapshot1, snapshot2)
+func (km *KopiaManager) RestoreBackupGroup(backupName, targetDir string, opts RestoreOptions) error {
+	return km.Snapshots().RestoreBackupGroup(backupName, targetDir, opts)
 }
 
-func (km *KopiaManager) RestoreBackupGroup(backupName, targetDir string) error {
-	return km.Snapshots().RestoreBackupGroup(backupName, targetDir)
+func (km *KopiaManager) RepositoryStatus() (*RepositoryStatus, error) {
+	return km.Repository().RepositoryStatus()
 }
 
 func (km *KopiaManager) GetStatus() (string, error) {
 	return km.Repository().GetStatus()
 }
 
+func (km *KopiaManager) CheckHealth(opts CheckOptions) (*CheckReport, error) {
+	return km.Repository().CheckHealth(opts)
+}
+
 func (km *KopiaManager) RunMaintenance(unsafe bool) error {
 	return km.Repository().RunMaintenance(unsafe)
 }
 
-func (km *KopiaManager) VerifyRepository() error {
-	return km.Repository().VerifyRepository()
+func (km *KopiaManager) VerifyRepository(opts VerifyOptions) error {
+	return km.Repository().VerifyRepository(opts)
+}
+
+func (km *KopiaManager) VerifySnapshots(opts VerifyOptions, progress func(VerifyProgress)) (*VerifyReport, error) {
+	return km.Repository().VerifySnapshots(opts, progress)
+}
+
+func (km *KopiaManager) PolicyInfo(path string) (*PolicyInfo, error) {
+	return km.Repository().PolicyInfo(path)
 }
 
 func (km *KopiaManager) ShowPolicy(path string) (string, error) {
 	return km.Repository().ShowPolicy(path)
 }
 
+func (km *KopiaManager) SetPolicy(target string, changes PolicyChanges) ([]string, error) {
+	return km.Repository().SetPolicy(target, changes)
+}
+
+func (km *KopiaManager) PreviewRetention(target string, changes PolicyChanges) ([]RetentionPreview, error) {
+	return km.Repository().PreviewRetention(target, changes)
+}
+
+func (km *KopiaManager) EstimateBackups(paths []string, uploadSpeed int) ([]BackupEstimate, error) {
+	return km.Estimator().EstimateBackups(paths, uploadSpeed)
+}
+
 func (km *KopiaManager) EstimateBackupSize(paths []string, uploadSpeed int) (string, error) {
 	return km.Estimator().EstimateBackupSize(paths, uploadSpeed)
 }
@@ -62,6 +140,14 @@ func (km *KopiaManager) UnmountSnapshot(mountPoint string) error {
 	return km.Mounts().UnmountSnapshot(mountPoint)
 }
 
+func (km *KopiaManager) SystemdStatus() (*SystemdStatus, error) {
+	return Services().SystemdStatus()
+}
+
+func (km *KopiaManager) BackupServiceStatuses() ([]BackupServiceStatus, error) {
+	return Services().BackupServiceStatuses()
+}
+
 func (km *KopiaManager) GetServicesStatus() (string, error) {
 	return Services().GetServicesStatus()
 }
@@ -77,3 +163,23 @@ func (km *KopiaManager) TriggerBackupService(backupName string) error {
 func (km *KopiaManager) ListBackupServices() ([]string, error) {
 	return Services().ListBackupServices()
 }
+
+func (km *KopiaManager) CreateRepository(opts StorageOptions, password string) error {
+	return km.Repository().CreateRepository(opts, password)
+}
+
+func (km *KopiaManager) ConnectRepository(opts StorageOptions, password string) error {
+	return km.Repository().ConnectRepository(opts, password)
+}
+
+func (km *KopiaManager) DisconnectRepository() error {
+	return km.Repository().DisconnectRepository()
+}
+
+func (km *KopiaManager) RepositoryPassword() (string, error) {
+	return km.Repository().RepositoryPassword()
+}
+
+func (km *KopiaManager) SavePassword(password string) error {
+	return km.Repository().SavePassword(password)
+}
@@ -0,0 +1,373 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultMetricsInterval is how often km serve-metrics refreshes the metrics
+const DefaultMetricsInterval = 5 * time.Minute
+
+// unitStates are the systemd active states exported for each unit
+var unitStates = []string{"active", "reloading", "inactive", "failed", "activating", "deactivating"}
+
+// SourceMetrics are the exported figures of one backup source
+type SourceMetrics struct {
+	Path       string
+	Hostname   string
+	Username   string
+	Snapshots  int
+	Incomplete int
+	Latest     *SnapshotSummary
+	LastErrors int32
+	// LogicalSize is the total file size of all complete snapshots
+	LogicalSize int64
+	// UniqueSize is the size of the distinct files referenced by those snapshots
+	UniqueSize int64
+}
+
+// DedupRatio is the logical size of the snapshots over the size of their distinct files
+func (s SourceMetrics) DedupRatio() float64 {
+	if s.UniqueSize == 0 {
+		return 0
+	}
+	return float64(s.LogicalSize) / float64(s.UniqueSize)
+}
+
+// dedupResult caches the unique size of a source until its snapshots change
+type dedupResult struct {
+	snapshots  string
+	uniqueSize int64
+}
+
+// MetricsExporter collects repository and systemd metrics for Prometheus.
+//
+// The metrics are gathered by Refresh and served from memory, so scrapes never
+// wait for the repository.
+type MetricsExporter struct {
+	km *KopiaManager
+
+	mu          sync.RWMutex
+	sources     []SourceMetrics
+	systemd     *SystemdStatus
+	lastRefresh time.Time
+	lastSuccess time.Time
+	duration    time.Duration
+	refreshed   bool
+	failed      bool
+
+	dedup map[string]dedupResult
+}
+
+// NewMetricsExporter creates a new MetricsExporter instance
+func NewMetricsExporter(km *KopiaManager) *MetricsExporter {
+	return &MetricsExporter{km: km, dedup: make(map[string]dedupResult)}
+}
+
+// Refresh reloads the repository metrics and the systemd unit states.
+// It must not be called concurrently.
+//
+// Systemd errors are not fatal; the unit metrics are left out until systemd
+// can be queried again.
+func (me *MetricsExporter) Refresh(ctx context.Context) error {
+	start := time.Now()
+	sources, err := me.collectSources(ctx)
+	if err != nil {
+		// Reopen the repository on the next refresh in case the connection broke
+		me.km.closeRepository()
+	}
+
+	systemd, systemdErr := Services().SystemdStatus()
+
+	me.mu.Lock()
+	defer me.mu.Unlock()
+
+	me.lastRefresh = start
+	me.duration = time.Since(start)
+	me.refreshed = true
+	me.failed = err != nil
+	if err == nil {
+		me.sources = sources
+		me.lastSuccess = start
+	}
+	me.systemd = nil
+	if systemdErr == nil {
+		me.systemd = systemd
+	}
+
+	if err != nil {
+		return err
+	}
+	if systemdErr != nil {
+		return fmt.Errorf("failed to read systemd units: %w", systemdErr)
+	}
+	return nil
+}
+
+// collectSources gathers the metrics of every snapshot source
+func (me *MetricsExporter) collectSources(ctx context.Context) ([]SourceMetrics, error) {
+	r, err := me.km.openRepository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close(ctx)
+
+	// Pick up snapshots written by other processes since the last refresh
+	if err := r.Refresh(ctx); err != nil {
+		return nil, fmt.Errorf("failed to refresh repository: %w", err)
+	}
+
+	sources, err := snapshot.ListSources(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sources: %w", err)
+	}
+
+	metrics := make([]SourceMetrics, 0, len(sources))
+	seen := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		snapshots, err := sortedSnapshots(ctx, r, source)
+		if err != nil {
+			return nil, err
+		}
+
+		m := SourceMetrics{
+			Path:      source.Path,
+			Hostname:  source.Host,
+			Username:  source.UserName,
+			Snapshots: len(snapshots),
+		}
+
+		var complete []*snapshot.Manifest
+		for _, snap := range snapshots {
+			if snap.IncompleteReason != "" {
+				m.Incomplete++
+				continue
+			}
+			complete = append(complete, snap)
+			m.LogicalSize += snap.Stats.TotalFileSize
+		}
+		if len(complete) > 0 {
+			latest := complete[len(complete)-1]
+			summary := manifestToSummary(latest)
+			m.Latest = &summary
+			m.LastErrors = latest.Stats.ErrorCount
+		}
+
+		m.UniqueSize, err = me.uniqueSize(ctx, r, source.String(), complete)
+		if err != nil {
+			return nil, err
+		}
+		seen[source.String()] = true
+
+		metrics = append(metrics, m)
+	}
+
+	// Forget sources that no longer have snapshots
+	for key := range me.dedup {
+		if !seen[key] {
+			delete(me.dedup, key)
+		}
+	}
+
+	return metrics, nil
+}
+
+// uniqueSize returns the size of the distinct files in the snapshots of a source.
+// The result is reused until the snapshots of the source change.
+func (me *MetricsExporter) uniqueSize(ctx context.Context, r repo.Repository, key string, snapshots []*snapshot.Manifest) (int64, error) {
+	ids := make([]string, 0, len(snapshots))
+	for _, snap := range snapshots {
+		ids = append(ids, string(snap.ID))
+	}
+	snapshotIDs := strings.Join(ids, ",")
+	if cached, ok := me.dedup[key]; ok && cached.snapshots == snapshotIDs {
+		return cached.uniqueSize, nil
+	}
+
+	w := &dedupWalker{
+		dirs:  make(map[object.ID]bool),
+		files: make(map[object.ID]bool),
+	}
+	for _, snap := range snapshots {
+		root, err := snapshotfs.SnapshotRoot(r, snap)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open snapshot %s: %w", snap.ID, err)
+		}
+		err = w.walk(ctx, root)
+		root.Close()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read snapshot %s: %w", snap.ID, err)
+		}
+	}
+
+	me.dedup[key] = dedupResult{snapshots: snapshotIDs, uniqueSize: w.size}
+	return w.size, nil
+}
+
+// dedupWalker sums the sizes of distinct file objects.
+// Directories are content addressed, so a directory seen before is skipped.
+type dedupWalker struct {
+	dirs  map[object.ID]bool
+	files map[object.ID]bool
+	size  int64
+}
+
+// walk adds the files below entry that were not seen before
+func (w *dedupWalker) walk(ctx context.Context, entry fs.Entry) error {
+	h, ok := entry.(object.HasObjectID)
+	if !ok {
+		return nil
+	}
+	oid := h.ObjectID()
+
+	dir, isDir := entry.(fs.Directory)
+	if !isDir {
+		if !w.files[oid] {
+			w.files[oid] = true
+			w.size += entry.Size()
+		}
+		return nil
+	}
+
+	if w.dirs[oid] {
+		return nil
+	}
+	w.dirs[oid] = true
+
+	return fs.IterateEntries(ctx, dir, func(ctx context.Context, child fs.Entry) error {
+		defer child.Close()
+		return w.walk(ctx, child)
+	})
+}
+
+var (
+	unitLabels = []string{"unit"}
+
+	exporterUpDesc = prometheus.NewDesc("km_exporter_up",
+		"Whether the last refresh of the repository metrics succeeded.", nil, nil)
+	exporterRefreshDesc = prometheus.NewDesc("km_exporter_last_refresh_timestamp_seconds",
+		"Time of the last refresh attempt.", nil, nil)
+	exporterSuccessDesc = prometheus.NewDesc("km_exporter_last_success_timestamp_seconds",
+		"Time of the last successful refresh.", nil, nil)
+	exporterDurationDesc = prometheus.NewDesc("km_exporter_refresh_duration_seconds",
+		"Duration of the last refresh.", nil, nil)
+
+	sourceIncompleteDesc = prometheus.NewDesc("km_source_incomplete_snapshots",
+		"Number of incomplete snapshots of the source.", sourceLabels, nil)
+	sourceLogicalSizeDesc = prometheus.NewDesc("km_source_logical_size_bytes",
+		"Total file size of all complete snapshots of the source.", sourceLabels, nil)
+	sourceUniqueSizeDesc = prometheus.NewDesc("km_source_unique_size_bytes",
+		"Size of the distinct files referenced by the snapshots of the source.", sourceLabels, nil)
+	sourceDedupRatioDesc = prometheus.NewDesc("km_source_dedup_ratio",
+		"Logical size of the snapshots of the source over the size of their distinct files.", sourceLabels, nil)
+
+	unitStateDesc = prometheus.NewDesc("km_systemd_unit_state",
+		"Systemd active state of a kopia unit; 1 for the current state.", []string{"unit", "state"}, nil)
+	serviceLastStartDesc = prometheus.NewDesc("km_systemd_service_last_start_timestamp_seconds",
+		"Time the service last started.", unitLabels, nil)
+	serviceLastExitDesc = prometheus.NewDesc("km_systemd_service_last_exit_timestamp_seconds",
+		"Time the service last exited.", unitLabels, nil)
+	serviceFailedDesc = prometheus.NewDesc("km_systemd_service_failed",
+		"Whether the last run of the service failed.", unitLabels, nil)
+	timerNextDesc = prometheus.NewDesc("km_systemd_timer_next_trigger_timestamp_seconds",
+		"Time the timer triggers next.", unitLabels, nil)
+	timerLastDesc = prometheus.NewDesc("km_systemd_timer_last_trigger_timestamp_seconds",
+		"Time the timer last triggered.", unitLabels, nil)
+)
+
+// Describe implements prometheus.Collector
+func (me *MetricsExporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		exporterUpDesc, exporterRefreshDesc, exporterSuccessDesc, exporterDurationDesc,
+		sourceSnapshotsDesc, sourceIncompleteDesc, sourceLastTimestampDesc, sourceLastSizeDesc,
+		sourceLastFilesDesc, sourceLastErrorsDesc, sourceLogicalSizeDesc, sourceUniqueSizeDesc,
+		sourceDedupRatioDesc, unitStateDesc, serviceLastStartDesc, serviceLastExitDesc,
+		serviceFailedDesc, timerNextDesc, timerLastDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (me *MetricsExporter) Collect(ch chan<- prometheus.Metric) {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+	timestamp := func(desc *prometheus.Desc, t *time.Time, labels ...string) {
+		if t != nil {
+			gauge(desc, float64(t.Unix()), labels...)
+		}
+	}
+
+	if !me.refreshed {
+		return
+	}
+
+	up := 1.0
+	if me.failed {
+		up = 0
+	}
+	gauge(exporterUpDesc, up)
+	gauge(exporterRefreshDesc, float64(me.lastRefresh.Unix()))
+	gauge(exporterDurationDesc, me.duration.Seconds())
+	if !me.lastSuccess.IsZero() {
+		gauge(exporterSuccessDesc, float64(me.lastSuccess.Unix()))
+	}
+
+	for _, s := range me.sources {
+		labels := []string{s.Path, s.Hostname, s.Username}
+		gauge(sourceSnapshotsDesc, float64(s.Snapshots), labels...)
+		gauge(sourceIncompleteDesc, float64(s.Incomplete), labels...)
+		gauge(sourceLogicalSizeDesc, float64(s.LogicalSize), labels...)
+		gauge(sourceUniqueSizeDesc, float64(s.UniqueSize), labels...)
+		gauge(sourceDedupRatioDesc, s.DedupRatio(), labels...)
+		if s.Latest == nil {
+			continue
+		}
+		gauge(sourceLastTimestampDesc, float64(s.Latest.StartTime.Unix()), labels...)
+		gauge(sourceLastSizeDesc, float64(s.Latest.TotalSize), labels...)
+		gauge(sourceLastFilesDesc, float64(s.Latest.FileCount), labels...)
+		gauge(sourceLastErrorsDesc, float64(s.LastErrors), labels...)
+	}
+
+	if me.systemd == nil {
+		return
+	}
+	unitState := func(unit, activeState string) {
+		for _, state := range unitStates {
+			value := 0.0
+			if state == activeState {
+				value = 1
+			}
+			gauge(unitStateDesc, value, unit, state)
+		}
+	}
+	for _, s := range me.systemd.Services {
+		unitState(s.Unit, s.ActiveState)
+		timestamp(serviceLastStartDesc, s.LastStart, s.Unit)
+		timestamp(serviceLastExitDesc, s.LastExit, s.Unit)
+		failed := 0.0
+		if s.ActiveState == "failed" || (s.Result != "" && s.Result != "success") {
+			failed = 1
+		}
+		gauge(serviceFailedDesc, failed, s.Unit)
+	}
+	for _, t := range me.systemd.Timers {
+		unitState(t.Unit, t.ActiveState)
+		timestamp(timerNextDesc, t.Next, t.Unit)
+		timestamp(timerLastDesc, t.Last, t.Unit)
+	}
+}
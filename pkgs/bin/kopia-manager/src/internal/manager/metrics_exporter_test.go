@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollectSourcesDedup(t *testing.T) {
+	km := newTestManager(t)
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "a.txt"), "aaaa")
+	writeFile(t, filepath.Join(src, "copy", "a.txt"), "aaaa")
+	backup(t, km, src, BackupOptions{})
+	writeFile(t, filepath.Join(src, "b.txt"), "bb")
+	backup(t, km, src, BackupOptions{})
+
+	me := NewMetricsExporter(km)
+	sources, err := me.collectSources(context.Background())
+	if err != nil {
+		t.Fatalf("collectSources() error: %v", err)
+	}
+	if len(sources) != 1 {
+		t.Fatalf("got %d sources, want 1", len(sources))
+	}
+
+	// 8 bytes in the first snapshot, 10 in the second; a.txt and b.txt are distinct
+	s := sources[0]
+	if s.Snapshots != 2 || s.LogicalSize != 18 || s.UniqueSize != 6 {
+		t.Errorf("snapshots %d, logical %d, unique %d; want 2, 18, 6", s.Snapshots, s.LogicalSize, s.UniqueSize)
+	}
+	if s.DedupRatio() != 3 {
+		t.Errorf("DedupRatio() = %v, want 3", s.DedupRatio())
+	}
+
+	// Unchanged snapshots reuse the cached result
+	for key, cached := range me.dedup {
+		cached.uniqueSize = 42
+		me.dedup[key] = cached
+	}
+	sources, err = me.collectSources(context.Background())
+	if err != nil {
+		t.Fatalf("collectSources() error: %v", err)
+	}
+	if sources[0].UniqueSize != 42 {
+		t.Errorf("unique size = %d, want the cached 42", sources[0].UniqueSize)
+	}
+}
@@ -1,6 +1,8 @@
 package manager
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -41,13 +43,13 @@ func (mm *MountManager) MountSnapshot(snapshotID, mountPoint string) error {
 	env = append(env, os.Environ()...)
 	env = append(env, "KOPIA_CHECK_FOR_UPDATES=false")
 
-	if mm.km.PasswordPath != "" {
-		// Read password from file and set as environment variable
-		passwordBytes, err := os.ReadFile(mm.km.PasswordPath)
-		if err == nil {
-			password := strings.TrimSpace(string(passwordBytes))
-			env = append(env, "KOPIA_PASSWORD="+password)
-		}
+	// Pass the password on; kopia falls back to its own keyring lookup without one
+	password, err := mm.km.credentials().Password(context.Background())
+	if err != nil && !errors.Is(err, ErrNoPassword) {
+		return err
+	}
+	if password != "" {
+		env = append(env, PasswordEnvVar+"="+password)
 	}
 
 	ui.Infof("Mounting snapshot %s at %s...", snapshotID, mountPoint)
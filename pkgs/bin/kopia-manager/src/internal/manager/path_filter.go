@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// pathFilter selects snapshot entries by path prefix or glob pattern, relative to the
+// snapshot root. An empty filter selects everything.
+type pathFilter []string
+
+// newPathFilter normalizes the given patterns and validates their glob syntax
+func newPathFilter(patterns ...string) (pathFilter, error) {
+	var f pathFilter
+	for _, p := range patterns {
+		p = strings.Trim(path.Clean("/"+p), "/")
+		if p == "" {
+			// The snapshot root selects everything
+			return nil, nil
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid path pattern %q: %w", p, err)
+		}
+		f = append(f, p)
+	}
+	return f, nil
+}
+
+// matches reports whether relPath is selected by any pattern, either as a glob match
+// or by being the pattern path itself or below it
+func (f pathFilter) matches(relPath string) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, p := range f {
+		if ok, _ := path.Match(p, relPath); ok {
+			return true
+		}
+		if relPath == p || strings.HasPrefix(relPath, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mayContain reports whether relPath or anything below it can match the filter
+func (f pathFilter) mayContain(relPath string) bool {
+	if f.matches(relPath) {
+		return true
+	}
+
+	// Compare as many leading components as relPath has; globs are matched per component
+	pathParts := strings.Split(relPath, "/")
+	for _, p := range f {
+		if componentsMatch(strings.Split(p, "/"), pathParts) {
+			return true
+		}
+	}
+	return false
+}
+
+// componentsMatch reports whether pathParts is a leading part of patternParts
+func componentsMatch(patternParts, pathParts []string) bool {
+	if len(pathParts) > len(patternParts) {
+		return false
+	}
+	for i, part := range pathParts {
+		if ok, _ := path.Match(patternParts[i], part); !ok {
+			return false
+		}
+	}
+	return true
+}
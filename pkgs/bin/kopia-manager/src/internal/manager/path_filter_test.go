@@ -0,0 +1,67 @@
+package manager
+
+import "testing"
+
+func TestPathFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		patterns    []string
+		path        string
+		matches     bool
+		mayContain  bool
+		wantInvalid bool
+	}{
+		{name: "empty selects everything", path: "a/b", matches: true, mayContain: true},
+		{name: "root selects everything", patterns: []string{"/"}, path: "a/b", matches: true, mayContain: true},
+		{name: "exact path", patterns: []string{"docs/a.md"}, path: "docs/a.md", matches: true, mayContain: true},
+		{name: "below prefix", patterns: []string{"docs"}, path: "docs/sub/a.md", matches: true, mayContain: true},
+		{name: "leading slash and dots", patterns: []string{"/docs/../docs/"}, path: "docs/a.md", matches: true, mayContain: true},
+		{name: "parent of prefix", patterns: []string{"docs/sub"}, path: "docs", matches: false, mayContain: true},
+		{name: "sibling prefix", patterns: []string{"docs"}, path: "docs2/a.md", matches: false, mayContain: false},
+		{name: "glob", patterns: []string{"*/*.md"}, path: "docs/a.md", matches: true, mayContain: true},
+		{name: "glob parent", patterns: []string{"*/*.md"}, path: "docs", matches: false, mayContain: true},
+		{name: "glob mismatch", patterns: []string{"*/*.md"}, path: "docs/a.txt", matches: false, mayContain: false},
+		{name: "any pattern", patterns: []string{"src", "*.md"}, path: "README.md", matches: true, mayContain: true},
+		{name: "invalid glob", patterns: []string{"docs/["}, wantInvalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newPathFilter(tt.patterns...)
+			if tt.wantInvalid {
+				if err == nil {
+					t.Fatalf("newPathFilter(%q) = %v, want error", tt.patterns, f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newPathFilter(%q) error: %v", tt.patterns, err)
+			}
+
+			if got := f.matches(tt.path); got != tt.matches {
+				t.Errorf("matches(%q) = %v, want %v", tt.path, got, tt.matches)
+			}
+			if got := f.mayContain(tt.path); got != tt.mayContain {
+				t.Errorf("mayContain(%q) = %v, want %v", tt.path, got, tt.mayContain)
+			}
+		})
+	}
+}
+
+func TestRelativeToSource(t *testing.T) {
+	tests := []struct {
+		source, path, want string
+	}{
+		{"/home/user", "/home/user/docs/a.md", "/docs/a.md"},
+		{"/home/user/", "/home/user/docs", "/docs"},
+		{"/home/user", "/home/user", ""},
+		{"/home/user", "/home/username/a", "/home/username/a"},
+		{"/home/user", "docs/*.md", "docs/*.md"},
+	}
+
+	for _, tt := range tests {
+		if got := relativeToSource(tt.source, tt.path); got != tt.want {
+			t.Errorf("relativeToSource(%q, %q) = %q, want %q", tt.source, tt.path, got, tt.want)
+		}
+	}
+}
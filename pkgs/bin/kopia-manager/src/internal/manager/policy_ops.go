@@ -0,0 +1,363 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// PolicyInherit as a setting value removes it from a policy, so it is inherited from the
+// parent policy again
+const PolicyInherit = "inherit"
+
+// RetentionSettings are the retention counts a policy can set, most recent first
+var RetentionSettings = []string{"latest", "hourly", "daily", "weekly", "monthly", "annual"}
+
+// PolicyChanges describes edits to a policy. Empty fields are left unchanged.
+type PolicyChanges struct {
+	// Retention maps a name from RetentionSettings to a count or PolicyInherit
+	Retention map[string]string
+	// Compression is a compressor name, "none" or PolicyInherit
+	Compression string
+	// AddIgnore and RemoveIgnore edit the ignore rules; ClearIgnore removes all of them first
+	AddIgnore    []string
+	RemoveIgnore []string
+	ClearIgnore  bool
+	// SnapshotInterval is a duration such as "6h", or PolicyInherit
+	SnapshotInterval string
+	// SnapshotTimes is a comma-separated list of HH:MM times of day, or PolicyInherit
+	SnapshotTimes string
+}
+
+// IsEmpty reports whether no changes are requested
+func (c PolicyChanges) IsEmpty() bool {
+	return len(c.Retention) == 0 && c.Compression == "" && len(c.AddIgnore) == 0 && len(c.RemoveIgnore) == 0 &&
+		!c.ClearIgnore && c.SnapshotInterval == "" && c.SnapshotTimes == ""
+}
+
+// RetentionPreviewEntry is a snapshot and whether the retention policy keeps it
+type RetentionPreviewEntry struct {
+	Snapshot SnapshotSummary `json:"snapshot"`
+	Keep     bool            `json:"keep"`
+	Reasons  []string        `json:"reasons,omitempty"`
+}
+
+// RetentionPreview lists which snapshots of a source the retention policy would expire
+type RetentionPreview struct {
+	Source    string                  `json:"source"`
+	Retention string                  `json:"retention"`
+	Entries   []RetentionPreviewEntry `json:"entries"`
+	Expired   int                     `json:"expired"`
+}
+
+// SetPolicy applies changes to the policy defined for target and returns a description of
+// each change. Target is "global", "@host", "user@host", "user@host:/path" or a path on
+// this host.
+func (ro *RepositoryOps) SetPolicy(target string, changes PolicyChanges) ([]string, error) {
+	if changes.IsEmpty() {
+		return nil, fmt.Errorf("no policy changes given")
+	}
+
+	ctx := context.Background()
+	r, err := ro.km.openRepository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close(ctx)
+
+	si, err := parsePolicyTarget(r, target)
+	if err != nil {
+		return nil, err
+	}
+
+	pol, err := policy.GetDefinedPolicy(ctx, r, si)
+	if errors.Is(err, policy.ErrPolicyNotFound) {
+		pol = &policy.Policy{}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load policy for %s: %w", si, err)
+	}
+
+	applied, err := changes.apply(pol)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.WriteSession(ctx, r, repo.WriteSessionOptions{
+		Purpose: "set-policy",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		return policy.SetPolicy(ctx, w, si, pol)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save policy for %s: %w", si, err)
+	}
+
+	return applied, nil
+}
+
+// PreviewRetention computes which existing snapshots of the sources under target the
+// retention policy would expire, with changes applied to target's policy first. Nothing
+// is written or deleted.
+func (ro *RepositoryOps) PreviewRetention(target string, changes PolicyChanges) ([]RetentionPreview, error) {
+	ctx := context.Background()
+	r, err := ro.km.openRepository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close(ctx)
+
+	si, err := parsePolicyTarget(r, target)
+	if err != nil {
+		return nil, err
+	}
+
+	sources, err := snapshot.ListSources(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sources: %w", err)
+	}
+
+	var previews []RetentionPreview
+	for _, source := range sources {
+		if !policyAppliesTo(si, source) {
+			continue
+		}
+
+		effective, err := effectivePolicyWithChanges(ctx, r, source, si, changes)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots, err := sortedSnapshots(ctx, r, source)
+		if err != nil {
+			return nil, err
+		}
+		effective.RetentionPolicy.ComputeRetentionReasons(snapshots)
+
+		preview := RetentionPreview{
+			Source:    source.String(),
+			Retention: retentionCounts(effective.RetentionPolicy).String(),
+		}
+		for i := len(snapshots) - 1; i >= 0; i-- {
+			snap := snapshots[i]
+			entry := RetentionPreviewEntry{
+				Snapshot: manifestToSummary(snap),
+				Reasons:  policy.CompactRetentionReasons(snap.RetentionReasons),
+			}
+			for _, pin := range snap.Pins {
+				entry.Reasons = append(entry.Reasons, "pinned: "+pin)
+			}
+			entry.Keep = len(entry.Reasons) > 0
+			if !entry.Keep {
+				preview.Expired++
+			}
+			preview.Entries = append(preview.Entries, entry)
+		}
+		previews = append(previews, preview)
+	}
+
+	if len(previews) == 0 {
+		return nil, fmt.Errorf("no snapshot sources are covered by the policy for %s", si)
+	}
+	return previews, nil
+}
+
+// effectivePolicyWithChanges merges the policy hierarchy of source as if changes had been
+// applied to the policy defined for target
+func effectivePolicyWithChanges(ctx context.Context, r repo.Repository, source, target snapshot.SourceInfo, changes PolicyChanges) (*policy.Policy, error) {
+	policies, err := policy.GetPolicyHierarchy(ctx, r, source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policies for %s: %w", source, err)
+	}
+
+	if !changes.IsEmpty() {
+		index := slices.IndexFunc(policies, func(p *policy.Policy) bool { return p.Target() == target })
+		if index < 0 {
+			// Not defined yet, insert it where it belongs in most-specific-first order
+			index = slices.IndexFunc(policies, func(p *policy.Policy) bool {
+				return policySpecificity(p.Target()) < policySpecificity(target)
+			})
+			if index < 0 {
+				index = len(policies)
+			}
+			policies = slices.Insert(policies, index, &policy.Policy{Labels: policy.LabelsForSource(target)})
+		}
+		if _, err := changes.apply(policies[index]); err != nil {
+			return nil, err
+		}
+	}
+
+	effective, _ := policy.MergePolicies(policies, source)
+	return effective, nil
+}
+
+// policySpecificity orders policy targets: paths (deeper is more specific), then
+// user@host, host and global
+func policySpecificity(si snapshot.SourceInfo) int {
+	switch {
+	case si.Path != "":
+		components := strings.FieldsFunc(filepath.ToSlash(si.Path), func(r rune) bool { return r == '/' })
+		return 3 + len(components)
+	case si.UserName != "":
+		return 2
+	case si.Host != "":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// policyAppliesTo reports whether the policy for target is part of source's hierarchy
+func policyAppliesTo(target, source snapshot.SourceInfo) bool {
+	if target.Host != "" && target.Host != source.Host {
+		return false
+	}
+	if target.UserName != "" && target.UserName != source.UserName {
+		return false
+	}
+	if target.Path == "" {
+		return true
+	}
+	_, ok := sourceRelative(target.Path, source.Path)
+	return ok
+}
+
+// parsePolicyTarget parses a policy target, defaulting to this host and user for paths
+func parsePolicyTarget(r repo.Repository, target string) (snapshot.SourceInfo, error) {
+	if target == "" || target == "global" {
+		return policy.GlobalPolicySourceInfo, nil
+	}
+
+	clientOpts := r.ClientOptions()
+	si, err := snapshot.ParseSourceInfo(target, clientOpts.Hostname, clientOpts.Username)
+	if err != nil {
+		return snapshot.SourceInfo{}, fmt.Errorf("invalid policy target %q: %w", target, err)
+	}
+	return si, nil
+}
+
+// apply edits pol and describes each change
+func (c PolicyChanges) apply(pol *policy.Policy) ([]string, error) {
+	var applied []string
+
+	for name := range c.Retention {
+		if !slices.Contains(RetentionSettings, name) {
+			return nil, fmt.Errorf("unknown retention setting %q", name)
+		}
+	}
+	for _, name := range RetentionSettings {
+		value, ok := c.Retention[name]
+		if !ok {
+			continue
+		}
+		field := retentionField(&pol.RetentionPolicy, name)
+		if value == PolicyInherit {
+			*field = nil
+		} else {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid keep-%s value %q, expected a count or %q", name, value, PolicyInherit)
+			}
+			count := policy.OptionalInt(n)
+			*field = &count
+		}
+		applied = append(applied, fmt.Sprintf("keep-%s: %s", name, value))
+	}
+
+	if c.Compression != "" {
+		switch {
+		case c.Compression == PolicyInherit:
+			pol.CompressionPolicy.CompressorName = ""
+		case c.Compression == "none" || compression.ByName[compression.Name(c.Compression)] != nil:
+			pol.CompressionPolicy.CompressorName = compression.Name(c.Compression)
+		default:
+			return nil, fmt.Errorf("unknown compression %q", c.Compression)
+		}
+		applied = append(applied, "compression: "+c.Compression)
+	}
+
+	if c.ClearIgnore {
+		pol.FilesPolicy.IgnoreRules = nil
+		applied = append(applied, "ignore rules cleared")
+	}
+	for _, rule := range c.RemoveIgnore {
+		if i := slices.Index(pol.FilesPolicy.IgnoreRules, rule); i >= 0 {
+			pol.FilesPolicy.IgnoreRules = slices.Delete(pol.FilesPolicy.IgnoreRules, i, i+1)
+			applied = append(applied, "ignore removed: "+rule)
+		}
+	}
+	for _, rule := range c.AddIgnore {
+		if !slices.Contains(pol.FilesPolicy.IgnoreRules, rule) {
+			pol.FilesPolicy.IgnoreRules = append(pol.FilesPolicy.IgnoreRules, rule)
+			applied = append(applied, "ignore added: "+rule)
+		}
+	}
+
+	if c.SnapshotInterval != "" {
+		if c.SnapshotInterval == PolicyInherit {
+			pol.SchedulingPolicy.SetInterval(0)
+		} else {
+			interval, err := time.ParseDuration(c.SnapshotInterval)
+			if err != nil || interval < time.Minute {
+				return nil, fmt.Errorf("invalid snapshot interval %q, expected a duration of at least 1m", c.SnapshotInterval)
+			}
+			pol.SchedulingPolicy.SetInterval(interval)
+		}
+		applied = append(applied, "snapshot interval: "+c.SnapshotInterval)
+	}
+
+	if c.SnapshotTimes != "" {
+		pol.SchedulingPolicy.TimesOfDay = nil
+		if c.SnapshotTimes != PolicyInherit {
+			for _, s := range strings.Split(c.SnapshotTimes, ",") {
+				var tod policy.TimeOfDay
+				if err := tod.Parse(strings.TrimSpace(s)); err != nil {
+					return nil, fmt.Errorf("invalid snapshot time %q: %w", s, err)
+				}
+				pol.SchedulingPolicy.TimesOfDay = append(pol.SchedulingPolicy.TimesOfDay, tod)
+			}
+			pol.SchedulingPolicy.TimesOfDay = policy.SortAndDedupeTimesOfDay(pol.SchedulingPolicy.TimesOfDay)
+		}
+		applied = append(applied, "snapshot times: "+c.SnapshotTimes)
+	}
+
+	return applied, nil
+}
+
+// retentionField returns the policy field for a retention setting name
+func retentionField(rp *policy.RetentionPolicy, name string) **policy.OptionalInt {
+	switch name {
+	case "latest":
+		return &rp.KeepLatest
+	case "hourly":
+		return &rp.KeepHourly
+	case "daily":
+		return &rp.KeepDaily
+	case "weekly":
+		return &rp.KeepWeekly
+	case "monthly":
+		return &rp.KeepMonthly
+	default:
+		return &rp.KeepAnnual
+	}
+}
+
+// retentionCounts returns the retention counts of a policy, treating unset as zero
+func retentionCounts(rp policy.RetentionPolicy) RetentionCounts {
+	return RetentionCounts{
+		Latest:  rp.KeepLatest.OrDefault(0),
+		Hourly:  rp.KeepHourly.OrDefault(0),
+		Daily:   rp.KeepDaily.OrDefault(0),
+		Weekly:  rp.KeepWeekly.OrDefault(0),
+		Monthly: rp.KeepMonthly.OrDefault(0),
+		Annual:  rp.KeepAnnual.OrDefault(0),
+	}
+}
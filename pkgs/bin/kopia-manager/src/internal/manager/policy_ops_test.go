@@ -0,0 +1,205 @@
+package manager
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestPolicyChangesApply(t *testing.T) {
+	keep := func(n int) *policy.OptionalInt {
+		v := policy.OptionalInt(n)
+		return &v
+	}
+
+	tests := []struct {
+		name    string
+		initial policy.Policy
+		changes PolicyChanges
+		check   func(t *testing.T, pol *policy.Policy)
+		applied []string
+		wantErr bool
+	}{
+		{
+			name:    "retention counts and inherit",
+			initial: policy.Policy{RetentionPolicy: policy.RetentionPolicy{KeepDaily: keep(7)}},
+			changes: PolicyChanges{Retention: map[string]string{"daily": PolicyInherit, "latest": "3"}},
+			check: func(t *testing.T, pol *policy.Policy) {
+				if pol.RetentionPolicy.KeepDaily != nil || pol.RetentionPolicy.KeepLatest.OrDefault(0) != 3 {
+					t.Errorf("retention = %+v, want latest 3 and daily inherited", pol.RetentionPolicy)
+				}
+			},
+			applied: []string{"keep-latest: 3", "keep-daily: inherit"},
+		},
+		{
+			name:    "unknown retention setting",
+			changes: PolicyChanges{Retention: map[string]string{"yearly": "1"}},
+			wantErr: true,
+		},
+		{
+			name:    "negative retention count",
+			changes: PolicyChanges{Retention: map[string]string{"weekly": "-1"}},
+			wantErr: true,
+		},
+		{
+			name:    "ignore rules",
+			initial: policy.Policy{FilesPolicy: policy.FilesPolicy{IgnoreRules: []string{"*.tmp", "cache/"}}},
+			changes: PolicyChanges{RemoveIgnore: []string{"cache/", "missing"}, AddIgnore: []string{"*.tmp", "*.log"}},
+			check: func(t *testing.T, pol *policy.Policy) {
+				if want := []string{"*.tmp", "*.log"}; !slices.Equal(pol.FilesPolicy.IgnoreRules, want) {
+					t.Errorf("ignore rules = %v, want %v", pol.FilesPolicy.IgnoreRules, want)
+				}
+			},
+			applied: []string{"ignore removed: cache/", "ignore added: *.log"},
+		},
+		{
+			name:    "compression",
+			changes: PolicyChanges{Compression: "zstd"},
+			check: func(t *testing.T, pol *policy.Policy) {
+				if pol.CompressionPolicy.CompressorName != "zstd" {
+					t.Errorf("compressor = %q, want zstd", pol.CompressionPolicy.CompressorName)
+				}
+			},
+			applied: []string{"compression: zstd"},
+		},
+		{
+			name:    "unknown compression",
+			changes: PolicyChanges{Compression: "rar"},
+			wantErr: true,
+		},
+		{
+			name:    "schedule",
+			changes: PolicyChanges{SnapshotInterval: "6h", SnapshotTimes: "18:30, 06:00,18:30"},
+			check: func(t *testing.T, pol *policy.Policy) {
+				if pol.SchedulingPolicy.Interval() != 6*time.Hour {
+					t.Errorf("interval = %v, want 6h", pol.SchedulingPolicy.Interval())
+				}
+				want := []policy.TimeOfDay{{Hour: 6}, {Hour: 18, Minute: 30}}
+				if !slices.Equal(pol.SchedulingPolicy.TimesOfDay, want) {
+					t.Errorf("times = %v, want %v", pol.SchedulingPolicy.TimesOfDay, want)
+				}
+			},
+			applied: []string{"snapshot interval: 6h", "snapshot times: 18:30, 06:00,18:30"},
+		},
+		{
+			name:    "interval too short",
+			changes: PolicyChanges{SnapshotInterval: "30s"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pol := tt.initial
+			applied, err := tt.changes.apply(&pol)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("apply() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply() error: %v", err)
+			}
+			if !slices.Equal(applied, tt.applied) {
+				t.Errorf("applied = %q, want %q", applied, tt.applied)
+			}
+			tt.check(t, &pol)
+		})
+	}
+}
+
+func TestPolicyAppliesTo(t *testing.T) {
+	source := snapshot.SourceInfo{Host: "nas", UserName: "root", Path: "/srv/data"}
+
+	tests := []struct {
+		target snapshot.SourceInfo
+		want   bool
+	}{
+		{policy.GlobalPolicySourceInfo, true},
+		{snapshot.SourceInfo{Host: "nas"}, true},
+		{snapshot.SourceInfo{Host: "laptop"}, false},
+		{snapshot.SourceInfo{Host: "nas", UserName: "root"}, true},
+		{snapshot.SourceInfo{Host: "nas", UserName: "alice"}, false},
+		{snapshot.SourceInfo{Host: "nas", UserName: "root", Path: "/srv"}, true},
+		{snapshot.SourceInfo{Host: "nas", UserName: "root", Path: "/srv/data"}, true},
+		{snapshot.SourceInfo{Host: "nas", UserName: "root", Path: "/srv/data/sub"}, false},
+		{snapshot.SourceInfo{Host: "nas", UserName: "root", Path: "/srv/dat"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := policyAppliesTo(tt.target, source); got != tt.want {
+			t.Errorf("policyAppliesTo(%s) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestPreviewRetention(t *testing.T) {
+	km := newTestManager(t)
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "a.txt"), "a")
+
+	var ids []string
+	for range 3 {
+		ids = append(ids, backup(t, km, src, BackupOptions{}))
+	}
+
+	keepLatest := map[string]string{"latest": "2"}
+	for _, name := range RetentionSettings[1:] {
+		keepLatest[name] = "0"
+	}
+
+	tests := []struct {
+		name    string
+		changes PolicyChanges
+		expired []string
+	}{
+		{name: "current policy keeps everything"},
+		{name: "keep latest two", changes: PolicyChanges{Retention: keepLatest}, expired: []string{ids[0]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previews, err := km.PreviewRetention(src, tt.changes)
+			if err != nil {
+				t.Fatalf("PreviewRetention() error: %v", err)
+			}
+			if len(previews) != 1 || len(previews[0].Entries) != 3 {
+				t.Fatalf("got previews %+v, want one source with 3 snapshots", previews)
+			}
+
+			preview := previews[0]
+			var expired []string
+			for _, e := range preview.Entries {
+				if !e.Keep {
+					expired = append(expired, e.Snapshot.ID)
+				} else if len(e.Reasons) == 0 {
+					t.Errorf("%s is kept without a reason", e.Snapshot.ID)
+				}
+			}
+			if !slices.Equal(expired, tt.expired) || preview.Expired != len(tt.expired) {
+				t.Errorf("expired %v (count %d), want %v", expired, preview.Expired, tt.expired)
+			}
+			if preview.Entries[0].Snapshot.ID != ids[2] {
+				t.Errorf("first entry is %s, want the newest snapshot %s", preview.Entries[0].Snapshot.ID, ids[2])
+			}
+		})
+	}
+
+	// The preview doesn't save the changes
+	previews, err := km.PreviewRetention("global", PolicyChanges{})
+	if err != nil {
+		t.Fatalf("PreviewRetention() error: %v", err)
+	}
+	if previews[0].Expired != 0 {
+		t.Errorf("preview changed the stored policy, %d snapshots expire", previews[0].Expired)
+	}
+
+	if _, err := km.PreviewRetention("nobody@elsewhere", PolicyChanges{}); err == nil {
+		t.Error("PreviewRetention() for a target without sources succeeded")
+	}
+}
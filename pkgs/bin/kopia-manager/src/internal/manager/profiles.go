@@ -0,0 +1,158 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// profileNamePattern restricts profile names to something safe for paths and shells
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Profile is a named repository connection
+type Profile struct {
+	Name            string `yaml:"-" json:"name"`
+	ConfigPath      string `yaml:"config" json:"config"`
+	PasswordPath    string `yaml:"passwordFile,omitempty" json:"passwordFile,omitempty"`
+	PasswordCommand string `yaml:"passwordCommand,omitempty" json:"passwordCommand,omitempty"`
+	Current         bool   `yaml:"-" json:"current"`
+}
+
+// ProfileConfig is the km config file holding the repository profiles
+type ProfileConfig struct {
+	Current  string             `yaml:"current,omitempty"`
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+
+	path string
+}
+
+// ProfileConfigPath returns the location of the km config file
+func ProfileConfigPath() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		homeDir, _ := os.UserHomeDir()
+		configDir = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configDir, AppName, "config.yaml")
+}
+
+// DefaultProfile returns the connection used when no profile is selected
+func DefaultProfile() Profile {
+	homeDir, _ := os.UserHomeDir()
+	return Profile{
+		ConfigPath:   filepath.Join(homeDir, ".config", "kopia", "repository.config"),
+		PasswordPath: filepath.Join(homeDir, ".config", "kopia", "repository.password"),
+	}
+}
+
+// LoadProfiles reads the km config file; a missing file has no profiles
+func LoadProfiles() (*ProfileConfig, error) {
+	pc := &ProfileConfig{path: ProfileConfigPath()}
+
+	data, err := os.ReadFile(pc.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return pc, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", pc.path, err)
+	}
+	if err := yaml.Unmarshal(data, pc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", pc.path, err)
+	}
+	return pc, nil
+}
+
+// Save writes the km config file
+func (pc *ProfileConfig) Save() error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(pc); err != nil {
+		return fmt.Errorf("failed to encode profiles: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(pc.path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(pc.path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", pc.path, err)
+	}
+	return nil
+}
+
+// Get returns a profile by name
+func (pc *ProfileConfig) Get(name string) (Profile, error) {
+	p, ok := pc.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q (see 'km profile list')", name)
+	}
+	p.Name = name
+	p.Current = name == pc.Current
+	return p, nil
+}
+
+// List returns the profiles sorted by name
+func (pc *ProfileConfig) List() []Profile {
+	profiles := make([]Profile, 0, len(pc.Profiles))
+	for name := range pc.Profiles {
+		p, _ := pc.Get(name)
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles
+}
+
+// Add stores a new profile. Relative paths are made absolute so the profile
+// works from any directory.
+func (pc *ProfileConfig) Add(p Profile) error {
+	if !profileNamePattern.MatchString(p.Name) {
+		return fmt.Errorf("invalid profile name %q, use letters, digits, '.', '_' and '-'", p.Name)
+	}
+	if _, exists := pc.Profiles[p.Name]; exists {
+		return fmt.Errorf("profile %q already exists", p.Name)
+	}
+
+	var err error
+	if p.ConfigPath, err = filepath.Abs(p.ConfigPath); err != nil {
+		return fmt.Errorf("invalid config path: %w", err)
+	}
+	if p.PasswordPath != "" {
+		if p.PasswordPath, err = filepath.Abs(p.PasswordPath); err != nil {
+			return fmt.Errorf("invalid password file path: %w", err)
+		}
+	}
+
+	if pc.Profiles == nil {
+		pc.Profiles = make(map[string]Profile)
+	}
+	pc.Profiles[p.Name] = p
+	return nil
+}
+
+// Use makes a profile the one used when --profile is not given
+func (pc *ProfileConfig) Use(name string) error {
+	if _, err := pc.Get(name); err != nil {
+		return err
+	}
+	pc.Current = name
+	return nil
+}
+
+// Resolve returns the connection for a profile name, the current profile if
+// name is empty, or the default kopia connection if no profile is current
+func (pc *ProfileConfig) Resolve(name string) (Profile, error) {
+	if name == "" {
+		name = pc.Current
+	}
+	if name == "" {
+		return DefaultProfile(), nil
+	}
+	return pc.Get(name)
+}
@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveProfile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	profiles, err := LoadProfiles()
+	if err != nil {
+		t.Fatalf("LoadProfiles() error: %v", err)
+	}
+	for _, name := range []string{"home", "nas"} {
+		if err := profiles.Add(Profile{Name: name, ConfigPath: filepath.Join("/srv", name+".config")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := profiles.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		current    string
+		profile    string
+		wantConfig string
+		wantErr    bool
+	}{
+		{name: "no profile", wantConfig: DefaultProfile().ConfigPath},
+		{name: "current profile", current: "home", wantConfig: "/srv/home.config"},
+		{name: "named profile", profile: "nas", wantConfig: "/srv/nas.config"},
+		{name: "named profile over current", current: "home", profile: "nas", wantConfig: "/srv/nas.config"},
+		{name: "unknown profile", profile: "missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc, err := LoadProfiles()
+			if err != nil {
+				t.Fatalf("LoadProfiles() error: %v", err)
+			}
+			pc.Current = tt.current
+
+			p, err := pc.Resolve(tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve(%q) error = %v, wantErr %v", tt.profile, err, tt.wantErr)
+			}
+			if p.ConfigPath != tt.wantConfig {
+				t.Errorf("Resolve(%q) config = %q, want %q", tt.profile, p.ConfigPath, tt.wantConfig)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package manager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/blob/s3"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// Storage backends supported by km repo create and connect
+const (
+	StorageFilesystem = "filesystem"
+	StorageS3         = "s3"
+)
+
+// StorageTypes lists the supported storage backends
+var StorageTypes = []string{StorageFilesystem, StorageS3}
+
+// defaultCacheSize is the content and metadata cache size of a new connection,
+// the same as the kopia CLI uses
+const defaultCacheSize = 5000 << 20
+
+// StorageOptions describes where a repository is stored
+type StorageOptions struct {
+	Type string
+
+	// Filesystem
+	Path string
+
+	// S3 and S3-compatible services such as MinIO
+	Bucket                 string
+	Prefix                 string
+	Endpoint               string
+	Region                 string
+	AccessKeyID            string
+	SecretAccessKey        string
+	SessionToken           string
+	DisableTLS             bool
+	DisableTLSVerification bool
+}
+
+// String describes the storage for messages
+func (o StorageOptions) String() string {
+	if o.Type == StorageS3 {
+		return fmt.Sprintf("s3://%s/%s at %s", o.Bucket, o.Prefix, o.Endpoint)
+	}
+	return o.Path
+}
+
+// open connects to the blob storage; isCreate creates a missing filesystem directory
+func (o StorageOptions) open(ctx context.Context, isCreate bool) (blob.Storage, error) {
+	var (
+		st  blob.Storage
+		err error
+	)
+
+	switch o.Type {
+	case StorageFilesystem:
+		if o.Path == "" {
+			return nil, fmt.Errorf("a repository path is required")
+		}
+		path, err := filepath.Abs(o.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repository path: %w", err)
+		}
+		st, err = filesystem.New(ctx, &filesystem.Options{Path: path}, isCreate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+	case StorageS3:
+		if o.Bucket == "" {
+			return nil, fmt.Errorf("a bucket is required")
+		}
+		st, err = s3.New(ctx, &s3.Options{
+			BucketName:      o.Bucket,
+			Prefix:          o.Prefix,
+			Endpoint:        o.Endpoint,
+			Region:          o.Region,
+			AccessKeyID:     o.AccessKeyID,
+			SecretAccessKey: o.SecretAccessKey,
+			SessionToken:    o.SessionToken,
+			DoNotUseTLS:     o.DisableTLS,
+			DoNotVerifyTLS:  o.DisableTLSVerification,
+		}, isCreate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open bucket %s: %w", o.Bucket, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported storage type %q (supported: filesystem, s3)", o.Type)
+	}
+
+	return st, nil
+}
+
+// CreateRepository initializes a new repository and connects the config file to it
+func (ro *RepositoryOps) CreateRepository(opts StorageOptions, password string) error {
+	ctx := context.Background()
+	if err := ro.checkNotConnected(); err != nil {
+		return err
+	}
+
+	st, err := opts.open(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer st.Close(ctx)
+
+	if err := repo.Initialize(ctx, st, &repo.NewRepositoryOptions{}, password); err != nil {
+		if errors.Is(err, repo.ErrAlreadyInitialized) {
+			return fmt.Errorf("a repository already exists at %s, use 'km repo connect'", opts)
+		}
+		return fmt.Errorf("failed to create repository: %w", err)
+	}
+
+	return ro.connect(ctx, st, password)
+}
+
+// ConnectRepository connects the config file to an existing repository
+func (ro *RepositoryOps) ConnectRepository(opts StorageOptions, password string) error {
+	ctx := context.Background()
+	if err := ro.checkNotConnected(); err != nil {
+		return err
+	}
+
+	st, err := opts.open(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer st.Close(ctx)
+
+	if err := ro.connect(ctx, st, password); err != nil {
+		if errors.Is(err, repo.ErrRepositoryNotInitialized) {
+			return fmt.Errorf("no repository at %s, use 'km repo create'", opts)
+		}
+		return err
+	}
+	return nil
+}
+
+// connect writes the config file; kopia removes it again if the repository can't be opened
+func (ro *RepositoryOps) connect(ctx context.Context, st blob.Storage, password string) error {
+	if err := os.MkdirAll(filepath.Dir(ro.km.ConfigPath), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	err := repo.Connect(ctx, ro.km.ConfigPath, st, password, &repo.ConnectOptions{
+		CachingOptions: content.CachingOptions{
+			ContentCacheSizeBytes:  defaultCacheSize,
+			MetadataCacheSizeBytes: defaultCacheSize,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to repository: %w", err)
+	}
+	return nil
+}
+
+// DisconnectRepository removes the config file and the local caches of the repository.
+// The password file is kept.
+func (ro *RepositoryOps) DisconnectRepository() error {
+	ro.km.closeRepository()
+
+	if _, err := os.Stat(ro.km.ConfigPath); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("not connected: %s does not exist", ro.km.ConfigPath)
+	}
+	if err := repo.Disconnect(context.Background(), ro.km.ConfigPath); err != nil {
+		return fmt.Errorf("failed to disconnect: %w", err)
+	}
+	return nil
+}
+
+// checkNotConnected refuses to replace the config of a connected repository
+func (ro *RepositoryOps) checkNotConnected() error {
+	if _, err := os.Stat(ro.km.ConfigPath); err == nil {
+		return fmt.Errorf("%s is already connected to a repository, run 'km repo disconnect' first or select another profile", ro.km.ConfigPath)
+	}
+	return nil
+}
+
+// RepositoryPassword returns the password from the configured credential providers
+func (ro *RepositoryOps) RepositoryPassword() (string, error) {
+	return ro.km.credentials().Password(context.Background())
+}
+
+// SavePassword writes the password to the password file of the connection
+func (ro *RepositoryOps) SavePassword(password string) error {
+	if ro.km.PasswordPath == "" {
+		return fmt.Errorf("no password file configured")
+	}
+	if err := os.MkdirAll(filepath.Dir(ro.km.PasswordPath), 0700); err != nil {
+		return fmt.Errorf("failed to create password directory: %w", err)
+	}
+	if err := os.WriteFile(ro.km.PasswordPath, []byte(password+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write password file: %w", err)
+	}
+	return nil
+}
+
+// GeneratePassword returns a random repository password
+func GeneratePassword() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
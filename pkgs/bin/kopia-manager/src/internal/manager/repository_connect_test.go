@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepositoryConnectRoundTrip(t *testing.T) {
+	// Keep kopia's default cache directory inside the test
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	dir := t.TempDir()
+	km := &KopiaManager{ConfigPath: filepath.Join(dir, "repository.config")}
+	storage := StorageOptions{Type: StorageFilesystem, Path: filepath.Join(dir, "repo")}
+
+	// Each step runs on the state the previous steps left behind
+	steps := []struct {
+		name      string
+		run       func() error
+		wantErr   string // part of the expected error message
+		connected bool
+	}{
+		{name: "create", run: func() error { return km.CreateRepository(storage, testPassword) }, connected: true},
+		{name: "create while connected", run: func() error { return km.CreateRepository(storage, testPassword) }, wantErr: "already connected", connected: true},
+		{name: "disconnect", run: km.DisconnectRepository},
+		{name: "disconnect again", run: km.DisconnectRepository, wantErr: "not connected"},
+		{name: "connect with a wrong password", run: func() error { return km.ConnectRepository(storage, "wrong") }, wantErr: "invalid repository password"},
+		{name: "create over an existing repository", run: func() error { return km.CreateRepository(storage, testPassword) }, wantErr: "already exists"},
+		{name: "connect", run: func() error { return km.ConnectRepository(storage, testPassword) }, connected: true},
+		{name: "connect to an empty directory", run: func() error {
+			if err := km.DisconnectRepository(); err != nil {
+				return err
+			}
+			empty := filepath.Join(dir, "empty")
+			if err := os.Mkdir(empty, 0o700); err != nil {
+				return err
+			}
+			return km.ConnectRepository(StorageOptions{Type: StorageFilesystem, Path: empty}, testPassword)
+		}, wantErr: "no repository at"},
+	}
+
+	for _, step := range steps {
+		err := step.run()
+		if step.wantErr == "" && err != nil {
+			t.Fatalf("%s: error: %v", step.name, err)
+		}
+		if step.wantErr != "" && (err == nil || !strings.Contains(err.Error(), step.wantErr)) {
+			t.Fatalf("%s: error = %v, want %q", step.name, err, step.wantErr)
+		}
+		_, statErr := os.Stat(km.ConfigPath)
+		if connected := statErr == nil; connected != step.connected {
+			t.Fatalf("%s: connected = %v, want %v", step.name, connected, step.connected)
+		}
+	}
+}
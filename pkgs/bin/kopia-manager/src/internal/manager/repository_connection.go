@@ -2,20 +2,28 @@ package manager
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/kopia/kopia/repo"
 )
 
+// connection is the repository NewKopiaManager connects to, set from the global flags
+var connection = DefaultProfile()
+
+// SetConnection selects the repository and password sources used by NewKopiaManager
+func SetConnection(p Profile) {
+	connection = p
+}
+
 // NewKopiaManager creates a new KopiaManager instance
 func NewKopiaManager() *KopiaManager {
-	homeDir, _ := os.UserHomeDir()
 	return &KopiaManager{
-		ConfigPath:   filepath.Join(homeDir, ".config", "kopia", "repository.config"),
-		PasswordPath: filepath.Join(homeDir, ".config", "kopia", "repository.password"),
+		ConfigPath:      connection.ConfigPath,
+		PasswordPath:    connection.PasswordPath,
+		PasswordCommand: connection.PasswordCommand,
 	}
 }
 
@@ -26,15 +34,15 @@ func (km *KopiaManager) openRepository(ctx context.Context) (repo.Repository, er
 
 	// Return cached connection if available
 	if km.cachedRepo != nil {
-		return km.cachedRepo, nil
+		return km.sharedRepository(km.cachedRepo), nil
 	}
 
-	password, err := os.ReadFile(km.PasswordPath)
+	password, err := km.credentials().Password(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read password file: %w", err)
+		return nil, err
 	}
 
-	r, err := repo.Open(ctx, km.ConfigPath, strings.TrimSpace(string(password)), &repo.Options{})
+	r, err := repo.Open(ctx, km.ConfigPath, password, &repo.Options{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to open repository: %w", err)
 	}
@@ -43,7 +51,105 @@ func (km *KopiaManager) openRepository(ctx context.Context) (repo.Repository, er
 	km.cachedRepo = r
 	km.cachedCtx = ctx
 
-	return r, nil
+	return km.sharedRepository(r), nil
+}
+
+// openUncachedRepository opens a separate read-only connection without the local cache,
+// so every content is read from storage. It uses a copy of the config without caching
+// options, which is removed again by release.
+func (km *KopiaManager) openUncachedRepository(ctx context.Context) (dr repo.DirectRepository, release func(), err error) {
+	lc, err := repo.LoadConfigFromFile(km.ConfigPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load repository config: %w", err)
+	}
+	if lc.APIServer != nil {
+		return nil, nil, fmt.Errorf("reading contents from storage requires a direct repository connection")
+	}
+	lc.Caching = nil
+	lc.ReadOnly = true
+
+	dir, err := os.MkdirTemp("", AppName+"-uncached-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	data, err := json.Marshal(lc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode repository config: %w", err)
+	}
+	configPath := filepath.Join(dir, "repository.config")
+	if err := os.WriteFile(configPath, data, 0o600); err != nil {
+		return nil, nil, err
+	}
+
+	password, err := km.credentials().Password(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := repo.Open(ctx, configPath, password, &repo.Options{DisableRepositoryLog: true})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+	dr, ok := r.(repo.DirectRepository)
+	if !ok {
+		r.Close(ctx)
+		return nil, nil, fmt.Errorf("reading contents from storage requires a direct repository connection")
+	}
+
+	return dr, func() {
+		dr.Close(ctx)
+		os.RemoveAll(dir)
+	}, nil
+}
+
+// KeepOpen keeps the cached repository connection open across operations until Close.
+// Without it the first operation to finish closes the connection.
+func (km *KopiaManager) KeepOpen() {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	km.keepOpen = true
+}
+
+// Close closes the repository connection kept open by KeepOpen
+func (km *KopiaManager) Close() {
+	km.closeRepository()
+}
+
+// sharedRepository hides Close from the operations while the connection is kept open
+func (km *KopiaManager) sharedRepository(r repo.Repository) repo.Repository {
+	if !km.keepOpen {
+		return r
+	}
+	// Keep the direct repository interfaces visible for maintenance
+	if dw, ok := r.(repo.DirectRepositoryWriter); ok {
+		return sharedDirectRepository{dw}
+	}
+	return sharedRepository{r}
+}
+
+// sharedRepository is a repository connection owned by the KopiaManager cache
+type sharedRepository struct {
+	repo.Repository
+}
+
+// Close leaves the shared connection open
+func (sharedRepository) Close(context.Context) error {
+	return nil
+}
+
+// sharedDirectRepository is a direct repository connection owned by the KopiaManager cache
+type sharedDirectRepository struct {
+	repo.DirectRepositoryWriter
+}
+
+// Close leaves the shared connection open
+func (sharedDirectRepository) Close(context.Context) error {
+	return nil
 }
 
 // closeRepository closes the cached repository connection
@@ -3,6 +3,7 @@ package manager
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"kopia-manager/internal/ui"
 
@@ -22,43 +23,72 @@ func NewRepositoryOps(km *KopiaManager) *RepositoryOps {
 	return &RepositoryOps{km: km}
 }
 
-// GetStatus returns the repository status
-func (ro *RepositoryOps) GetStatus() (string, error) {
+// RepositoryStatus returns the repository connection and its sources
+func (ro *RepositoryOps) RepositoryStatus() (*RepositoryStatus, error) {
 	ctx := context.Background()
 	r, err := ro.km.openRepository(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer r.Close(ctx)
 
 	clientOpts := r.ClientOptions()
+	status := &RepositoryStatus{
+		ConfigFile:  ro.km.ConfigPath,
+		Description: clientOpts.Description,
+		Hostname:    clientOpts.Hostname,
+		Username:    clientOpts.Username,
+		ReadOnly:    clientOpts.ReadOnly,
+		Sources:     []SourceStatus{},
+	}
 
 	// Get repository statistics
 	sources, err := snapshot.ListSources(ctx, r)
 	if err != nil {
-		return "", fmt.Errorf("failed to list sources: %w", err)
+		return nil, fmt.Errorf("failed to list sources: %w", err)
 	}
 
-	// Count total snapshots
-	totalSnapshots := 0
 	for _, source := range sources {
 		snapshots, err := snapshot.ListSnapshots(ctx, r, source)
 		if err != nil {
 			continue
 		}
-		totalSnapshots += len(snapshots)
+
+		sourceStatus := SourceStatus{
+			Path:      source.Path,
+			Hostname:  source.Host,
+			Username:  source.UserName,
+			Snapshots: len(snapshots),
+		}
+		if len(snapshots) > 0 {
+			latest := manifestToSummary(snapshot.SortByTime(snapshots, true)[0])
+			sourceStatus.Latest = &latest
+		}
+
+		status.Sources = append(status.Sources, sourceStatus)
+		status.Snapshots += len(snapshots)
+	}
+
+	return status, nil
+}
+
+// GetStatus returns the repository status
+func (ro *RepositoryOps) GetStatus() (string, error) {
+	status, err := ro.RepositoryStatus()
+	if err != nil {
+		return "", err
 	}
 
 	// Create table with repository status
 	headers := []string{"Property", "Value"}
 	rows := [][]string{
-		{"Config file", ro.km.ConfigPath},
-		{"Description", clientOpts.Description},
-		{"Hostname", clientOpts.Hostname},
-		{"Username", clientOpts.Username},
-		{"Read-only", fmt.Sprintf("%v", clientOpts.ReadOnly)},
-		{"Sources", fmt.Sprintf("%d", len(sources))},
-		{"Snapshots", fmt.Sprintf("%d", totalSnapshots)},
+		{"Config file", status.ConfigFile},
+		{"Description", status.Description},
+		{"Hostname", status.Hostname},
+		{"Username", status.Username},
+		{"Read-only", fmt.Sprintf("%v", status.ReadOnly)},
+		{"Sources", fmt.Sprintf("%d", len(status.Sources))},
+		{"Snapshots", fmt.Sprintf("%d", status.Snapshots)},
 	}
 
 	return ui.RenderTable("Repository Status", headers, rows), nil
@@ -105,159 +135,111 @@ func (ro *RepositoryOps) RunMaintenance(unsafe bool) error {
 	return nil
 }
 
-// VerifyRepository verifies repository integrity
-func (ro *RepositoryOps) VerifyRepository() error {
+// PolicyInfo returns the effective policy for a path and which settings it defines itself
+func (ro *RepositoryOps) PolicyInfo(path string) (*PolicyInfo, error) {
 	ctx := context.Background()
 	r, err := ro.km.openRepository(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer r.Close(ctx)
 
-	// Create and start spinner
-	spin := ui.NewSpinner("Verifying repository integrity")
-	spin.Start()
-
-	// Get all sources and verify their snapshots
-	sources, err := snapshot.ListSources(ctx, r)
+	// Get client options to build complete source info
+	clientOpts := r.ClientOptions()
+	sourceInfo, err := snapshot.ParseSourceInfo(path, clientOpts.Hostname, clientOpts.Username)
 	if err != nil {
-		spin.Fail(fmt.Sprintf("Failed to list sources: %v", err))
-		return fmt.Errorf("failed to list sources: %w", err)
+		return nil, fmt.Errorf("failed to parse source info: %w", err)
 	}
 
-	var totalSnapshots, verifiedSnapshots int
-	var failedSnapshots []string
+	// Get detailed policy information including sources
+	effective, definition, _, err := policy.GetEffectivePolicy(ctx, r, sourceInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy: %w", err)
+	}
 
-	for _, source := range sources {
-		snapshots, err := snapshot.ListSnapshots(ctx, r, source)
-		if err != nil {
-			ui.Warningf("Warning: failed to list snapshots for source %s: %v", source, err)
-			continue
-		}
+	// Helper function to check if a setting is defined for this target
+	isDefinedForTarget := func(defSource snapshot.SourceInfo) bool {
+		return definition != nil && defSource.Path == sourceInfo.Path && defSource.Host == sourceInfo.Host && defSource.UserName == sourceInfo.UserName
+	}
 
-		for _, snap := range snapshots {
-			totalSnapshots++
-			spin.UpdateMessage(fmt.Sprintf("Verifying repository integrity (%d/%d)", totalSnapshots, len(snapshots)*len(sources)))
+	info := &PolicyInfo{
+		Source:      sourceInfo.String(),
+		Compression: string(effective.CompressionPolicy.CompressorName),
+		Retention:   retentionCounts(effective.RetentionPolicy),
+		IgnoreRules: effective.FilesPolicy.IgnoreRules,
+		Defined:     []string{},
+	}
+	if info.IgnoreRules == nil {
+		info.IgnoreRules = []string{}
+	}
+	if interval := effective.SchedulingPolicy.Interval(); interval > 0 {
+		info.SnapshotInterval = interval.String()
+	}
+	for _, t := range effective.SchedulingPolicy.TimesOfDay {
+		info.SnapshotTimes = append(info.SnapshotTimes, t.String())
+	}
 
-			_, err := r.VerifyObject(ctx, snap.RootObjectID())
-			if err != nil {
-				failedSnapshots = append(failedSnapshots, string(snap.ID))
-			} else {
-				verifiedSnapshots++
+	if definition != nil {
+		if isDefinedForTarget(definition.CompressionPolicy.CompressorName) {
+			info.Defined = append(info.Defined, "compression")
+		}
+		rd := definition.RetentionPolicy
+		for _, src := range []snapshot.SourceInfo{rd.KeepLatest, rd.KeepHourly, rd.KeepDaily, rd.KeepWeekly, rd.KeepMonthly, rd.KeepAnnual} {
+			if isDefinedForTarget(src) {
+				info.Defined = append(info.Defined, "retention")
+				break
 			}
 		}
-	}
-
-	spin.Stop()
-
-	// Print any failed snapshots
-	if len(failedSnapshots) > 0 {
-		ui.Warning("Failed to verify the following snapshots:")
-		for _, id := range failedSnapshots {
-			ui.Itemf("- %s", id)
+		if isDefinedForTarget(definition.FilesPolicy.IgnoreRules) {
+			info.Defined = append(info.Defined, "ignoreRules")
+		}
+		if isDefinedForTarget(definition.SchedulingPolicy.IntervalSeconds) {
+			info.Defined = append(info.Defined, "snapshotInterval")
+		}
+		if isDefinedForTarget(definition.SchedulingPolicy.TimesOfDay) {
+			info.Defined = append(info.Defined, "snapshotTimes")
 		}
 	}
 
-	ui.Summaryf("Verification completed: %d/%d snapshots verified successfully", verifiedSnapshots, totalSnapshots)
-	return nil
+	return info, nil
 }
 
-// ShowPolicy shows the backup policy for a path
+// ShowPolicy returns the effective policy for a path as a table
 func (ro *RepositoryOps) ShowPolicy(path string) (string, error) {
-	ctx := context.Background()
-	r, err := ro.km.openRepository(ctx)
+	info, err := ro.PolicyInfo(path)
 	if err != nil {
 		return "", err
 	}
-	defer r.Close(ctx)
-
-	// Get client options to build complete source info
-	clientOpts := r.ClientOptions()
-	sourceInfo, err := snapshot.ParseSourceInfo(path, clientOpts.Hostname, clientOpts.Username)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse source info: %w", err)
-	}
 
-	// Get detailed policy information including sources
-	effective, definition, _, err := policy.GetEffectivePolicy(ctx, r, sourceInfo)
-	if err != nil {
-		return "", fmt.Errorf("failed to get policy: %w", err)
-	}
-
-	// Helper function to check if a setting is defined for this target
-	isDefinedForTarget := func(defSource snapshot.SourceInfo) bool {
-		return defSource.Path == sourceInfo.Path && defSource.Host == sourceInfo.Host && defSource.UserName == sourceInfo.UserName
+	source := func(setting string) string {
+		if info.IsDefined(setting) {
+			return "Defined"
+		}
+		return "Inherited"
 	}
 
 	// Create table
-	title := fmt.Sprintf("Policy for %s@%s:%s", sourceInfo.UserName, sourceInfo.Host, sourceInfo.Path)
+	title := fmt.Sprintf("Policy for %s", info.Source)
 	headers := []string{"Setting", "Value", "Source"}
-	var rows [][]string
-
-	// Compression
-	compSource := "Inherited"
-	if definition != nil && definition.CompressionPolicy.CompressorName.Path != "" {
-		if isDefinedForTarget(definition.CompressionPolicy.CompressorName) {
-			compSource = "Defined"
-		}
+	rows := [][]string{
+		{"Compression", info.Compression, source("compression")},
+		{"Retention", info.Retention.String(), source("retention")},
 	}
-	rows = append(rows, []string{"Compression", string(effective.CompressionPolicy.CompressorName), compSource})
 
-	// Retention policy
-	annual := 0
-	if effective.RetentionPolicy.KeepAnnual != nil {
-		annual = effective.RetentionPolicy.KeepAnnual.OrDefault(0)
-	}
-	monthly := 0
-	if effective.RetentionPolicy.KeepMonthly != nil {
-		monthly = effective.RetentionPolicy.KeepMonthly.OrDefault(0)
+	if info.SnapshotInterval != "" {
+		rows = append(rows, []string{"Snapshot Interval", info.SnapshotInterval, source("snapshotInterval")})
 	}
-	weekly := 0
-	if effective.RetentionPolicy.KeepWeekly != nil {
-		weekly = effective.RetentionPolicy.KeepWeekly.OrDefault(0)
-	}
-	daily := 0
-	if effective.RetentionPolicy.KeepDaily != nil {
-		daily = effective.RetentionPolicy.KeepDaily.OrDefault(0)
-	}
-	hourly := 0
-	if effective.RetentionPolicy.KeepHourly != nil {
-		hourly = effective.RetentionPolicy.KeepHourly.OrDefault(0)
-	}
-	latest := 0
-	if effective.RetentionPolicy.KeepLatest != nil {
-		latest = effective.RetentionPolicy.KeepLatest.OrDefault(0)
+	if len(info.SnapshotTimes) > 0 {
+		rows = append(rows, []string{"Snapshot Times", strings.Join(info.SnapshotTimes, ", "), source("snapshotTimes")})
 	}
 
-	retentionSource := "Inherited"
-	if definition != nil && definition.RetentionPolicy.KeepDaily.Path != "" {
-		if isDefinedForTarget(definition.RetentionPolicy.KeepDaily) {
-			retentionSource = "Defined"
-		}
-	}
-	retentionValue := fmt.Sprintf("%dy/%dm/%dw/%dd/%dh/%dl", annual, monthly, weekly, daily, hourly, latest)
-	rows = append(rows, []string{"Retention", retentionValue, retentionSource})
-
-	// Files policy
-	if len(effective.FilesPolicy.IgnoreRules) > 0 {
-		ignoreSource := "Inherited"
-		if definition != nil && definition.FilesPolicy.IgnoreRules.Path != "" {
-			if isDefinedForTarget(definition.FilesPolicy.IgnoreRules) {
-				ignoreSource = "Defined"
-			}
-		}
-		// Show first rule, then add additional rows for remaining rules
-		for i, rule := range effective.FilesPolicy.IgnoreRules {
-			setting := "Ignore Rules"
-			if i > 0 {
-				setting = "" // Empty for continuation rows
-			}
-			source := ignoreSource
-			if i > 0 {
-				source = "" // Empty for continuation rows
-			}
-			rows = append(rows, []string{setting, rule, source})
+	// Show first rule, then add additional rows for remaining rules
+	for i, rule := range info.IgnoreRules {
+		setting, ruleSource := "Ignore Rules", source("ignoreRules")
+		if i > 0 {
+			setting, ruleSource = "", "" // Empty for continuation rows
 		}
+		rows = append(rows, []string{setting, rule, ruleSource})
 	}
 
 	return ui.RenderTable(title, headers, rows), nil
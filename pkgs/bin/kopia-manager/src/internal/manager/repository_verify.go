@@ -0,0 +1,339 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"path"
+	"sort"
+	"sync"
+
+	"kopia-manager/internal/ui"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// DefaultVerifyParallelism is how many objects km verify checks at once
+const DefaultVerifyParallelism = 8
+
+// verifyProgressInterval is how many entries are walked between progress updates
+const verifyProgressInterval = 1000
+
+// VerifyOptions selects the snapshots to verify and how deeply
+type VerifyOptions struct {
+	FindOptions
+	// Parallelism bounds the number of objects verified at once
+	Parallelism int
+	// SamplePercent is the percentage of contents downloaded from storage, bypassing the
+	// local cache, and hashed
+	SamplePercent float64
+}
+
+// VerifyFailure is an entry of a snapshot that failed verification
+type VerifyFailure struct {
+	Path     string `json:"path"`
+	ObjectID string `json:"objectId,omitempty"`
+	Error    string `json:"error"`
+}
+
+// SnapshotVerification is the verification result of one snapshot
+type SnapshotVerification struct {
+	Snapshot SnapshotSummary `json:"snapshot"`
+	Source   string          `json:"source"`
+	Entries  int             `json:"entries"`
+	Failures []VerifyFailure `json:"failures,omitempty"`
+}
+
+// VerifyReport is the result of km verify
+type VerifyReport struct {
+	Snapshots       int                    `json:"snapshots"`
+	Failed          int                    `json:"failed"`
+	Objects         int                    `json:"objects"`
+	SamplePercent   float64                `json:"samplePercent"`
+	SampledContents int64                  `json:"sampledContents"`
+	SampledBytes    int64                  `json:"sampledBytes"`
+	Results         []SnapshotVerification `json:"results"`
+}
+
+// VerifyProgress reports how far a verification has got
+type VerifyProgress struct {
+	Snapshot  int
+	Snapshots int
+	Source    string
+	Entries   int
+}
+
+// VerifyRepository verifies every snapshot tree and prints the failing files
+func (ro *RepositoryOps) VerifyRepository(opts VerifyOptions) error {
+	spin := ui.NewSpinner("Verifying repository integrity")
+	spin.Start()
+
+	report, err := ro.VerifySnapshots(opts, func(p VerifyProgress) {
+		spin.UpdateMessage(fmt.Sprintf("Verifying snapshot %d/%d of %s (%d entries)", p.Snapshot, p.Snapshots, p.Source, p.Entries))
+	})
+	if err != nil {
+		spin.Fail(fmt.Sprintf("Verification failed: %v", err))
+		return err
+	}
+	spin.Stop()
+
+	for _, result := range report.Results {
+		if len(result.Failures) == 0 {
+			continue
+		}
+		ui.Warningf("Snapshot %s of %s failed verification:", result.Snapshot.ID, result.Source)
+		for _, failure := range result.Failures {
+			ui.Itemf("- %s: %s", failure.Path, failure.Error)
+		}
+	}
+
+	ui.Summaryf("Verification completed: %d/%d snapshots verified successfully (%d objects)",
+		report.Snapshots-report.Failed, report.Snapshots, report.Objects)
+	if report.SamplePercent > 0 {
+		ui.Infof("Downloaded and hashed %d contents (%s, %.4g%% sample)",
+			report.SampledContents, ui.FormatSize(report.SampledBytes), report.SamplePercent)
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d snapshots failed verification", report.Failed, report.Snapshots)
+	}
+	return nil
+}
+
+// VerifySnapshots walks every selected snapshot and verifies all objects it references.
+//
+// Objects shared between snapshots are verified once, but a failure is reported
+// for every snapshot and path referencing the object. progress may be nil.
+func (ro *RepositoryOps) VerifySnapshots(opts VerifyOptions, progress func(VerifyProgress)) (*VerifyReport, error) {
+	if opts.SamplePercent < 0 || opts.SamplePercent > 100 {
+		return nil, fmt.Errorf("sample percentage must be between 0 and 100, got %g", opts.SamplePercent)
+	}
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = DefaultVerifyParallelism
+	}
+
+	ctx := context.Background()
+	r, err := ro.km.openRepository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close(ctx)
+
+	v := &verifier{
+		r:        r,
+		percent:  opts.SamplePercent,
+		sem:      make(chan struct{}, opts.Parallelism),
+		objects:  make(map[object.ID]*objectCheck),
+		contents: make(map[content.ID]bool),
+	}
+	if opts.SamplePercent > 0 {
+		// Cached contents would hide data that is damaged or missing in storage
+		dr, closeUncached, err := ro.km.openUncachedRepository(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("content sampling: %w", err)
+		}
+		defer closeUncached()
+		v.content = dr.ContentReader()
+	}
+
+	sources, err := filterSources(ctx, r, opts.FindOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// List everything first so progress shows the real total
+	var snapshots []*snapshot.Manifest
+	for _, source := range sources {
+		sourceSnapshots, err := sortedSnapshots(ctx, r, source)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, sourceSnapshots...)
+	}
+
+	report := &VerifyReport{
+		Snapshots:     len(snapshots),
+		SamplePercent: opts.SamplePercent,
+		Results:       make([]SnapshotVerification, 0, len(snapshots)),
+	}
+	for i, snap := range snapshots {
+		result := v.verifySnapshot(ctx, snap, func(entries int) {
+			if progress != nil {
+				progress(VerifyProgress{Snapshot: i + 1, Snapshots: len(snapshots), Source: snap.Source.String(), Entries: entries})
+			}
+		})
+		if len(result.Failures) > 0 {
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	report.Objects = len(v.objects)
+	report.SampledContents = v.sampledContents
+	report.SampledBytes = v.sampledBytes
+	return report, nil
+}
+
+// objectCheck is the verification of one object, shared by every entry referencing it
+type objectCheck struct {
+	done chan struct{}
+	err  error
+}
+
+// verifier checks objects with bounded parallelism and remembers the results
+type verifier struct {
+	r       repo.Repository
+	content content.Reader // nil unless contents are sampled
+	percent float64
+	sem     chan struct{}
+
+	mu              sync.Mutex
+	objects         map[object.ID]*objectCheck
+	contents        map[content.ID]bool
+	sampledContents int64
+	sampledBytes    int64
+}
+
+// pendingCheck is an entry of a snapshot waiting for its object to be verified
+type pendingCheck struct {
+	path  string
+	oid   object.ID
+	check *objectCheck
+}
+
+// verifySnapshot walks a snapshot tree and collects the entries that failed verification
+func (v *verifier) verifySnapshot(ctx context.Context, snap *snapshot.Manifest, progress func(entries int)) SnapshotVerification {
+	result := SnapshotVerification{Snapshot: manifestToSummary(snap), Source: snap.Source.String()}
+	fail := func(relPath string, oid object.ID, err error) {
+		failure := VerifyFailure{Path: sourceJoin(snap.Source.Path, relPath), Error: err.Error()}
+		if oid != object.EmptyID {
+			failure.ObjectID = oid.String()
+		}
+		result.Failures = append(result.Failures, failure)
+	}
+
+	progress(0)
+	root, err := snapshotfs.SnapshotRoot(v.r, snap)
+	if err != nil {
+		fail("", object.EmptyID, err)
+		return result
+	}
+	defer root.Close()
+
+	var pending []pendingCheck
+	var walk func(relPath string, entry fs.Entry)
+	walk = func(relPath string, entry fs.Entry) {
+		result.Entries++
+		if result.Entries%verifyProgressInterval == 0 {
+			progress(result.Entries)
+		}
+
+		h, ok := entry.(object.HasObjectID)
+		if !ok {
+			return
+		}
+		oid := h.ObjectID()
+		check := v.check(ctx, oid)
+
+		dir, ok := entry.(fs.Directory)
+		if !ok {
+			pending = append(pending, pendingCheck{relPath, oid, check})
+			return
+		}
+
+		// A directory has to be intact before its entries can be listed
+		<-check.done
+		if check.err != nil {
+			fail(relPath, oid, check.err)
+			return
+		}
+		err := fs.IterateEntries(ctx, dir, func(ctx context.Context, child fs.Entry) error {
+			defer child.Close()
+			walk(path.Join(relPath, child.Name()), child)
+			return nil
+		})
+		if err != nil {
+			fail(relPath, oid, fmt.Errorf("failed to list directory: %w", err))
+		}
+	}
+	walk("", root)
+
+	for _, p := range pending {
+		<-p.check.done
+		if p.check.err != nil {
+			fail(p.path, p.oid, p.check.err)
+		}
+	}
+
+	sort.Slice(result.Failures, func(i, j int) bool {
+		return result.Failures[i].Path < result.Failures[j].Path
+	})
+	return result
+}
+
+// check starts verifying an object unless it was verified before.
+// It blocks while the maximum number of objects are being verified.
+func (v *verifier) check(ctx context.Context, oid object.ID) *objectCheck {
+	v.mu.Lock()
+	if c, ok := v.objects[oid]; ok {
+		v.mu.Unlock()
+		return c
+	}
+	c := &objectCheck{done: make(chan struct{})}
+	v.objects[oid] = c
+	v.mu.Unlock()
+
+	v.sem <- struct{}{}
+	go func() {
+		defer func() {
+			<-v.sem
+			close(c.done)
+		}()
+		c.err = v.verifyObject(ctx, oid)
+	}()
+	return c
+}
+
+// verifyObject checks that all contents of an object exist and downloads a sample of them
+func (v *verifier) verifyObject(ctx context.Context, oid object.ID) error {
+	contentIDs, err := v.r.VerifyObject(ctx, oid)
+	if err != nil {
+		return fmt.Errorf("failed to verify object: %w", err)
+	}
+
+	if v.content == nil {
+		return nil
+	}
+	for _, cid := range contentIDs {
+		if !v.sample(cid) {
+			continue
+		}
+		// Reading a content decrypts it and checks its hash
+		data, err := v.content.GetContent(ctx, cid)
+		if err != nil {
+			return fmt.Errorf("failed to read content %s: %w", cid, err)
+		}
+		v.mu.Lock()
+		v.sampledContents++
+		v.sampledBytes += int64(len(data))
+		v.mu.Unlock()
+	}
+	return nil
+}
+
+// sample decides once per content whether it is downloaded
+func (v *verifier) sample(cid content.ID) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, seen := v.contents[cid]; seen {
+		return false
+	}
+	v.contents[cid] = true
+	return rand.Float64()*100 < v.percent
+}
@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+)
+
+func TestVerifySnapshotsReadsStorage(t *testing.T) {
+	km := newTestManagerWithOptions(t, &repo.ConnectOptions{
+		CachingOptions: content.CachingOptions{CacheDirectory: t.TempDir(), ContentCacheSizeBytes: 1 << 20},
+	})
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "a.txt"), strings.Repeat("a", 4096))
+	writeFile(t, filepath.Join(src, "docs", "b.txt"), strings.Repeat("b", 4096))
+	backup(t, km, src, BackupOptions{})
+
+	opts := VerifyOptions{SamplePercent: 100}
+	report, err := km.VerifySnapshots(opts, nil)
+	if err != nil {
+		t.Fatalf("VerifySnapshots() error: %v", err)
+	}
+	if report.Failed != 0 || report.SampledContents == 0 {
+		t.Fatalf("report = %+v, want every content sampled without failures", report)
+	}
+
+	// Damage the data packs in storage, sharded below p/; the local cache still holds
+	// intact copies
+	packs := filepath.Join(filepath.Dir(km.ConfigPath), "repo", "p")
+	err = filepath.WalkDir(packs, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, make([]byte, info.Size()), 0o600)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = km.VerifySnapshots(opts, nil)
+	if err != nil {
+		t.Fatalf("VerifySnapshots() error: %v", err)
+	}
+	if report.Failed != 1 {
+		t.Errorf("report = %+v, want the damaged snapshot to fail", report)
+	}
+}
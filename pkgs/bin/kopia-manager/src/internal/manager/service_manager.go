@@ -3,7 +3,9 @@ package manager
 import (
 	"fmt"
 	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ServiceManager handles systemd service operations
@@ -312,3 +314,129 @@ func (sm *ServiceManager) getBackupTimers() ([]string, error) {
 
 	return timers, nil
 }
+
+// SystemdStatus returns the state of the kopia services and timers
+func (sm *ServiceManager) SystemdStatus() (*SystemdStatus, error) {
+	services := []string{
+		"kopia-init.service",
+		"kopia-maintenance.service",
+	}
+	if backupServices, err := sm.getBackupServices(); err == nil {
+		services = append(services, backupServices...)
+	}
+
+	timers := []string{
+		"kopia-maintenance.timer",
+	}
+	if backupTimers, err := sm.getBackupTimers(); err == nil {
+		timers = append(timers, backupTimers...)
+	}
+
+	status := &SystemdStatus{
+		Services: make([]ServiceStatus, 0, len(services)),
+		Timers:   make([]TimerStatus, 0, len(timers)),
+	}
+	for _, service := range services {
+		s, err := sm.ServiceStatus(service)
+		if err != nil {
+			return nil, err
+		}
+		status.Services = append(status.Services, s)
+	}
+	for _, timer := range timers {
+		t, err := sm.TimerStatus(timer)
+		if err != nil {
+			return nil, err
+		}
+		status.Timers = append(status.Timers, t)
+	}
+
+	return status, nil
+}
+
+// BackupServiceStatuses returns each backup service with its timer
+func (sm *ServiceManager) BackupServiceStatuses() ([]BackupServiceStatus, error) {
+	names, err := sm.ListBackupServices()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]BackupServiceStatus, 0, len(names))
+	for _, name := range names {
+		service, err := sm.ServiceStatus(fmt.Sprintf("kopia-backup-%s.service", name))
+		if err != nil {
+			return nil, err
+		}
+		timer, err := sm.TimerStatus(fmt.Sprintf("kopia-backup-%s.timer", name))
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, BackupServiceStatus{Name: name, Service: service, Timer: timer})
+	}
+
+	return statuses, nil
+}
+
+// ServiceStatus returns the state of a systemd user service
+func (sm *ServiceManager) ServiceStatus(unit string) (ServiceStatus, error) {
+	props, err := unitProperties(unit, "LoadState", "ActiveState", "SubState", "Result", "Description",
+		"ExecMainStartTimestamp", "ExecMainExitTimestamp")
+	if err != nil {
+		return ServiceStatus{}, err
+	}
+
+	return ServiceStatus{
+		Unit:        unit,
+		LoadState:   props["LoadState"],
+		ActiveState: props["ActiveState"],
+		SubState:    props["SubState"],
+		Result:      props["Result"],
+		Description: props["Description"],
+		LastStart:   parseUnitTimestamp(props["ExecMainStartTimestamp"]),
+		LastExit:    parseUnitTimestamp(props["ExecMainExitTimestamp"]),
+	}, nil
+}
+
+// TimerStatus returns the state of a systemd user timer
+func (sm *ServiceManager) TimerStatus(unit string) (TimerStatus, error) {
+	props, err := unitProperties(unit, "ActiveState", "Triggers", "NextElapseUSecRealtime", "LastTriggerUSec")
+	if err != nil {
+		return TimerStatus{}, err
+	}
+
+	return TimerStatus{
+		Unit:        unit,
+		ActiveState: props["ActiveState"],
+		Activates:   props["Triggers"],
+		Next:        parseUnitTimestamp(props["NextElapseUSecRealtime"]),
+		Last:        parseUnitTimestamp(props["LastTriggerUSec"]),
+	}, nil
+}
+
+// unitProperties reads properties of a systemd user unit, with timestamps in unix form
+func unitProperties(unit string, properties ...string) (map[string]string, error) {
+	cmd := exec.Command("systemctl", "--user", "show", unit,
+		"--property="+strings.Join(properties, ","), "--timestamp=unix")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status of %s: %w", unit, err)
+	}
+
+	props := make(map[string]string, len(properties))
+	for _, line := range strings.Split(string(output), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[key] = strings.TrimSpace(value)
+		}
+	}
+	return props, nil
+}
+
+// parseUnitTimestamp parses a "@seconds" timestamp, returning nil when unset
+func parseUnitTimestamp(value string) *time.Time {
+	seconds, err := strconv.ParseInt(strings.TrimPrefix(value, "@"), 10, 64)
+	if err != nil || seconds <= 0 {
+		return nil
+	}
+	t := time.Unix(seconds, 0)
+	return &t
+}
@@ -0,0 +1,270 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// Diff change kinds
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// DiffEntry describes a file that differs between two snapshots
+type DiffEntry struct {
+	Path        string `json:"path"`
+	Change      string `json:"change"`
+	OldSize     int64  `json:"oldSize"`
+	NewSize     int64  `json:"newSize"`
+	SizeDelta   int64  `json:"sizeDelta"`
+	OldObjectID string `json:"oldObjectId,omitempty"`
+	NewObjectID string `json:"newObjectId,omitempty"`
+}
+
+// SnapshotDiff is the file-level difference between two snapshots
+type SnapshotDiff struct {
+	From      SnapshotSummary `json:"from"`
+	To        SnapshotSummary `json:"to"`
+	Entries   []DiffEntry     `json:"entries,omitempty"`
+	Added     int             `json:"added"`
+	Removed   int             `json:"removed"`
+	Modified  int             `json:"modified"`
+	SizeDelta int64           `json:"sizeDelta"`
+}
+
+// DiffOptions controls which entries DiffSnapshots reports
+type DiffOptions struct {
+	// PathFilter limits the diff to a path prefix or glob pattern relative to the snapshot root
+	PathFilter string
+}
+
+// DiffSnapshots compares two snapshots file by file, matching entries by path and
+// detecting modifications by object ID.
+func (sm *SnapshotManager) DiffSnapshots(snapshot1, snapshot2 string, opts DiffOptions) (*SnapshotDiff, error) {
+	ctx := context.Background()
+	r, err := sm.km.openRepository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close(ctx)
+
+	// Find both snapshots
+	manifestID1, err := sm.resolveSnapshotID(ctx, r, snapshot1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve snapshot %s: %w", snapshot1, err)
+	}
+
+	manifestID2, err := sm.resolveSnapshotID(ctx, r, snapshot2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve snapshot %s: %w", snapshot2, err)
+	}
+
+	snap1, err := snapshot.LoadSnapshot(ctx, r, manifestID1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot %s: %w", snapshot1, err)
+	}
+
+	snap2, err := snapshot.LoadSnapshot(ctx, r, manifestID2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot %s: %w", snapshot2, err)
+	}
+
+	root1, err := snapshotfs.SnapshotRoot(r, snap1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot %s: %w", snapshot1, err)
+	}
+	defer root1.Close()
+
+	root2, err := snapshotfs.SnapshotRoot(r, snap2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot %s: %w", snapshot2, err)
+	}
+	defer root2.Close()
+
+	filter, err := newPathFilter(opts.PathFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &differ{filter: filter}
+	if err := d.compare(ctx, "", root1, root2); err != nil {
+		return nil, fmt.Errorf("failed to compare snapshots: %w", err)
+	}
+
+	sort.Slice(d.entries, func(i, j int) bool {
+		return d.entries[i].Path < d.entries[j].Path
+	})
+
+	diff := &SnapshotDiff{
+		From:    manifestToSummary(snap1),
+		To:      manifestToSummary(snap2),
+		Entries: d.entries,
+	}
+	for _, e := range d.entries {
+		switch e.Change {
+		case ChangeAdded:
+			diff.Added++
+		case ChangeRemoved:
+			diff.Removed++
+		case ChangeModified:
+			diff.Modified++
+		}
+		diff.SizeDelta += e.SizeDelta
+	}
+
+	return diff, nil
+}
+
+// differ walks two snapshot trees in lockstep and collects changed files
+type differ struct {
+	filter  pathFilter
+	entries []DiffEntry
+}
+
+// compare diffs two entries found at the same relative path. Either entry may be nil.
+func (d *differ) compare(ctx context.Context, relPath string, oldEntry, newEntry fs.Entry) error {
+	if relPath != "" && !d.filter.mayContain(relPath) {
+		return nil
+	}
+
+	switch {
+	case oldEntry == nil && newEntry == nil:
+		return nil
+	case oldEntry == nil:
+		return d.walk(ctx, relPath, newEntry, ChangeAdded)
+	case newEntry == nil:
+		return d.walk(ctx, relPath, oldEntry, ChangeRemoved)
+	}
+
+	// Identical object IDs mean identical content, including whole subtrees
+	if entryObjectID(oldEntry) == entryObjectID(newEntry) && entryObjectID(oldEntry) != "" {
+		return nil
+	}
+
+	oldDir, oldIsDir := oldEntry.(fs.Directory)
+	newDir, newIsDir := newEntry.(fs.Directory)
+
+	switch {
+	case oldIsDir && newIsDir:
+		return d.compareDirs(ctx, relPath, oldDir, newDir)
+	case oldIsDir != newIsDir:
+		// Type changed, e.g. a file replaced by a directory
+		if err := d.walk(ctx, relPath, oldEntry, ChangeRemoved); err != nil {
+			return err
+		}
+		return d.walk(ctx, relPath, newEntry, ChangeAdded)
+	default:
+		d.add(relPath, ChangeModified, oldEntry, newEntry)
+		return nil
+	}
+}
+
+// compareDirs matches the children of two directories by name
+func (d *differ) compareDirs(ctx context.Context, relPath string, oldDir, newDir fs.Directory) error {
+	oldChildren, err := childrenByName(ctx, oldDir)
+	if err != nil {
+		return err
+	}
+	defer closeEntries(oldChildren)
+	newChildren, err := childrenByName(ctx, newDir)
+	if err != nil {
+		return err
+	}
+	defer closeEntries(newChildren)
+
+	names := make(map[string]struct{}, len(oldChildren)+len(newChildren))
+	for name := range oldChildren {
+		names[name] = struct{}{}
+	}
+	for name := range newChildren {
+		names[name] = struct{}{}
+	}
+
+	for name := range names {
+		if err := d.compare(ctx, path.Join(relPath, name), oldChildren[name], newChildren[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walk reports every file below entry as added or removed
+func (d *differ) walk(ctx context.Context, relPath string, entry fs.Entry, change string) error {
+	dir, ok := entry.(fs.Directory)
+	if !ok {
+		if change == ChangeAdded {
+			d.add(relPath, change, nil, entry)
+		} else {
+			d.add(relPath, change, entry, nil)
+		}
+		return nil
+	}
+
+	return fs.IterateEntries(ctx, dir, func(ctx context.Context, child fs.Entry) error {
+		childPath := path.Join(relPath, child.Name())
+		if !d.filter.mayContain(childPath) {
+			return nil
+		}
+		defer child.Close()
+		return d.walk(ctx, childPath, child, change)
+	})
+}
+
+// add records a changed file if it matches the path filter
+func (d *differ) add(relPath, change string, oldEntry, newEntry fs.Entry) {
+	if !d.filter.matches(relPath) {
+		return
+	}
+
+	e := DiffEntry{Path: relPath, Change: change}
+	if oldEntry != nil {
+		e.OldSize = oldEntry.Size()
+		e.OldObjectID = entryObjectID(oldEntry)
+	}
+	if newEntry != nil {
+		e.NewSize = newEntry.Size()
+		e.NewObjectID = entryObjectID(newEntry)
+	}
+	e.SizeDelta = e.NewSize - e.OldSize
+
+	d.entries = append(d.entries, e)
+}
+
+// childrenByName reads all entries of a directory into a map keyed by name
+func childrenByName(ctx context.Context, dir fs.Directory) (map[string]fs.Entry, error) {
+	entries, err := fs.GetAllEntries(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir.Name(), err)
+	}
+
+	children := make(map[string]fs.Entry, len(entries))
+	for _, e := range entries {
+		children[e.Name()] = e
+	}
+	return children, nil
+}
+
+// closeEntries closes the entries read by childrenByName
+func closeEntries(children map[string]fs.Entry) {
+	for _, e := range children {
+		e.Close()
+	}
+}
+
+// entryObjectID returns the repository object ID of a snapshot entry, or "" if unknown
+func entryObjectID(e fs.Entry) string {
+	if h, ok := e.(object.HasObjectID); ok {
+		return h.ObjectID().String()
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	km := newTestManager(t)
+	src := t.TempDir()
+
+	writeFile(t, filepath.Join(src, "notes.txt"), "v1")
+	writeFile(t, filepath.Join(src, "docs", "old.md"), "old")
+	writeFile(t, filepath.Join(src, "docs", "same.md"), "same")
+	from := backup(t, km, src, BackupOptions{})
+
+	// Modified files differ in size so the uploader doesn't reuse them by mtime
+	writeFile(t, filepath.Join(src, "notes.txt"), "version 2")
+	if err := os.Remove(filepath.Join(src, "docs", "old.md")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(src, "docs", "new.md"), "new file")
+	to := backup(t, km, src, BackupOptions{})
+
+	tests := []struct {
+		name   string
+		filter string
+		want   []DiffEntry
+	}{
+		{
+			name: "all files",
+			want: []DiffEntry{
+				{Path: "docs/new.md", Change: ChangeAdded, NewSize: 8, SizeDelta: 8},
+				{Path: "docs/old.md", Change: ChangeRemoved, OldSize: 3, SizeDelta: -3},
+				{Path: "notes.txt", Change: ChangeModified, OldSize: 2, NewSize: 9, SizeDelta: 7},
+			},
+		},
+		{
+			name:   "directory prefix",
+			filter: "docs",
+			want: []DiffEntry{
+				{Path: "docs/new.md", Change: ChangeAdded, NewSize: 8, SizeDelta: 8},
+				{Path: "docs/old.md", Change: ChangeRemoved, OldSize: 3, SizeDelta: -3},
+			},
+		},
+		{
+			name:   "glob",
+			filter: "*/new.*",
+			want: []DiffEntry{
+				{Path: "docs/new.md", Change: ChangeAdded, NewSize: 8, SizeDelta: 8},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := km.DiffSnapshots(from, to, DiffOptions{PathFilter: tt.filter})
+			if err != nil {
+				t.Fatalf("DiffSnapshots() error: %v", err)
+			}
+
+			if len(diff.Entries) != len(tt.want) {
+				t.Fatalf("got entries %+v, want %+v", diff.Entries, tt.want)
+			}
+			var sizeDelta int64
+			for i, want := range tt.want {
+				got := diff.Entries[i]
+				if got.Path != want.Path || got.Change != want.Change || got.OldSize != want.OldSize ||
+					got.NewSize != want.NewSize || got.SizeDelta != want.SizeDelta {
+					t.Errorf("entry %d = %+v, want %+v", i, got, want)
+				}
+				sizeDelta += want.SizeDelta
+			}
+			if diff.SizeDelta != sizeDelta {
+				t.Errorf("size delta = %d, want %d", diff.SizeDelta, sizeDelta)
+			}
+		})
+	}
+}
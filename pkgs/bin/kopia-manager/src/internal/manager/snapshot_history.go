@@ -0,0 +1,571 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// FindOptions selects the snapshots FindEntries and FileHistory search
+type FindOptions struct {
+	// Source limits the search to snapshots of this backed up path
+	Source   string
+	Hostname string
+	Username string
+}
+
+// FindMatch is an entry found in a snapshot
+type FindMatch struct {
+	Snapshot SnapshotSummary `json:"snapshot"`
+	Path     string          `json:"path"`
+	Size     int64           `json:"size"`
+	ModTime  time.Time       `json:"modTime"`
+	IsDir    bool            `json:"isDir"`
+	ObjectID string          `json:"objectId,omitempty"`
+}
+
+// FileVersion is a distinct version of a file, seen in one or more consecutive snapshots
+type FileVersion struct {
+	Version   int             `json:"version"`
+	ObjectID  string          `json:"objectId"`
+	Size      int64           `json:"size"`
+	ModTime   time.Time       `json:"modTime"`
+	FirstSeen SnapshotSummary `json:"firstSeen"`
+	LastSeen  SnapshotSummary `json:"lastSeen"`
+	Snapshots int             `json:"snapshots"`
+}
+
+// FileHistory lists the versions of a file across the snapshots of its source, oldest first
+type FileHistory struct {
+	Source   string        `json:"source"`
+	Path     string        `json:"path"`
+	Versions []FileVersion `json:"versions"`
+	// Missing counts snapshots of the source that don't contain the file
+	Missing int `json:"missing"`
+}
+
+// FullPath returns the absolute path of the file as it was backed up
+func (h *FileHistory) FullPath() string {
+	return sourceJoin(h.Source, h.Path)
+}
+
+// FindEntries searches all snapshots of the selected sources for entries matching pattern.
+//
+// A pattern without a slash is matched against entry names anywhere in the tree; otherwise
+// it is a glob matched against the whole path, relative to the snapshot root or absolute
+// below the backed up directory.
+func (sm *SnapshotManager) FindEntries(pattern string, opts FindOptions) ([]FindMatch, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	ctx := context.Background()
+	r, err := sm.km.openRepository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close(ctx)
+
+	sources, err := filterSources(ctx, r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []FindMatch
+	for _, source := range sources {
+		f := &finder{cache: make(map[string][]FindMatch)}
+		if strings.Contains(pattern, "/") {
+			rel, ok := sourceRelative(source.Path, pattern)
+			if !ok {
+				continue
+			}
+			f.pathPattern = rel
+		} else {
+			f.namePattern = pattern
+		}
+
+		snapshots, err := sortedSnapshots(ctx, r, source)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, snap := range snapshots {
+			root, err := snapshotfs.SnapshotRoot(r, snap)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open snapshot %s: %w", snap.ID, err)
+			}
+
+			found, err := f.search(ctx, root)
+			root.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to search snapshot %s: %w", snap.ID, err)
+			}
+
+			summary := manifestToSummary(snap)
+			for _, m := range found {
+				m.Snapshot = summary
+				m.Path = sourceJoin(source.Path, m.Path)
+				matches = append(matches, m)
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Path != matches[j].Path {
+			return matches[i].Path < matches[j].Path
+		}
+		return matches[i].Snapshot.StartTime.Before(matches[j].Snapshot.StartTime)
+	})
+	return matches, nil
+}
+
+// FileHistory lists every distinct version of a file across the snapshots of the source
+// that contains it. filePath is absolute, or relative to the snapshot root when the
+// source is unambiguous.
+func (sm *SnapshotManager) FileHistory(filePath string, opts FindOptions) (*FileHistory, error) {
+	ctx := context.Background()
+	r, err := sm.km.openRepository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close(ctx)
+
+	source, relPath, err := resolveSourcePath(ctx, r, filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots, err := sortedSnapshots(ctx, r, source)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &FileHistory{Source: source.Path, Path: relPath}
+	var current *FileVersion
+	for _, snap := range snapshots {
+		root, err := snapshotfs.SnapshotRoot(r, snap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open snapshot %s: %w", snap.ID, err)
+		}
+
+		entry, err := lookupEntry(ctx, root, relPath)
+		if err != nil {
+			root.Close()
+			return nil, fmt.Errorf("failed to read snapshot %s: %w", snap.ID, err)
+		}
+		if entry == nil {
+			root.Close()
+			history.Missing++
+			current = nil
+			continue
+		}
+
+		isDir := entry.IsDir()
+		version := FileVersion{ObjectID: entryObjectID(entry), Size: entry.Size(), ModTime: entry.ModTime()}
+		entry.Close()
+		root.Close()
+		if isDir {
+			return nil, fmt.Errorf("%s is a directory in snapshot %s", history.FullPath(), snap.ID)
+		}
+
+		summary := manifestToSummary(snap)
+		if current != nil && current.ObjectID == version.ObjectID {
+			current.LastSeen = summary
+			current.Snapshots++
+			continue
+		}
+
+		version.Version = len(history.Versions) + 1
+		version.FirstSeen = summary
+		version.LastSeen = summary
+		version.Snapshots = 1
+		history.Versions = append(history.Versions, version)
+		current = &history.Versions[len(history.Versions)-1]
+	}
+
+	if len(history.Versions) == 0 {
+		return nil, fmt.Errorf("%s not found in any snapshot of %s", history.FullPath(), source.Path)
+	}
+	return history, nil
+}
+
+// CatFile writes the content of a file in a snapshot to w
+func (sm *SnapshotManager) CatFile(snapshotID, filePath string, w io.Writer) error {
+	ctx := context.Background()
+	r, err := sm.km.openRepository(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close(ctx)
+
+	file, err := sm.openSnapshotFile(ctx, r, snapshotID, filePath)
+	if err != nil {
+		return err
+	}
+
+	reader, err := file.Open(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(w, reader); err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// RestoreFile writes a single file from a snapshot to target, keeping its modification
+// time and permissions. An existing target is only replaced if overwrite is set.
+func (sm *SnapshotManager) RestoreFile(snapshotID, filePath, target string, overwrite bool) error {
+	ctx := context.Background()
+	r, err := sm.km.openRepository(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close(ctx)
+
+	file, err := sm.openSnapshotFile(ctx, r, snapshotID, filePath)
+	if err != nil {
+		return err
+	}
+
+	if !overwrite {
+		if _, err := os.Lstat(target); err == nil {
+			return fmt.Errorf("%s already exists", target)
+		}
+	}
+
+	reader, err := file.Open(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer reader.Close()
+
+	// Write next to the target and rename, so a failed restore leaves the old file intact
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", target, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, reader); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+
+	if err := os.Chmod(tmp.Name(), file.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", target, err)
+	}
+	if err := os.Chtimes(tmp.Name(), file.ModTime(), file.ModTime()); err != nil {
+		return fmt.Errorf("failed to set modification time on %s: %w", target, err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return nil
+}
+
+// openSnapshotFile looks up a regular file in a snapshot. filePath is relative to the
+// snapshot root or absolute below the backed up directory.
+func (sm *SnapshotManager) openSnapshotFile(ctx context.Context, r repo.Repository, snapshotID, filePath string) (fs.File, error) {
+	snap, err := sm.loadSnapshot(ctx, r, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	relPath, ok := sourceRelative(snap.Source.Path, filePath)
+	if !ok {
+		return nil, fmt.Errorf("%s is not below %s", filePath, snap.Source.Path)
+	}
+
+	root, err := snapshotfs.SnapshotRoot(r, snap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot %s: %w", snapshotID, err)
+	}
+
+	entry, err := lookupEntry(ctx, root, relPath)
+	if entry != root {
+		// The root is only returned for snapshots of a single file
+		root.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", snapshotID, err)
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("%s not found in snapshot %s", filePath, snapshotID)
+	}
+
+	file, ok := entry.(fs.File)
+	if !ok {
+		entry.Close()
+		return nil, fmt.Errorf("%s is not a regular file in snapshot %s", filePath, snapshotID)
+	}
+	return file, nil
+}
+
+// finder searches snapshot trees for entries matching a name or a path glob. Name
+// searches are cached by directory object ID, so subtrees that didn't change between
+// snapshots are only read once.
+type finder struct {
+	namePattern string
+	pathPattern string
+	cache       map[string][]FindMatch
+}
+
+// search returns the matching entries below root, with paths relative to it
+func (f *finder) search(ctx context.Context, root fs.Entry) ([]FindMatch, error) {
+	dir, ok := root.(fs.Directory)
+	if !ok {
+		// Snapshot of a single file
+		if f.namePattern != "" && nameMatches(f.namePattern, root.Name()) {
+			return []FindMatch{newFindMatch("", root)}, nil
+		}
+		return nil, nil
+	}
+
+	if f.namePattern != "" {
+		return f.searchNames(ctx, dir)
+	}
+	return f.searchPath(ctx, dir, "")
+}
+
+// searchNames finds entries by name below dir, with paths relative to dir
+func (f *finder) searchNames(ctx context.Context, dir fs.Directory) ([]FindMatch, error) {
+	oid := entryObjectID(dir)
+	if found, ok := f.cache[oid]; ok && oid != "" {
+		return found, nil
+	}
+
+	var found []FindMatch
+	err := fs.IterateEntries(ctx, dir, func(ctx context.Context, child fs.Entry) error {
+		defer child.Close()
+		if nameMatches(f.namePattern, child.Name()) {
+			found = append(found, newFindMatch(child.Name(), child))
+		}
+
+		childDir, ok := child.(fs.Directory)
+		if !ok {
+			return nil
+		}
+		below, err := f.searchNames(ctx, childDir)
+		if err != nil {
+			return err
+		}
+		for _, m := range below {
+			m.Path = path.Join(child.Name(), m.Path)
+			found = append(found, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if oid != "" {
+		f.cache[oid] = found
+	}
+	return found, nil
+}
+
+// searchPath finds entries whose whole path matches, skipping subtrees that cannot match
+func (f *finder) searchPath(ctx context.Context, dir fs.Directory, relPath string) ([]FindMatch, error) {
+	patternParts := strings.Split(f.pathPattern, "/")
+
+	var found []FindMatch
+	err := fs.IterateEntries(ctx, dir, func(ctx context.Context, child fs.Entry) error {
+		defer child.Close()
+		childPath := path.Join(relPath, child.Name())
+		if ok, _ := path.Match(f.pathPattern, childPath); ok {
+			found = append(found, newFindMatch(childPath, child))
+		}
+
+		childDir, ok := child.(fs.Directory)
+		if !ok || !componentsMatch(patternParts, strings.Split(childPath, "/")) {
+			return nil
+		}
+		below, err := f.searchPath(ctx, childDir, childPath)
+		if err != nil {
+			return err
+		}
+		found = append(found, below...)
+		return nil
+	})
+	return found, err
+}
+
+// nameMatches matches an entry name against a glob
+func nameMatches(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+func newFindMatch(relPath string, e fs.Entry) FindMatch {
+	return FindMatch{
+		Path:     relPath,
+		Size:     e.Size(),
+		ModTime:  e.ModTime(),
+		IsDir:    e.IsDir(),
+		ObjectID: entryObjectID(e),
+	}
+}
+
+// lookupEntry walks relPath below root, returning nil if it doesn't exist. The
+// directories in between are closed; root and the returned entry are the caller's.
+func lookupEntry(ctx context.Context, root fs.Entry, relPath string) (fs.Entry, error) {
+	entry := root
+	if relPath == "" {
+		return entry, nil
+	}
+
+	release := func() {
+		if entry != root {
+			entry.Close()
+		}
+	}
+	for _, name := range strings.Split(relPath, "/") {
+		dir, ok := entry.(fs.Directory)
+		if !ok {
+			release()
+			return nil, nil
+		}
+		child, err := dir.Child(ctx, name)
+		release()
+		if errors.Is(err, fs.ErrEntryNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entry = child
+	}
+	return entry, nil
+}
+
+// filterSources lists the snapshot sources selected by opts
+func filterSources(ctx context.Context, r repo.Repository, opts FindOptions) ([]snapshot.SourceInfo, error) {
+	sources, err := snapshot.ListSources(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sources: %w", err)
+	}
+
+	var filtered []snapshot.SourceInfo
+	for _, source := range sources {
+		if opts.Hostname != "" && source.Host != opts.Hostname {
+			continue
+		}
+		if opts.Username != "" && source.UserName != opts.Username {
+			continue
+		}
+		if opts.Source != "" && filepath.Clean(opts.Source) != filepath.Clean(source.Path) {
+			continue
+		}
+		filtered = append(filtered, source)
+	}
+
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("no snapshot sources match")
+	}
+	return filtered, nil
+}
+
+// resolveSourcePath finds the source a file was backed up from and its path relative to
+// the snapshot root. Absolute paths pick the source with the longest matching path.
+func resolveSourcePath(ctx context.Context, r repo.Repository, filePath string, opts FindOptions) (snapshot.SourceInfo, string, error) {
+	sources, err := filterSources(ctx, r, opts)
+	if err != nil {
+		return snapshot.SourceInfo{}, "", err
+	}
+
+	if !path.IsAbs(filepath.ToSlash(filePath)) {
+		if len(sources) != 1 {
+			return snapshot.SourceInfo{}, "", fmt.Errorf("relative path %s is ambiguous across %d sources, use an absolute path or --source", filePath, len(sources))
+		}
+		relPath, _ := sourceRelative(sources[0].Path, filePath)
+		return sources[0], relPath, nil
+	}
+
+	var best []snapshot.SourceInfo
+	for _, source := range sources {
+		if _, ok := sourceRelative(source.Path, filePath); !ok {
+			continue
+		}
+		switch {
+		case len(best) == 0 || len(source.Path) > len(best[0].Path):
+			best = []snapshot.SourceInfo{source}
+		case len(source.Path) == len(best[0].Path):
+			best = append(best, source)
+		}
+	}
+
+	switch len(best) {
+	case 0:
+		return snapshot.SourceInfo{}, "", fmt.Errorf("no backup source contains %s", filePath)
+	case 1:
+		relPath, _ := sourceRelative(best[0].Path, filePath)
+		return best[0], relPath, nil
+	default:
+		var names []string
+		for _, source := range best {
+			names = append(names, source.String())
+		}
+		return snapshot.SourceInfo{}, "", fmt.Errorf("%s is backed up by several sources (%s), use --host or --user", filePath, strings.Join(names, ", "))
+	}
+}
+
+// sourceRelative returns p relative to the snapshot root of sourcePath. Relative paths are
+// taken as is; absolute paths must be at or below the source.
+func sourceRelative(sourcePath, p string) (string, bool) {
+	p = filepath.ToSlash(p)
+	if !path.IsAbs(p) {
+		return strings.Trim(path.Clean("/"+p), "/"), true
+	}
+
+	p = path.Clean(p)
+	source := path.Clean(filepath.ToSlash(sourcePath))
+	switch {
+	case source == "/":
+		return strings.TrimPrefix(p, "/"), true
+	case p == source:
+		return "", true
+	case strings.HasPrefix(p, source+"/"):
+		return strings.TrimPrefix(p, source+"/"), true
+	}
+	return "", false
+}
+
+// sourceJoin builds the absolute path of an entry from its source and relative path
+func sourceJoin(sourcePath, relPath string) string {
+	if relPath == "" {
+		return sourcePath
+	}
+	return path.Join(filepath.ToSlash(sourcePath), relPath)
+}
+
+// sortedSnapshots lists the snapshots of a source, oldest first
+func sortedSnapshots(ctx context.Context, r repo.Repository, source snapshot.SourceInfo) ([]*snapshot.Manifest, error) {
+	snapshots, err := snapshot.ListSnapshots(ctx, r, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots of %s: %w", source, err)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].StartTime.ToTime().Before(snapshots[j].StartTime.ToTime())
+	})
+	return snapshots, nil
+}
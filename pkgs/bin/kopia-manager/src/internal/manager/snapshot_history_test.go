@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileHistory(t *testing.T) {
+	km := newTestManager(t)
+	src := t.TempDir()
+	notes := filepath.Join(src, "docs", "notes.txt")
+
+	writeFile(t, notes, "v1")
+	first := backup(t, km, src, BackupOptions{})
+	backup(t, km, src, BackupOptions{})
+	writeFile(t, notes, "version 2")
+	last := backup(t, km, src, BackupOptions{})
+
+	history, err := km.FileHistory(notes, FindOptions{})
+	if err != nil {
+		t.Fatalf("FileHistory() error: %v", err)
+	}
+	if len(history.Versions) != 2 {
+		t.Fatalf("got %d versions, want 2: %+v", len(history.Versions), history.Versions)
+	}
+	if v := history.Versions[0]; v.Size != 2 || v.Snapshots != 2 || v.FirstSeen.ID != first {
+		t.Errorf("first version = %+v, want size 2 in 2 snapshots from %s", v, first)
+	}
+	if v := history.Versions[1]; v.Size != 9 || v.Snapshots != 1 || v.FirstSeen.ID != last {
+		t.Errorf("second version = %+v, want size 9 in 1 snapshot from %s", v, last)
+	}
+
+	if _, err := km.FileHistory(filepath.Join(src, "docs"), FindOptions{}); err == nil {
+		t.Error("FileHistory() of a directory succeeded")
+	}
+
+	var out bytes.Buffer
+	if err := km.CatFile(first, "docs/notes.txt", &out); err != nil {
+		t.Fatalf("CatFile() error: %v", err)
+	}
+	if out.String() != "v1" {
+		t.Errorf("CatFile() = %q, want %q", out.String(), "v1")
+	}
+	if err := km.CatFile(first, "docs", &out); err == nil {
+		t.Error("CatFile() of a directory succeeded")
+	}
+	if err := km.CatFile(first, "docs/missing.txt", &out); err == nil {
+		t.Error("CatFile() of a missing file succeeded")
+	}
+}
+
+func TestFinderClosesEntries(t *testing.T) {
+	km := newTestManager(t)
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "docs", "notes.txt"), "notes")
+	writeFile(t, filepath.Join(src, "docs", "old", "notes.txt"), "old notes")
+	writeFile(t, filepath.Join(src, "src", "main.go"), "package main")
+	root := snapshotRoot(t, km, backup(t, km, src, BackupOptions{}))
+
+	tests := []struct {
+		name   string
+		finder finder
+		want   int
+	}{
+		{name: "by name", finder: finder{namePattern: "notes.txt"}, want: 2},
+		{name: "by path", finder: finder{pathPattern: "docs/*.txt"}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := &openEntries{}
+			tt.finder.cache = make(map[string][]FindMatch)
+			found, err := tt.finder.search(context.Background(), entries.dir(root))
+			if err != nil {
+				t.Fatalf("search() error: %v", err)
+			}
+			if len(found) != tt.want {
+				t.Errorf("found %+v, want %d matches", found, tt.want)
+			}
+			if open := entries.open(); len(open) != 0 {
+				t.Errorf("entries left open: %v", open)
+			}
+		})
+	}
+}
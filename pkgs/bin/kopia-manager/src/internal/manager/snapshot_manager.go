@@ -3,7 +3,6 @@ package manager
 import (
 	"context"
 	"fmt"
-	"math"
 	"os"
 	"path/filepath"
 	"sort"
@@ -13,14 +12,12 @@ import (
 
 	"kopia-manager/internal/ui"
 
-	"github.com/kopia/kopia/fs/localfs"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 	"github.com/kopia/kopia/snapshot/restore"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
-	"github.com/kopia/kopia/snapshot/upload"
 )
 
 // SnapshotManager handles snapshot-related operations
@@ -96,199 +93,71 @@ func (sm *SnapshotManager) ListSnapshots(hostname, username string) ([]SnapshotS
 	return allSnapshots, nil
 }
 
-// GetSnapshotInfo returns detailed information about a specific snapshot
-func (sm *SnapshotManager) GetSnapshotInfo(snapshotID string) (string, error) {
+// SnapshotDetails returns detailed information about a specific snapshot
+func (sm *SnapshotManager) SnapshotDetails(snapshotID string) (*SnapshotDetails, error) {
 	ctx := context.Background()
 	r, err := sm.km.openRepository(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer r.Close(ctx)
 
 	// Find the snapshot by ID (supports partial IDs)
-	manifestID, err := sm.resolveSnapshotID(ctx, r, snapshotID)
+	snap, err := sm.loadSnapshot(ctx, r, snapshotID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	snap, err := snapshot.LoadSnapshot(ctx, r, manifestID)
+	return &SnapshotDetails{
+		SnapshotSummary:  manifestToSummary(snap),
+		RootObjectID:     snap.RootObjectID().String(),
+		CachedFiles:      snap.Stats.CachedFiles,
+		NonCachedFiles:   snap.Stats.NonCachedFiles,
+		ErrorCount:       snap.Stats.ErrorCount,
+		IncompleteReason: snap.IncompleteReason,
+	}, nil
+}
+
+// GetSnapshotInfo returns detailed information about a specific snapshot
+func (sm *SnapshotManager) GetSnapshotInfo(snapshotID string) (string, error) {
+	snap, err := sm.SnapshotDetails(snapshotID)
 	if err != nil {
-		return "", fmt.Errorf("failed to load snapshot: %w", err)
+		return "", err
 	}
 
 	var result strings.Builder
 	result.WriteString(fmt.Sprintf("Snapshot ID:         %s\n", snap.ID))
 	result.WriteString(fmt.Sprintf("Description:         %s\n", snap.Description))
-	result.WriteString(fmt.Sprintf("Source:              %s@%s:%s\n", snap.Source.UserName, snap.Source.Host, snap.Source.Path))
-	result.WriteString(fmt.Sprintf("Start Time:          %s\n", snap.StartTime.ToTime().Format(time.RFC3339)))
-	result.WriteString(fmt.Sprintf("End Time:            %s\n", snap.EndTime.ToTime().Format(time.RFC3339)))
-	result.WriteString(fmt.Sprintf("Total Size:          %s\n", ui.FormatSize(snap.Stats.TotalFileSize)))
-	result.WriteString(fmt.Sprintf("File Count:          %d\n", snap.Stats.TotalFileCount))
-	result.WriteString(fmt.Sprintf("Directory Count:     %d\n", snap.Stats.TotalDirectoryCount))
-	result.WriteString(fmt.Sprintf("Cached Files:        %d\n", snap.Stats.CachedFiles))
-	result.WriteString(fmt.Sprintf("Non-Cached Files:    %d\n", snap.Stats.NonCachedFiles))
-	result.WriteString(fmt.Sprintf("Error Count:         %d\n", snap.Stats.ErrorCount))
+	result.WriteString(fmt.Sprintf("Source:              %s@%s:%s\n", snap.Username, snap.Hostname, snap.Source))
+	result.WriteString(fmt.Sprintf("Start Time:          %s\n", snap.StartTime.Format(time.RFC3339)))
+	result.WriteString(fmt.Sprintf("End Time:            %s\n", snap.EndTime.Format(time.RFC3339)))
+	result.WriteString(fmt.Sprintf("Total Size:          %s\n", ui.FormatSize(snap.TotalSize)))
+	result.WriteString(fmt.Sprintf("File Count:          %d\n", snap.FileCount))
+	result.WriteString(fmt.Sprintf("Directory Count:     %d\n", snap.DirCount))
+	result.WriteString(fmt.Sprintf("Cached Files:        %d\n", snap.CachedFiles))
+	result.WriteString(fmt.Sprintf("Non-Cached Files:    %d\n", snap.NonCachedFiles))
+	result.WriteString(fmt.Sprintf("Error Count:         %d\n", snap.ErrorCount))
 
 	if snap.IncompleteReason != "" {
 		result.WriteString(fmt.Sprintf("Incomplete Reason:   %s\n", snap.IncompleteReason))
 	}
 
-	if len(snap.Tags) > 0 {
-		result.WriteString("Tags:\n")
-		for key, value := range snap.Tags {
-			result.WriteString(fmt.Sprintf("  %s: %s\n", key, value))
-		}
+	if len(snap.Pins) > 0 {
+		result.WriteString(fmt.Sprintf("Pins:                %s\n", strings.Join(snap.Pins, ", ")))
 	}
 
-	return result.String(), nil
-}
-
-// CreateBackup creates a backup of the specified paths
-func (sm *SnapshotManager) CreateBackup(paths []string, description string) error {
-	ctx := context.Background()
-	r, err := sm.km.openRepository(ctx)
-	if err != nil {
-		return err
+	if tags := snap.TagList(); len(tags) > 0 {
+		result.WriteString(fmt.Sprintf("Tags:                %s\n", strings.Join(tags, ", ")))
 	}
-	defer r.Close(ctx)
-
-	return repo.WriteSession(ctx, r, repo.WriteSessionOptions{
-		Purpose: "snapshot",
-	}, func(ctx context.Context, w repo.RepositoryWriter) error {
-		// Get client options for hostname and username
-		clientOpts := r.ClientOptions()
-
-		for i, path := range paths {
-			// Create spinner for this backup
-			spin := ui.NewSpinner(fmt.Sprintf("Creating backup [%d/%d]: %s", i+1, len(paths), ui.ShortenPath(path)))
-			spin.Start()
-
-			sourceInfo, err := snapshot.ParseSourceInfo(path, clientOpts.Hostname, clientOpts.Username)
-			if err != nil {
-				spin.Fail(fmt.Sprintf("Failed to parse source info: %v", err))
-				return fmt.Errorf("failed to parse source info for %s: %w", path, err)
-			}
-
-			// Get or create policy for this path
-			policyTree, err := policy.TreeForSource(ctx, w, sourceInfo)
-			if err != nil {
-				spin.Fail(fmt.Sprintf("Failed to get policy: %v", err))
-				return fmt.Errorf("failed to get policy for %s: %w", path, err)
-			}
-
-			// Create local filesystem
-			entry, err := localfs.NewEntry(path)
-			if err != nil {
-				spin.Fail(fmt.Sprintf("Failed to access path: %v", err))
-				return fmt.Errorf("failed to create filesystem entry for %s: %w", path, err)
-			}
 
-			spin.UpdateMessage(fmt.Sprintf("Uploading backup [%d/%d]: %s", i+1, len(paths), ui.ShortenPath(path)))
-
-			// Create uploader
-			u := upload.NewUploader(w)
-			u.Progress = &upload.CountingUploadProgress{}
-
-			manifest, err := u.Upload(ctx, entry, policyTree, sourceInfo)
-			if err != nil {
-				spin.Fail(fmt.Sprintf("Failed to upload: %v", err))
-				return fmt.Errorf("failed to upload %s: %w", path, err)
-			}
-
-			if description != "" {
-				manifest.Description = description
-			}
-
-			_, err = snapshot.SaveSnapshot(ctx, w, manifest)
-			if err != nil {
-				spin.Fail(fmt.Sprintf("Failed to save snapshot: %v", err))
-				return fmt.Errorf("failed to save snapshot: %w", err)
-			}
-
-			spin.Success(fmt.Sprintf("Snapshot created: %s", manifest.ID))
-		}
-
-		return nil
-	})
-}
-
-// RestoreSnapshot restores a snapshot to the target directory
-func (sm *SnapshotManager) RestoreSnapshot(snapshotID, targetDir string) error {
-	ctx := context.Background()
-	r, err := sm.km.openRepository(ctx)
-	if err != nil {
-		return err
-	}
-	defer r.Close(ctx)
-
-	// Find the snapshot by ID
-	manifestID, err := sm.resolveSnapshotID(ctx, r, snapshotID)
-	if err != nil {
-		return err
-	}
-
-	snap, err := snapshot.LoadSnapshot(ctx, r, manifestID)
-	if err != nil {
-		return fmt.Errorf("failed to load snapshot: %w", err)
-	}
-
-	// Create target directory if it doesn't exist
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		return fmt.Errorf("failed to create target directory: %w", err)
-	}
-
-	// Create filesystem output - ensure full restore, not shallow
-	output := &restore.FilesystemOutput{
-		TargetPath:           targetDir,
-		OverwriteDirectories: true,
-		OverwriteFiles:       true,
-		OverwriteSymlinks:    true,
-		SkipOwners:           true,
-		SkipPermissions:      true,
-	}
-
-	err = output.Init(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to initialize output: %w", err)
-	}
-
-	// Create a filesystem entry from the snapshot
-	rootEntry, err := snapshotfs.SnapshotRoot(r, snap)
-	if err != nil {
-		return fmt.Errorf("failed to create snapshot root entry: %w", err)
-	}
-	defer rootEntry.Close()
-
-	// Create progress bar
-	totalSize := snap.Stats.TotalFileSize
-	progressBar := ui.NewSimpleProgressBar(fmt.Sprintf("Restoring snapshot %s", snapshotID), totalSize)
-
-	// Restore with options for full deep restore (no placeholders)
-	stats, err := restore.Entry(ctx, r, output, rootEntry, restore.Options{
-		Parallel:               4,
-		RestoreDirEntryAtDepth: math.MaxInt32, // Unlimited depth for full restore
-		MinSizeForPlaceholder:  0,             // Default value - not used when depth is unlimited
-		ProgressCallback: func(ctx context.Context, s restore.Stats) {
-			progressBar.Update(s.RestoredTotalFileSize)
-			progressBar.Print()
-		},
-	})
-	if err != nil {
-		return fmt.Errorf("failed to restore: %w", err)
-	}
-
-	// Finish the progress bar
-	progressBar.Finish()
-
-	ui.Summaryf("Restore completed: %d files, %d directories, %s",
-		stats.RestoredFileCount, stats.RestoredDirCount, ui.FormatSize(stats.RestoredTotalFileSize))
-	return nil
+	return result.String(), nil
 }
 
 // DeleteSnapshot deletes a specific snapshot or all snapshots if allFlag is true.
 //
-// When allFlag is true, hostname and username optionally filter which snapshots are deleted.
-func (sm *SnapshotManager) DeleteSnapshot(snapshotID string, allFlag bool, hostname, username string) error {
+// When allFlag is true, hostname, username and tags optionally filter which
+// snapshots are deleted, and pinned snapshots are kept.
+func (sm *SnapshotManager) DeleteSnapshot(snapshotID string, allFlag bool, hostname, username string, tags []string) error {
 	ctx := context.Background()
 	r, err := sm.km.openRepository(ctx)
 	if err != nil {
@@ -321,14 +190,24 @@ func (sm *SnapshotManager) DeleteSnapshot(snapshotID string, allFlag bool, hostn
 				sources = filtered
 			}
 
-			var allSnapshots []*snapshot.Manifest
+			var allSnapshots, pinned []*snapshot.Manifest
 			for _, source := range sources {
 				snapshots, err := snapshot.ListSnapshots(ctx, r, source)
 				if err != nil {
 					continue
 				}
-				allSnapshots = append(allSnapshots, snapshots...)
+				for _, snap := range snapshots {
+					if !manifestToSummary(snap).MatchesTags(tags) {
+						continue
+					}
+					if len(snap.Pins) > 0 {
+						pinned = append(pinned, snap)
+						continue
+					}
+					allSnapshots = append(allSnapshots, snap)
+				}
 			}
+			notePinned(pinned)
 
 			if len(allSnapshots) == 0 {
 				ui.Info("No snapshots to delete.")
@@ -376,8 +255,9 @@ func (sm *SnapshotManager) DeleteSnapshot(snapshotID string, allFlag bool, hostn
 	})
 }
 
-// DeleteBackupGroup deletes all snapshots from a backup group
-func (sm *SnapshotManager) DeleteBackupGroup(backupName string) error {
+// DeleteBackupGroup deletes all unpinned snapshots from a backup group,
+// optionally only those with all the given tags
+func (sm *SnapshotManager) DeleteBackupGroup(backupName string, tags []string) error {
 	ctx := context.Background()
 	r, err := sm.km.openRepository(ctx)
 	if err != nil {
@@ -394,7 +274,7 @@ func (sm *SnapshotManager) DeleteBackupGroup(backupName string) error {
 			return fmt.Errorf("failed to list sources: %w", err)
 		}
 
-		var matchingSnapshots []*snapshot.Manifest
+		var matchingSnapshots, pinned []*snapshot.Manifest
 		for _, source := range sources {
 			snapshots, err := snapshot.ListSnapshots(ctx, r, source)
 			if err != nil {
@@ -402,12 +282,17 @@ func (sm *SnapshotManager) DeleteBackupGroup(backupName string) error {
 			}
 			for _, snap := range snapshots {
 				summary := manifestToSummary(snap)
-				name := extractBackupName(summary)
-				if name == backupName {
-					matchingSnapshots = append(matchingSnapshots, snap)
+				if extractBackupName(summary) != backupName || !summary.MatchesTags(tags) {
+					continue
+				}
+				if len(snap.Pins) > 0 {
+					pinned = append(pinned, snap)
+					continue
 				}
+				matchingSnapshots = append(matchingSnapshots, snap)
 			}
 		}
+		notePinned(pinned)
 
 		if len(matchingSnapshots) == 0 {
 			ui.Infof("No snapshots found for backup group '%s'.", backupName)
@@ -476,9 +361,21 @@ func (sm *SnapshotManager) DeleteBackupGroup(backupName string) error {
 	})
 }
 
+// notePinned tells the user which snapshots a bulk delete keeps because they are pinned
+func notePinned(pinned []*snapshot.Manifest) {
+	if len(pinned) == 0 {
+		return
+	}
+	ui.Notef("Keeping %d pinned snapshot(s):", len(pinned))
+	for _, snap := range pinned {
+		ui.Itemf("- %s (%s, pinned: %s)", snap.ID, snap.Source.Path, strings.Join(snap.Pins, ", "))
+	}
+}
+
 // extractBackupName extracts the backup name from a snapshot summary
 func extractBackupName(snap SnapshotSummary) string {
-	desc := snap.Description
+	// Further lines hold details such as hook output
+	desc, _, _ := strings.Cut(snap.Description, "\n")
 	if strings.HasPrefix(desc, "Automated backup: ") {
 		return strings.TrimPrefix(desc, "Automated backup: ")
 	}
@@ -491,62 +388,18 @@ func extractBackupName(snap SnapshotSummary) string {
 	return snap.Source
 }
 
-// DiffSnapshots compares two snapshots (simplified implementation)
-func (sm *SnapshotManager) DiffSnapshots(snapshot1, snapshot2 string) (string, error) {
-	ctx := context.Background()
-	r, err := sm.km.openRepository(ctx)
-	if err != nil {
-		return "", err
-	}
-	defer r.Close(ctx)
-
-	// Find both snapshots
-	manifestID1, err := sm.resolveSnapshotID(ctx, r, snapshot1)
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve snapshot %s: %w", snapshot1, err)
-	}
-
-	manifestID2, err := sm.resolveSnapshotID(ctx, r, snapshot2)
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve snapshot %s: %w", snapshot2, err)
+// RestoreBackupGroup restores all snapshots from a backup group to target directory
+func (sm *SnapshotManager) RestoreBackupGroup(backupName, targetDir string, opts RestoreOptions) error {
+	if len(opts.Paths) > 0 {
+		return fmt.Errorf("paths cannot be selected when restoring a backup group")
 	}
-
-	snap1, err := snapshot.LoadSnapshot(ctx, r, manifestID1)
-	if err != nil {
-		return "", fmt.Errorf("failed to load snapshot %s: %w", snapshot1, err)
+	if format := opts.format(targetDir); format != RestoreFormatDir {
+		return fmt.Errorf("backup groups can only be restored to a directory, not %s", format)
 	}
-
-	snap2, err := snapshot.LoadSnapshot(ctx, r, manifestID2)
-	if err != nil {
-		return "", fmt.Errorf("failed to load snapshot %s: %w", snapshot2, err)
+	if err := opts.validate(targetDir); err != nil {
+		return err
 	}
 
-	// Simple comparison of metadata
-	var result strings.Builder
-	result.WriteString(fmt.Sprintf("Comparing snapshots %s and %s:\n\n", snap1.ID, snap2.ID))
-	result.WriteString(fmt.Sprintf("Snapshot 1: %s (%s)\n", snap1.ID, snap1.StartTime.ToTime().Format(time.RFC3339)))
-	result.WriteString(fmt.Sprintf("  Size: %s, Files: %d, Dirs: %d\n",
-		ui.FormatSize(snap1.Stats.TotalFileSize), snap1.Stats.TotalFileCount, snap1.Stats.TotalDirectoryCount))
-
-	result.WriteString(fmt.Sprintf("Snapshot 2: %s (%s)\n", snap2.ID, snap2.StartTime.ToTime().Format(time.RFC3339)))
-	result.WriteString(fmt.Sprintf("  Size: %s, Files: %d, Dirs: %d\n",
-		ui.FormatSize(snap2.Stats.TotalFileSize), snap2.Stats.TotalFileCount, snap2.Stats.TotalDirectoryCount))
-
-	// Calculate differences
-	sizeDiff := snap2.Stats.TotalFileSize - snap1.Stats.TotalFileSize
-	filesDiff := int64(snap2.Stats.TotalFileCount) - int64(snap1.Stats.TotalFileCount)
-	dirsDiff := int64(snap2.Stats.TotalDirectoryCount) - int64(snap1.Stats.TotalDirectoryCount)
-
-	result.WriteString(fmt.Sprintf("\nDifferences:\n"))
-	result.WriteString(fmt.Sprintf("  Size: %+d bytes (%s)\n", sizeDiff, ui.FormatSize(sizeDiff)))
-	result.WriteString(fmt.Sprintf("  Files: %+d\n", filesDiff))
-	result.WriteString(fmt.Sprintf("  Directories: %+d\n", dirsDiff))
-
-	return result.String(), nil
-}
-
-// RestoreBackupGroup restores all snapshots from a backup group to target directory
-func (sm *SnapshotManager) RestoreBackupGroup(backupName, targetDir string) error {
 	ctx := context.Background()
 	r, err := sm.km.openRepository(ctx)
 	if err != nil {
@@ -570,7 +423,7 @@ func (sm *SnapshotManager) RestoreBackupGroup(backupName, targetDir string) erro
 		}
 
 		for _, snap := range snapshots {
-			desc := snap.Description
+			desc, _, _ := strings.Cut(snap.Description, "\n")
 			if strings.HasPrefix(desc, "Automated backup: ") {
 				desc = strings.TrimPrefix(desc, "Automated backup: ")
 			}
@@ -632,6 +485,7 @@ func (sm *SnapshotManager) RestoreBackupGroup(backupName, targetDir string) erro
 
 	// Create overall progress bar for the entire backup group
 	progressBar := ui.NewSimpleProgressBar(fmt.Sprintf("Restoring backup group '%s'", backupName), totalSize)
+	defer progressBar.Stop()
 	restoredSize := int64(0)
 
 	// Restore latest snapshot for each path
@@ -639,19 +493,9 @@ func (sm *SnapshotManager) RestoreBackupGroup(backupName, targetDir string) erro
 		snap := item.snapshot
 		restoreTarget := item.target
 
-		// Create filesystem output
-		output := &restore.FilesystemOutput{
-			TargetPath:           restoreTarget,
-			OverwriteDirectories: true,
-			OverwriteFiles:       true,
-			OverwriteSymlinks:    true,
-			SkipOwners:           true,
-			SkipPermissions:      true,
-		}
-
-		err := output.Init(ctx)
+		output, err := newRestoreOutput(ctx, restoreTarget, opts)
 		if err != nil {
-			return fmt.Errorf("failed to initialize output: %w", err)
+			return err
 		}
 
 		// Create a filesystem entry from the snapshot
@@ -661,15 +505,10 @@ func (sm *SnapshotManager) RestoreBackupGroup(backupName, targetDir string) erro
 		}
 
 		// Restore with progress tracking
-		_, err = restore.Entry(ctx, r, output, rootEntry, restore.Options{
-			Parallel:               4,
-			RestoreDirEntryAtDepth: math.MaxInt32,
-			MinSizeForPlaceholder:  0,
-			ProgressCallback: func(ctx context.Context, s restore.Stats) {
-				progressBar.Update(restoredSize + s.RestoredTotalFileSize)
-				progressBar.Print()
-			},
-		})
+		_, err = restore.Entry(ctx, r, output, rootEntry, opts.restoreOptions(func(ctx context.Context, s restore.Stats) {
+			progressBar.Update(restoredSize + s.RestoredTotalFileSize + s.SkippedTotalFileSize)
+			progressBar.Print()
+		}))
 
 		rootEntry.Close()
 
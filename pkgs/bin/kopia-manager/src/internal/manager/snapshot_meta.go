@@ -0,0 +1,197 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// snapshotTagPrefix marks user defined tags in the manifest, as kopia does
+const snapshotTagPrefix = "tag:"
+
+// DefaultPinReason is used when a snapshot is pinned without a reason
+const DefaultPinReason = "km"
+
+// SnapshotUpdate is the result of changing snapshot metadata.
+//
+// Kopia stores the metadata in the snapshot manifest, so updating it gives
+// the snapshot a new ID.
+type SnapshotUpdate struct {
+	PreviousID string          `json:"previousId"`
+	Snapshot   SnapshotSummary `json:"snapshot"`
+	Changed    bool            `json:"changed"`
+}
+
+// PinSnapshot adds pins to a snapshot so retention never expires it
+func (sm *SnapshotManager) PinSnapshot(snapshotID string, reasons []string) (*SnapshotUpdate, error) {
+	if len(reasons) == 0 {
+		reasons = []string{DefaultPinReason}
+	}
+	return sm.updateSnapshot(snapshotID, "pin-snapshot", func(m *snapshot.Manifest) (bool, error) {
+		return m.UpdatePins(reasons, nil), nil
+	})
+}
+
+// UnpinSnapshot removes the given pins from a snapshot, or all pins if none are given
+func (sm *SnapshotManager) UnpinSnapshot(snapshotID string, reasons []string) (*SnapshotUpdate, error) {
+	return sm.updateSnapshot(snapshotID, "unpin-snapshot", func(m *snapshot.Manifest) (bool, error) {
+		if len(reasons) == 0 {
+			reasons = m.Pins
+		}
+		return m.UpdatePins(nil, reasons), nil
+	})
+}
+
+// TagSnapshot adds or replaces key:value tags on a snapshot
+func (sm *SnapshotManager) TagSnapshot(snapshotID string, tags []string) (*SnapshotUpdate, error) {
+	parsed, err := parseSnapshotTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return sm.updateSnapshot(snapshotID, "tag-snapshot", func(m *snapshot.Manifest) (bool, error) {
+		if m.Tags == nil {
+			m.Tags = make(map[string]string)
+		}
+		changed := false
+		for key, value := range parsed {
+			if current, ok := m.Tags[snapshotTagPrefix+key]; !ok || current != value {
+				m.Tags[snapshotTagPrefix+key] = value
+				changed = true
+			}
+		}
+		return changed, nil
+	})
+}
+
+// UntagSnapshot removes tags by key from a snapshot
+func (sm *SnapshotManager) UntagSnapshot(snapshotID string, keys []string) (*SnapshotUpdate, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no tags given")
+	}
+
+	return sm.updateSnapshot(snapshotID, "untag-snapshot", func(m *snapshot.Manifest) (bool, error) {
+		changed := false
+		for _, key := range keys {
+			// Accept key:value as printed by km list
+			key, _, _ = strings.Cut(key, ":")
+			if _, ok := m.Tags[snapshotTagPrefix+key]; ok {
+				delete(m.Tags, snapshotTagPrefix+key)
+				changed = true
+			}
+		}
+		return changed, nil
+	})
+}
+
+// DescribeSnapshot replaces the description of a snapshot
+func (sm *SnapshotManager) DescribeSnapshot(snapshotID, description string) (*SnapshotUpdate, error) {
+	return sm.updateSnapshot(snapshotID, "describe-snapshot", func(m *snapshot.Manifest) (bool, error) {
+		if m.Description == description {
+			return false, nil
+		}
+		m.Description = description
+		return true, nil
+	})
+}
+
+// updateSnapshot loads a snapshot, applies update and saves it if anything changed
+func (sm *SnapshotManager) updateSnapshot(snapshotID, purpose string, update func(*snapshot.Manifest) (bool, error)) (*SnapshotUpdate, error) {
+	ctx := context.Background()
+	r, err := sm.km.openRepository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close(ctx)
+
+	snap, err := sm.loadSnapshot(ctx, r, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SnapshotUpdate{PreviousID: string(snap.ID)}
+	result.Changed, err = update(snap)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Changed {
+		err = repo.WriteSession(ctx, r, repo.WriteSessionOptions{
+			Purpose: purpose,
+		}, func(ctx context.Context, w repo.RepositoryWriter) error {
+			if err := snapshot.UpdateSnapshot(ctx, w, snap); err != nil {
+				return fmt.Errorf("failed to update snapshot: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result.Snapshot = manifestToSummary(snap)
+	return result, nil
+}
+
+// parseSnapshotTags parses key:value tags; the value may be empty
+func parseSnapshotTags(tags []string) (map[string]string, error) {
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("no tags given")
+	}
+
+	parsed := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		key, value, _ := strings.Cut(tag, ":")
+		if key == "" {
+			return nil, fmt.Errorf("invalid tag %q, expected key:value", tag)
+		}
+		parsed[key] = value
+	}
+	return parsed, nil
+}
+
+// userTags returns the user defined tags of a manifest without their prefix
+func userTags(m *snapshot.Manifest) map[string]string {
+	var tags map[string]string
+	for key, value := range m.Tags {
+		if name, ok := strings.CutPrefix(key, snapshotTagPrefix); ok {
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[name] = value
+		}
+	}
+	return tags
+}
+
+// TagList returns the tags of the snapshot as sorted key:value strings
+func (s SnapshotSummary) TagList() []string {
+	list := make([]string, 0, len(s.Tags))
+	for key, value := range s.Tags {
+		if value == "" {
+			list = append(list, key)
+		} else {
+			list = append(list, key+":"+value)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// MatchesTags reports whether the snapshot has all the given tags.
+//
+// A filter of "key" matches any value, "key:value" only that value.
+func (s SnapshotSummary) MatchesTags(filters []string) bool {
+	for _, filter := range filters {
+		key, value, hasValue := strings.Cut(filter, ":")
+		current, ok := s.Tags[key]
+		if !ok || (hasValue && current != value) {
+			return false
+		}
+	}
+	return true
+}
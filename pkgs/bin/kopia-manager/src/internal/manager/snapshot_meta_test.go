@@ -0,0 +1,202 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseSnapshotTags(t *testing.T) {
+	tests := []struct {
+		tags    []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{tags: []string{"env:prod", "keep"}, want: map[string]string{"env": "prod", "keep": ""}},
+		{tags: []string{"url:http://x"}, want: map[string]string{"url": "http://x"}},
+		{tags: []string{":prod"}, wantErr: true},
+		{tags: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSnapshotTags(tt.tags)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSnapshotTags(%q) = %v, want error", tt.tags, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSnapshotTags(%q) error: %v", tt.tags, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseSnapshotTags(%q) = %v, want %v", tt.tags, got, tt.want)
+		}
+		for key, value := range tt.want {
+			if v, ok := got[key]; !ok || v != value {
+				t.Errorf("parseSnapshotTags(%q)[%q] = %q, want %q", tt.tags, key, v, value)
+			}
+		}
+	}
+}
+
+func TestSnapshotSummaryTags(t *testing.T) {
+	s := SnapshotSummary{Tags: map[string]string{"env": "prod", "keep": "", "app": "db"}}
+
+	if got, want := s.TagList(), []string{"app:db", "env:prod", "keep"}; !slices.Equal(got, want) {
+		t.Errorf("TagList() = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		filters []string
+		want    bool
+	}{
+		{nil, true},
+		{[]string{"env"}, true},
+		{[]string{"env:prod"}, true},
+		{[]string{"env:dev"}, false},
+		{[]string{"keep:"}, true},
+		{[]string{"env:prod", "app:db"}, true},
+		{[]string{"env:prod", "missing"}, false},
+	}
+	for _, tt := range tests {
+		if got := s.MatchesTags(tt.filters); got != tt.want {
+			t.Errorf("MatchesTags(%q) = %v, want %v", tt.filters, got, tt.want)
+		}
+	}
+}
+
+func TestSnapshotMetadata(t *testing.T) {
+	km := newTestManager(t)
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "a.txt"), "a")
+	id := backup(t, km, src, BackupOptions{})
+
+	update, err := km.TagSnapshot(id, []string{"env:prod", "keep"})
+	if err != nil {
+		t.Fatalf("TagSnapshot() error: %v", err)
+	}
+	if !update.Changed || update.PreviousID != id || update.Snapshot.ID == id {
+		t.Errorf("TagSnapshot() = %+v, want a changed snapshot with a new ID", update)
+	}
+	if got := update.Snapshot.TagList(); !slices.Equal(got, []string{"env:prod", "keep"}) {
+		t.Errorf("tags = %q, want [env:prod keep]", got)
+	}
+	id = update.Snapshot.ID
+
+	update, err = km.TagSnapshot(id, []string{"env:prod"})
+	if err != nil {
+		t.Fatalf("TagSnapshot() error: %v", err)
+	}
+	if update.Changed || update.Snapshot.ID != id {
+		t.Errorf("re-tagging with the same value = %+v, want unchanged", update)
+	}
+
+	update, err = km.UntagSnapshot(id, []string{"keep", "env:prod"})
+	if err != nil {
+		t.Fatalf("UntagSnapshot() error: %v", err)
+	}
+	if !update.Changed || len(update.Snapshot.Tags) != 0 {
+		t.Errorf("UntagSnapshot() = %+v, want all tags removed", update)
+	}
+	id = update.Snapshot.ID
+
+	update, err = km.PinSnapshot(id, nil)
+	if err != nil {
+		t.Fatalf("PinSnapshot() error: %v", err)
+	}
+	if !slices.Equal(update.Snapshot.Pins, []string{DefaultPinReason}) {
+		t.Errorf("pins = %q, want [%s]", update.Snapshot.Pins, DefaultPinReason)
+	}
+	id = update.Snapshot.ID
+
+	update, err = km.UnpinSnapshot(id, nil)
+	if err != nil {
+		t.Fatalf("UnpinSnapshot() error: %v", err)
+	}
+	if len(update.Snapshot.Pins) != 0 {
+		t.Errorf("pins = %q after unpinning all", update.Snapshot.Pins)
+	}
+	id = update.Snapshot.ID
+
+	update, err = km.DescribeSnapshot(id, "before upgrade")
+	if err != nil {
+		t.Fatalf("DescribeSnapshot() error: %v", err)
+	}
+	if update.Snapshot.Description != "before upgrade" {
+		t.Errorf("description = %q, want %q", update.Snapshot.Description, "before upgrade")
+	}
+
+	// Only the updated manifest remains
+	snapshots, err := km.ListSnapshots("", "")
+	if err != nil {
+		t.Fatalf("ListSnapshots() error: %v", err)
+	}
+	if len(snapshots) != 1 || snapshots[0].ID != update.Snapshot.ID {
+		t.Errorf("ListSnapshots() = %+v, want only %s", snapshots, update.Snapshot.ID)
+	}
+}
+
+func TestDeleteSnapshotsByTag(t *testing.T) {
+	km := newTestManager(t)
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "a.txt"), "a")
+
+	tag := func(tags ...string) string {
+		update, err := km.TagSnapshot(backup(t, km, src, BackupOptions{}), tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return update.Snapshot.ID
+	}
+	dev := tag("env:dev")
+	prod := tag("env:prod")
+	pinnedDev := tag("env:dev")
+	if _, err := km.PinSnapshot(pinnedDev, []string{"audit"}); err != nil {
+		t.Fatal(err)
+	}
+
+	confirmDeletion(t)
+	if err := km.DeleteSnapshot("", true, "", "", []string{"env:dev"}); err != nil {
+		t.Fatalf("DeleteSnapshot() error: %v", err)
+	}
+
+	snapshots, err := km.ListSnapshots("", "")
+	if err != nil {
+		t.Fatalf("ListSnapshots() error: %v", err)
+	}
+	var remaining []string
+	for _, s := range snapshots {
+		if s.ID == dev {
+			t.Errorf("snapshot %s tagged env:dev was not deleted", dev)
+		}
+		remaining = append(remaining, s.TagList()...)
+	}
+	slices.Sort(remaining)
+	// The pinned env:dev snapshot and the env:prod one are kept
+	if len(snapshots) != 2 || !slices.Equal(remaining, []string{"env:dev", "env:prod"}) {
+		t.Errorf("remaining snapshots %+v, want the pinned one and %s", snapshots, prod)
+	}
+}
+
+// confirmDeletion answers the deletion prompt with "yes"
+func confirmDeletion(t *testing.T) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString("yes\n"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		r.Close()
+	})
+}
@@ -0,0 +1,520 @@
+package manager
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"kopia-manager/internal/ui"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// Restore output formats
+const (
+	RestoreFormatAuto = "auto"
+	RestoreFormatDir  = "dir"
+	RestoreFormatTar  = "tar"
+	RestoreFormatTgz  = "tgz"
+	RestoreFormatZip  = "zip"
+)
+
+// RestoreFormats lists the accepted values of RestoreOptions.Format
+var RestoreFormats = []string{RestoreFormatAuto, RestoreFormatDir, RestoreFormatTar, RestoreFormatTgz, RestoreFormatZip}
+
+// Restore plan actions
+const (
+	RestoreActionCreate    = "create"
+	RestoreActionOverwrite = "overwrite"
+	RestoreActionSkip      = "skip"
+)
+
+// DefaultRestoreParallel is the number of files restored concurrently by default
+const DefaultRestoreParallel = 4
+
+// RestoreOptions controls which entries RestoreSnapshot writes and how
+type RestoreOptions struct {
+	// Paths limits the restore to these paths or glob patterns relative to the snapshot root.
+	// Absolute paths below the snapshot source are accepted as well.
+	Paths []string
+
+	// PreserveOwners and PreservePermissions restore file ownership and modes; restoring
+	// owners usually requires root. Failing to restore them fails the restore.
+	PreserveOwners      bool
+	PreservePermissions bool
+
+	// NoOverwrite keeps files and symlinks that already exist in the target
+	NoOverwrite bool
+
+	// Parallel is the number of files restored concurrently, DefaultRestoreParallel if zero
+	Parallel int
+
+	// Shallow writes placeholder files instead of content for entries deeper than
+	// ShallowDepth levels below the target; placeholders are expanded on demand by Kopia
+	Shallow      bool
+	ShallowDepth int32
+
+	// Format is one of RestoreFormats; "auto" picks an archive format from the target's
+	// extension (.tar, .tar.gz/.tgz, .zip) and restores to a directory otherwise
+	Format string
+}
+
+// format resolves the output format for targetDir
+func (opts RestoreOptions) format(targetDir string) string {
+	if opts.Format != "" && opts.Format != RestoreFormatAuto {
+		return opts.Format
+	}
+
+	switch {
+	case strings.HasSuffix(targetDir, ".tar"):
+		return RestoreFormatTar
+	case strings.HasSuffix(targetDir, ".tar.gz"), strings.HasSuffix(targetDir, ".tgz"):
+		return RestoreFormatTgz
+	case strings.HasSuffix(targetDir, ".zip"):
+		return RestoreFormatZip
+	default:
+		return RestoreFormatDir
+	}
+}
+
+// validate rejects option combinations that the chosen output cannot honour
+func (opts RestoreOptions) validate(targetDir string) error {
+	if !slices.Contains(RestoreFormats, opts.Format) && opts.Format != "" {
+		return fmt.Errorf("unknown restore format %q (valid: %s)", opts.Format, strings.Join(RestoreFormats, ", "))
+	}
+	if opts.Parallel < 0 {
+		return fmt.Errorf("parallelism must be positive, got %d", opts.Parallel)
+	}
+	if opts.Shallow && opts.ShallowDepth < 0 {
+		return fmt.Errorf("shallow depth must not be negative, got %d", opts.ShallowDepth)
+	}
+
+	if opts.format(targetDir) != RestoreFormatDir {
+		if opts.Shallow {
+			return fmt.Errorf("shallow restores are only supported when restoring to a directory")
+		}
+		return nil
+	}
+	if opts.Shallow && opts.NoOverwrite {
+		return fmt.Errorf("shallow restores cannot be combined with keeping existing files")
+	}
+	return nil
+}
+
+// restoreOptions builds the Kopia restore options
+func (opts RestoreOptions) restoreOptions(progress restore.ProgressCallback) restore.Options {
+	parallel := opts.Parallel
+	if parallel == 0 {
+		parallel = DefaultRestoreParallel
+	}
+
+	// Unlimited depth restores everything, no placeholders
+	depth := int32(math.MaxInt32)
+	if opts.Shallow {
+		depth = opts.ShallowDepth
+	}
+
+	return restore.Options{
+		Parallel:               parallel,
+		Incremental:            opts.NoOverwrite,
+		RestoreDirEntryAtDepth: depth,
+		MinSizeForPlaceholder:  0,
+		ProgressCallback:       progress,
+	}
+}
+
+// newRestoreOutput creates the directory or archive output for targetDir
+func newRestoreOutput(ctx context.Context, targetDir string, opts RestoreOptions) (restore.Output, error) {
+	format := opts.format(targetDir)
+	if format == RestoreFormatDir {
+		// Create target directory if it doesn't exist
+		if err := os.MkdirAll(targetDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create target directory: %w", err)
+		}
+
+		fsOutput := &restore.FilesystemOutput{
+			TargetPath:             targetDir,
+			OverwriteDirectories:   true,
+			OverwriteFiles:         !opts.NoOverwrite,
+			OverwriteSymlinks:      !opts.NoOverwrite,
+			IgnorePermissionErrors: !opts.PreserveOwners && !opts.PreservePermissions,
+			SkipOwners:             !opts.PreserveOwners,
+			SkipPermissions:        !opts.PreservePermissions,
+		}
+		if err := fsOutput.Init(ctx); err != nil {
+			return nil, fmt.Errorf("failed to initialize output: %w", err)
+		}
+
+		var output restore.Output = fsOutput
+		if opts.NoOverwrite {
+			output = &keepExistingOutput{FilesystemOutput: fsOutput}
+		}
+		return output, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(targetDir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create target directory: %w", err)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if opts.NoOverwrite {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(targetDir, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	switch format {
+	case RestoreFormatTar:
+		return restore.NewTarOutput(f), nil
+	case RestoreFormatTgz:
+		return restore.NewTarOutput(&gzipFile{Writer: gzip.NewWriter(f), file: f}), nil
+	default:
+		return restore.NewZipOutput(f, zip.Deflate), nil
+	}
+}
+
+// keepExistingOutput makes an incremental restore skip every file and symlink that already
+// exists in the target, rather than only those whose size and time match the snapshot
+type keepExistingOutput struct {
+	*restore.FilesystemOutput
+}
+
+// FileExists implements restore.Output
+func (o *keepExistingOutput) FileExists(_ context.Context, relativePath string, _ fs.File) bool {
+	return o.exists(relativePath)
+}
+
+// SymlinkExists implements restore.Output
+func (o *keepExistingOutput) SymlinkExists(_ context.Context, relativePath string, _ fs.Symlink) bool {
+	return o.exists(relativePath)
+}
+
+func (o *keepExistingOutput) exists(relativePath string) bool {
+	_, err := os.Lstat(filepath.Join(o.TargetPath, filepath.FromSlash(relativePath)))
+	return err == nil
+}
+
+// gzipFile closes the gzip stream and then the archive file below it
+type gzipFile struct {
+	*gzip.Writer
+	file *os.File
+}
+
+// Close flushes the gzip stream and closes the file
+func (g *gzipFile) Close() error {
+	if err := g.Writer.Close(); err != nil {
+		g.file.Close()
+		return err
+	}
+	return g.file.Close()
+}
+
+// RestorePlanEntry describes a file or symlink a restore would write
+type RestorePlanEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Action string `json:"action"`
+}
+
+// RestorePlan lists what a restore would write to the target directory
+type RestorePlan struct {
+	Snapshot   SnapshotSummary    `json:"snapshot"`
+	TargetDir  string             `json:"targetDir"`
+	Entries    []RestorePlanEntry `json:"entries"`
+	TotalSize  int64              `json:"totalSize"`
+	Overwrites int                `json:"overwrites"`
+	Skipped    int                `json:"skipped"`
+}
+
+// RestoreSnapshot restores a snapshot, or the selected paths of it, to the target directory
+// or archive
+func (sm *SnapshotManager) RestoreSnapshot(snapshotID, targetDir string, opts RestoreOptions) error {
+	if err := opts.validate(targetDir); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	r, err := sm.km.openRepository(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close(ctx)
+
+	snap, err := sm.loadSnapshot(ctx, r, snapshotID)
+	if err != nil {
+		return err
+	}
+
+	rootEntry, err := restoreRoot(r, snap, opts)
+	if err != nil {
+		return err
+	}
+	defer rootEntry.Close()
+
+	// Only the selected entries count towards progress when restoring a subset
+	totalSize := snap.Stats.TotalFileSize
+	if len(opts.Paths) > 0 {
+		plan, err := planRestore(ctx, rootEntry, targetDir, opts)
+		if err != nil {
+			return err
+		}
+		if len(plan.Entries) == 0 {
+			return fmt.Errorf("no entries in snapshot %s match %s", snapshotID, strings.Join(opts.Paths, ", "))
+		}
+		totalSize = plan.TotalSize
+	}
+
+	output, err := newRestoreOutput(ctx, targetDir, opts)
+	if err != nil {
+		return err
+	}
+	archive := opts.format(targetDir) != RestoreFormatDir
+
+	// Create progress bar
+	progressBar := ui.NewSimpleProgressBar(fmt.Sprintf("Restoring snapshot %s", snapshotID), totalSize)
+	defer progressBar.Stop()
+
+	stats, err := restore.Entry(ctx, r, output, rootEntry, opts.restoreOptions(func(ctx context.Context, s restore.Stats) {
+		progressBar.Update(s.RestoredTotalFileSize + s.SkippedTotalFileSize)
+		progressBar.Print()
+	}))
+	if err != nil {
+		if archive {
+			// Don't leave a truncated archive behind
+			output.Close(ctx)
+			os.Remove(targetDir)
+		}
+		return fmt.Errorf("failed to restore: %w", err)
+	}
+
+	// Finish the progress bar
+	progressBar.Finish()
+
+	ui.Summaryf("Restore completed: %d files, %d directories, %s",
+		stats.RestoredFileCount, stats.RestoredDirCount, ui.FormatSize(stats.RestoredTotalFileSize))
+	if stats.SkippedCount > 0 {
+		ui.Notef("Kept %d existing files (%s)", stats.SkippedCount, ui.FormatSize(stats.SkippedTotalFileSize))
+	}
+	if archive {
+		ui.Notef("Archive written to %s", targetDir)
+	}
+	return nil
+}
+
+// PlanRestore reports the files a restore of snapshotID into targetDir would write and
+// which of them already exist there, without writing anything.
+func (sm *SnapshotManager) PlanRestore(snapshotID, targetDir string, opts RestoreOptions) (*RestorePlan, error) {
+	if err := opts.validate(targetDir); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	r, err := sm.km.openRepository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close(ctx)
+
+	snap, err := sm.loadSnapshot(ctx, r, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	rootEntry, err := restoreRoot(r, snap, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer rootEntry.Close()
+
+	plan, err := planRestore(ctx, rootEntry, targetDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	plan.Snapshot = manifestToSummary(snap)
+	return plan, nil
+}
+
+// loadSnapshot resolves a (partial) snapshot ID and loads its manifest
+func (sm *SnapshotManager) loadSnapshot(ctx context.Context, r repo.Repository, snapshotID string) (*snapshot.Manifest, error) {
+	manifestID, err := sm.resolveSnapshotID(ctx, r, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	snap, err := snapshot.LoadSnapshot(ctx, r, manifestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+	return snap, nil
+}
+
+// restoreRoot returns the snapshot root entry, limited to the selected paths if any
+func restoreRoot(r repo.Repository, snap *snapshot.Manifest, opts RestoreOptions) (fs.Entry, error) {
+	patterns := make([]string, 0, len(opts.Paths))
+	for _, p := range opts.Paths {
+		patterns = append(patterns, relativeToSource(snap.Source.Path, p))
+	}
+
+	filter, err := newPathFilter(patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	rootEntry, err := snapshotfs.SnapshotRoot(r, snap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot root entry: %w", err)
+	}
+	if len(filter) == 0 {
+		return rootEntry, nil
+	}
+
+	dir, ok := rootEntry.(fs.Directory)
+	if !ok {
+		rootEntry.Close()
+		return nil, fmt.Errorf("snapshot of %s is a single file, paths cannot be selected", snap.Source.Path)
+	}
+	return &filteredDirectory{Directory: dir, filter: filter}, nil
+}
+
+// relativeToSource turns an absolute path below the snapshot source into a path relative
+// to the snapshot root; other paths are returned unchanged
+func relativeToSource(sourcePath, p string) string {
+	source := strings.TrimSuffix(filepath.ToSlash(sourcePath), "/")
+	p = filepath.ToSlash(p)
+	if source != "" && (p == source || strings.HasPrefix(p, source+"/")) {
+		return strings.TrimPrefix(p, source)
+	}
+	return p
+}
+
+// planRestore walks the entries a restore of root would write. Entries going into an
+// archive are always created.
+func planRestore(ctx context.Context, root fs.Entry, targetDir string, opts RestoreOptions) (*RestorePlan, error) {
+	plan := &RestorePlan{TargetDir: targetDir}
+	archive := opts.format(targetDir) != RestoreFormatDir
+
+	var walk func(ctx context.Context, relPath string, e fs.Entry) error
+	walk = func(ctx context.Context, relPath string, e fs.Entry) error {
+		if dir, ok := e.(fs.Directory); ok {
+			return fs.IterateEntries(ctx, dir, func(ctx context.Context, child fs.Entry) error {
+				defer child.Close()
+				return walk(ctx, path.Join(relPath, child.Name()), child)
+			})
+		}
+
+		entry := RestorePlanEntry{Path: relPath, Size: e.Size(), Action: RestoreActionCreate}
+		if relPath == "" {
+			entry.Path = e.Name()
+		}
+		if !archive {
+			if _, err := os.Lstat(filepath.Join(targetDir, filepath.FromSlash(relPath))); err == nil {
+				entry.Action = RestoreActionOverwrite
+				if opts.NoOverwrite {
+					entry.Action = RestoreActionSkip
+				}
+			}
+		}
+
+		switch entry.Action {
+		case RestoreActionOverwrite:
+			plan.Overwrites++
+		case RestoreActionSkip:
+			plan.Skipped++
+		}
+		plan.Entries = append(plan.Entries, entry)
+		plan.TotalSize += entry.Size
+		return nil
+	}
+
+	if err := walk(ctx, "", root); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return plan, nil
+}
+
+// filteredDirectory exposes only the entries of a snapshot directory that match a path
+// filter, along with the directories leading to them
+type filteredDirectory struct {
+	fs.Directory
+	filter  pathFilter
+	relPath string
+}
+
+// Child returns the named child if it is selected by the filter
+func (d *filteredDirectory) Child(ctx context.Context, name string) (fs.Entry, error) {
+	childPath := path.Join(d.relPath, name)
+	if !d.filter.mayContain(childPath) {
+		return nil, fs.ErrEntryNotFound
+	}
+
+	child, err := d.Directory.Child(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if entry := d.wrap(childPath, child); entry != nil {
+		return entry, nil
+	}
+	child.Close()
+	return nil, fs.ErrEntryNotFound
+}
+
+// Iterate lists the children selected by the filter
+func (d *filteredDirectory) Iterate(ctx context.Context) (fs.DirectoryIterator, error) {
+	var selected []fs.Entry
+	err := fs.IterateEntries(ctx, d.Directory, func(ctx context.Context, child fs.Entry) error {
+		childPath := path.Join(d.relPath, child.Name())
+		if entry := d.wrap(childPath, child); entry != nil {
+			selected = append(selected, entry)
+		} else {
+			child.Close()
+		}
+		return nil
+	})
+	if err != nil {
+		for _, e := range selected {
+			e.Close()
+		}
+		return nil, err
+	}
+	return fs.StaticIterator(selected, nil), nil
+}
+
+// SupportsMultipleIterations is always true as Iterate returns a static listing
+func (d *filteredDirectory) SupportsMultipleIterations() bool {
+	return true
+}
+
+// DirEntry exposes the snapshot directory entry, needed by restore for shallow placeholders
+func (d *filteredDirectory) DirEntry() *snapshot.DirEntry {
+	if de, ok := d.Directory.(snapshot.HasDirEntry); ok {
+		return de.DirEntry()
+	}
+	return nil
+}
+
+// wrap returns the child as seen through the filter, or nil if it is not selected.
+// Fully selected directories are returned unfiltered.
+func (d *filteredDirectory) wrap(childPath string, child fs.Entry) fs.Entry {
+	if d.filter.matches(childPath) {
+		return child
+	}
+	if dir, ok := child.(fs.Directory); ok && d.filter.mayContain(childPath) {
+		return &filteredDirectory{Directory: dir, filter: d.filter, relPath: childPath}
+	}
+	return nil
+}
+
+var _ snapshot.HasDirEntry = (*filteredDirectory)(nil)
@@ -0,0 +1,150 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot/restore"
+)
+
+func TestPlanRestorePaths(t *testing.T) {
+	km := newTestManager(t)
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "docs", "a.md"), "aaa")
+	writeFile(t, filepath.Join(src, "docs", "b.txt"), "bb")
+	writeFile(t, filepath.Join(src, "src", "main.go"), "package main")
+	id := backup(t, km, src, BackupOptions{})
+
+	target := t.TempDir()
+	writeFile(t, filepath.Join(target, "docs", "a.md"), "existing")
+
+	tests := []struct {
+		name        string
+		opts        RestoreOptions
+		want        map[string]string // path -> action
+		wantSize    int64
+		wantOverwr  int
+		wantSkipped int
+	}{
+		{
+			name:       "everything",
+			want:       map[string]string{"docs/a.md": RestoreActionOverwrite, "docs/b.txt": RestoreActionCreate, "src/main.go": RestoreActionCreate},
+			wantSize:   17,
+			wantOverwr: 1,
+		},
+		{
+			name:     "glob",
+			opts:     RestoreOptions{Paths: []string{"*/*.txt", "src"}},
+			want:     map[string]string{"docs/b.txt": RestoreActionCreate, "src/main.go": RestoreActionCreate},
+			wantSize: 14,
+		},
+		{
+			name:        "absolute path below the source keeps existing files",
+			opts:        RestoreOptions{Paths: []string{filepath.Join(src, "docs")}, NoOverwrite: true},
+			want:        map[string]string{"docs/a.md": RestoreActionSkip, "docs/b.txt": RestoreActionCreate},
+			wantSize:    5,
+			wantSkipped: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := km.PlanRestore(id, target, tt.opts)
+			if err != nil {
+				t.Fatalf("PlanRestore() error: %v", err)
+			}
+
+			got := make(map[string]string, len(plan.Entries))
+			for _, e := range plan.Entries {
+				got[e.Path] = e.Action
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("planned %v, want %v", got, tt.want)
+			}
+			for p, action := range tt.want {
+				if got[p] != action {
+					t.Errorf("%s: action = %q, want %q", p, got[p], action)
+				}
+			}
+			if plan.TotalSize != tt.wantSize || plan.Overwrites != tt.wantOverwr || plan.Skipped != tt.wantSkipped {
+				t.Errorf("size %d, overwrites %d, skipped %d; want %d, %d, %d",
+					plan.TotalSize, plan.Overwrites, plan.Skipped, tt.wantSize, tt.wantOverwr, tt.wantSkipped)
+			}
+		})
+	}
+
+	// The dry run writes nothing
+	if _, err := os.Stat(filepath.Join(target, "src")); !os.IsNotExist(err) {
+		t.Errorf("PlanRestore() created files in the target: %v", err)
+	}
+}
+
+func TestRestoreOutputPermissionErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   RestoreOptions
+		ignore bool
+	}{
+		{name: "default", ignore: true},
+		{name: "preserve owners", opts: RestoreOptions{PreserveOwners: true}},
+		{name: "preserve permissions", opts: RestoreOptions{PreservePermissions: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := newRestoreOutput(context.Background(), t.TempDir(), tt.opts)
+			if err != nil {
+				t.Fatalf("newRestoreOutput() error: %v", err)
+			}
+			fsOutput, ok := output.(*restore.FilesystemOutput)
+			if !ok {
+				t.Fatalf("output is %T, want *restore.FilesystemOutput", output)
+			}
+			if fsOutput.IgnorePermissionErrors != tt.ignore {
+				t.Errorf("IgnorePermissionErrors = %v, want %v", fsOutput.IgnorePermissionErrors, tt.ignore)
+			}
+		})
+	}
+}
+
+func TestRestoreFilterClosesEntries(t *testing.T) {
+	km := newTestManager(t)
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "docs", "a.md"), "aaa")
+	writeFile(t, filepath.Join(src, "docs", "b.txt"), "bb")
+	writeFile(t, filepath.Join(src, "src", "main.go"), "package main")
+	id := backup(t, km, src, BackupOptions{})
+
+	ctx := context.Background()
+	root := snapshotRoot(t, km, id)
+
+	filter, err := newPathFilter("docs/*.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := &openEntries{}
+	dir := &filteredDirectory{Directory: entries.dir(root), filter: filter}
+
+	if _, err := planRestore(ctx, dir, t.TempDir(), RestoreOptions{}); err != nil {
+		t.Fatalf("planRestore() error: %v", err)
+	}
+	if _, err := dir.Child(ctx, "src"); !errors.Is(err, fs.ErrEntryNotFound) {
+		t.Fatalf("Child(src) error = %v, want fs.ErrEntryNotFound", err)
+	}
+	docs, err := dir.Child(ctx, "docs")
+	if err != nil {
+		t.Fatalf("Child(docs) error: %v", err)
+	}
+	if _, err := docs.(fs.Directory).Child(ctx, "b.txt"); !errors.Is(err, fs.ErrEntryNotFound) {
+		t.Fatalf("Child(docs/b.txt) error = %v, want fs.ErrEntryNotFound", err)
+	}
+	docs.Close()
+
+	if open := entries.open(); len(open) != 0 {
+		t.Errorf("entries left open: %v", open)
+	}
+}
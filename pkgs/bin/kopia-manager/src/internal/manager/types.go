@@ -2,6 +2,8 @@ package manager
 
 import (
 	"context"
+	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -12,55 +14,138 @@ import (
 // KopiaManager is the main entry point for Kopia operations
 // It coordinates access to specialized managers for different operation types
 type KopiaManager struct {
-	ConfigPath   string
-	PasswordPath string
+	ConfigPath      string
+	PasswordPath    string
+	PasswordCommand string
 
 	// Connection caching - shared across all managers
 	mu         sync.Mutex
 	cachedRepo repo.Repository
 	cachedCtx  context.Context
+	keepOpen   bool
 }
 
 // ServiceStatus represents the status of a systemd service
 type ServiceStatus struct {
-	Unit        string
-	LoadState   string
-	ActiveState string
-	SubState    string
-	Description string
+	Unit        string     `json:"unit"`
+	LoadState   string     `json:"loadState"`
+	ActiveState string     `json:"activeState"`
+	SubState    string     `json:"subState"`
+	Result      string     `json:"result"`
+	Description string     `json:"description"`
+	LastStart   *time.Time `json:"lastStart,omitempty"`
+	LastExit    *time.Time `json:"lastExit,omitempty"`
 }
 
 // TimerStatus represents the status of a systemd timer
 type TimerStatus struct {
-	Next      string
-	Left      string
-	Last      string
-	Passed    string
-	Unit      string
-	Activates string
+	Unit        string     `json:"unit"`
+	ActiveState string     `json:"activeState"`
+	Activates   string     `json:"activates"`
+	Next        *time.Time `json:"next,omitempty"`
+	Last        *time.Time `json:"last,omitempty"`
+}
+
+// SystemdStatus holds the status of the kopia services and timers
+type SystemdStatus struct {
+	Services []ServiceStatus `json:"services"`
+	Timers   []TimerStatus   `json:"timers"`
+}
+
+// BackupServiceStatus pairs a backup service with the timer that starts it
+type BackupServiceStatus struct {
+	Name    string        `json:"name"`
+	Service ServiceStatus `json:"service"`
+	Timer   TimerStatus   `json:"timer"`
+}
+
+// RepositoryStatus describes the connected repository and its sources
+type RepositoryStatus struct {
+	ConfigFile  string         `json:"configFile"`
+	Description string         `json:"description"`
+	Hostname    string         `json:"hostname"`
+	Username    string         `json:"username"`
+	ReadOnly    bool           `json:"readOnly"`
+	Snapshots   int            `json:"snapshots"`
+	Sources     []SourceStatus `json:"sources"`
+}
+
+// SourceStatus describes a backed up source and its latest snapshot
+type SourceStatus struct {
+	Path      string           `json:"path"`
+	Hostname  string           `json:"hostname"`
+	Username  string           `json:"username"`
+	Snapshots int              `json:"snapshots"`
+	Latest    *SnapshotSummary `json:"latest,omitempty"`
+}
+
+// SnapshotDetails extends SnapshotSummary with the statistics shown by km info
+type SnapshotDetails struct {
+	SnapshotSummary
+	RootObjectID     string `json:"rootObjectId"`
+	CachedFiles      int32  `json:"cachedFiles"`
+	NonCachedFiles   int32  `json:"nonCachedFiles"`
+	ErrorCount       int32  `json:"errorCount"`
+	IncompleteReason string `json:"incompleteReason,omitempty"`
+}
+
+// PolicyInfo describes the effective policy of a source.
+//
+// Defined lists the settings set on the source itself rather than inherited.
+type PolicyInfo struct {
+	Source           string          `json:"source"`
+	Compression      string          `json:"compression"`
+	Retention        RetentionCounts `json:"retention"`
+	IgnoreRules      []string        `json:"ignoreRules"`
+	SnapshotInterval string          `json:"snapshotInterval,omitempty"`
+	SnapshotTimes    []string        `json:"snapshotTimes,omitempty"`
+	Defined          []string        `json:"defined"`
+}
+
+// IsDefined reports whether a setting is defined on the source itself
+func (p *PolicyInfo) IsDefined(setting string) bool {
+	return slices.Contains(p.Defined, setting)
+}
+
+// RetentionCounts holds how many snapshots of each kind retention keeps
+type RetentionCounts struct {
+	Latest  int `json:"latest"`
+	Hourly  int `json:"hourly"`
+	Daily   int `json:"daily"`
+	Weekly  int `json:"weekly"`
+	Monthly int `json:"monthly"`
+	Annual  int `json:"annual"`
+}
+
+// String summarizes the counts as annual/monthly/weekly/daily/hourly/latest
+func (c RetentionCounts) String() string {
+	return fmt.Sprintf("%dy/%dm/%dw/%dd/%dh/%dl", c.Annual, c.Monthly, c.Weekly, c.Daily, c.Hourly, c.Latest)
 }
 
 // BackupEstimate represents backup size estimation
 type BackupEstimate struct {
-	Path                string
-	TotalFiles          int64
-	TotalSize           int64
-	EstimatedUploadSize int64
-	EstimatedTime       time.Duration
+	Path                string        `json:"path"`
+	TotalFiles          int64         `json:"totalFiles"`
+	TotalSize           int64         `json:"totalSize"`
+	EstimatedUploadSize int64         `json:"estimatedUploadSize"`
+	EstimatedTime       time.Duration `json:"estimatedTime"`
+	Error               string        `json:"error,omitempty"`
 }
 
 // SnapshotSummary provides a simplified view of snapshot for CLI display
 type SnapshotSummary struct {
-	ID          string
-	Source      string
-	Hostname    string
-	Username    string
-	Description string
-	StartTime   time.Time
-	EndTime     time.Time
-	TotalSize   int64
-	FileCount   int32
-	DirCount    int32
+	ID          string            `json:"id"`
+	Source      string            `json:"source"`
+	Hostname    string            `json:"hostname"`
+	Username    string            `json:"username"`
+	Description string            `json:"description"`
+	StartTime   time.Time         `json:"startTime"`
+	EndTime     time.Time         `json:"endTime"`
+	TotalSize   int64             `json:"totalSize"`
+	FileCount   int32             `json:"fileCount"`
+	DirCount    int32             `json:"dirCount"`
+	Pins        []string          `json:"pins,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
 }
 
 // Constants
@@ -82,5 +167,7 @@ func manifestToSummary(m *snapshot.Manifest) SnapshotSummary {
 		TotalSize:   m.Stats.TotalFileSize,
 		FileCount:   m.Stats.TotalFileCount,
 		DirCount:    m.Stats.TotalDirectoryCount,
+		Pins:        m.Pins,
+		Tags:        userTags(m),
 	}
 }
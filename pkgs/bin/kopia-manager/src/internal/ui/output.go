@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats for the global --output flag
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+)
+
+// OutputFormats lists the accepted values of --output
+var OutputFormats = []string{OutputText, OutputJSON, OutputYAML}
+
+// ValidateOutputFormat checks a value given to --output
+func ValidateOutputFormat(format string) error {
+	for _, f := range OutputFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid output format %q (supported: text, json, yaml)", format)
+}
+
+// structuredOutputAnnotation marks commands that print their data in every output format
+const structuredOutputAnnotation = "km/structured-output"
+
+// SupportStructuredOutput marks commands that honour --output json and yaml
+func SupportStructuredOutput(cmds ...*cobra.Command) {
+	for _, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+		if cmd.Annotations == nil {
+			cmd.Annotations = make(map[string]string)
+		}
+		cmd.Annotations[structuredOutputAnnotation] = "true"
+	}
+}
+
+// CheckOutputSupported rejects --output json or yaml for commands that only print text,
+// rather than silently printing text
+func CheckOutputSupported(cmd *cobra.Command) error {
+	format := OutputFormat(cmd)
+	if format == OutputText || cmd.Annotations[structuredOutputAnnotation] != "" {
+		return nil
+	}
+	return fmt.Errorf("%s does not support --output %s", cmd.CommandPath(), format)
+}
+
+// OutputFormat returns the output format selected for a command.
+// A command's own --json flag is kept as a shorthand for --output json.
+func OutputFormat(cmd *cobra.Command) string {
+	if asJSON, err := cmd.Flags().GetBool("json"); err == nil && asJSON {
+		return OutputJSON
+	}
+	if format, err := cmd.Flags().GetString("output"); err == nil && format != "" {
+		return format
+	}
+	return OutputText
+}
+
+// PrintStructured writes v to stdout in the given structured format.
+//
+// YAML is converted from the JSON encoding so both formats use the same field
+// names and order.
+func PrintStructured(format string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+
+	if format == OutputYAML {
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		resetStyle(&node)
+
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&node); err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+
+	_, err = fmt.Fprintln(os.Stdout, string(data))
+	return err
+}
+
+// resetStyle drops the flow and quoting styles kept from the JSON input
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
@@ -22,6 +22,8 @@ type SimpleProgressBar struct {
 	titlePrinted bool
 	lastUpdate   time.Time
 	lastPercent  float64
+	status       string
+	done         bool
 }
 
 // (Styles now centralized in styles.go)
@@ -47,6 +49,14 @@ func FormatSize(bytes int64) string {
 	return fmt.Sprintf("%.2f %s", float64(bytes)/float64(div), units[exp])
 }
 
+// FormatSizeDelta formats a signed size difference with an explicit sign
+func FormatSizeDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + FormatSize(-delta)
+	}
+	return "+" + FormatSize(delta)
+}
+
 // FormatDuration formats a duration into a human-readable string
 func FormatDuration(d time.Duration) string {
 	d = d.Round(time.Second)
@@ -98,11 +108,21 @@ func NewSimpleProgressBar(title string, total int64) *SimpleProgressBar {
 // Update updates the current progress
 func (spb *SimpleProgressBar) Update(current int64) {
 	spb.current = current
-	if spb.current > spb.total {
+	if spb.total > 0 && spb.current > spb.total {
 		spb.current = spb.total
 	}
 }
 
+// SetTotal changes the expected total, e.g. once a better estimate is known
+func (spb *SimpleProgressBar) SetTotal(total int64) {
+	spb.total = total
+}
+
+// SetStatus sets a text shown after the statistics, such as the current file
+func (spb *SimpleProgressBar) SetStatus(status string) {
+	spb.status = status
+}
+
 // Print prints the progress bar (clearing the line first for in-place updates)
 func (spb *SimpleProgressBar) Print() {
 	// Print title once at the beginning
@@ -134,35 +154,41 @@ func (spb *SimpleProgressBar) Print() {
 	// Render progress bar using bubbles
 	progressBar := spb.prog.ViewAs(percent)
 
-	// Build info string
-	info := fmt.Sprintf("%s / %s", FormatSize(spb.current), FormatSize(spb.total))
-
-	// Calculate speed
-	if spb.current > 0 {
-		elapsed := time.Since(spb.startTime).Seconds()
-		if elapsed > 0 {
-			speed := float64(spb.current) / elapsed
-			info += fmt.Sprintf(" • %s/s", FormatSize(int64(speed)))
-		}
+	info := progressInfo(spb.current, spb.total, time.Since(spb.startTime))
+	if spb.status != "" {
+		info += " • " + spb.status
 	}
 
-	// Calculate ETA
-	if spb.current > 0 && spb.current < spb.total {
-		elapsed := time.Since(spb.startTime).Seconds()
-		if elapsed > 0 {
-			speed := float64(spb.current) / elapsed
-			remaining := float64(spb.total - spb.current)
-			eta := time.Duration(remaining/speed) * time.Second
-			info += fmt.Sprintf(" • ETA: %s", FormatDuration(eta))
-		}
+	// Clear entire line and print progress bar with info, cut to the terminal width so
+	// the line can be redrawn in place
+	line := ansi.Truncate(progressBar+" "+ProgressInfoStyle.Render(info), getTerminalWidth()-1, "…")
+	fmt.Printf("\r%s%s", ansi.EraseLine(0), line)
+}
+
+// progressInfo formats the statistics shown after the bar: the progress, the
+// throughput and the ETA. Without a total only the progress so far is known, and
+// neither throughput nor ETA is shown before any time has passed.
+func progressInfo(current, total int64, elapsed time.Duration) string {
+	info := FormatSize(current)
+	if total > 0 {
+		info += " / " + FormatSize(total)
+	}
+	if current == 0 || elapsed <= 0 {
+		return info
 	}
 
-	// Clear entire line and print progress bar with info
-	fmt.Printf("\r%s%s %s", ansi.EraseLine(0), progressBar, ProgressInfoStyle.Render(info))
+	speed := float64(current) / elapsed.Seconds()
+	info += fmt.Sprintf(" • %s/s", FormatSize(int64(speed)))
+	if current < total {
+		eta := time.Duration(float64(total-current)/speed) * time.Second
+		info += fmt.Sprintf(" • ETA: %s", FormatDuration(eta))
+	}
+	return info
 }
 
 // Finish completes the progress bar and adds a newline
 func (spb *SimpleProgressBar) Finish() {
+	spb.done = true
 	spb.Update(spb.total)
 
 	// Print title if not yet printed
@@ -175,9 +201,25 @@ func (spb *SimpleProgressBar) Finish() {
 	// Render final progress bar at 100%
 	progressBar := spb.prog.ViewAs(1.0)
 	info := fmt.Sprintf("%s / %s", FormatSize(spb.total), FormatSize(spb.total))
+	if spb.status != "" {
+		info += " • " + spb.status
+	}
 
 	// Print final progress bar with newline
-	fmt.Printf("\r%s%s %s\n", ansi.EraseLine(0), progressBar, ProgressInfoStyle.Render(info))
+	line := ansi.Truncate(progressBar+" "+ProgressInfoStyle.Render(info), getTerminalWidth()-1, "…")
+	fmt.Printf("\r%s%s\n", ansi.EraseLine(0), line)
+	fmt.Print(ansi.ShowCursor)
+}
+
+// Stop ends a progress bar that was not finished, e.g. because the operation failed. The
+// bar is left as it was drawn last, so output that follows starts on a new line. Deferring
+// Stop is safe: it does nothing after Finish or when nothing was drawn.
+func (spb *SimpleProgressBar) Stop() {
+	if spb.done || !spb.titlePrinted {
+		return
+	}
+	spb.done = true
+	fmt.Println()
 	fmt.Print(ansi.ShowCursor)
 }
 
@@ -0,0 +1,34 @@
+package ui
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressInfo(t *testing.T) {
+	const mb = 1024 * 1024
+
+	tests := []struct {
+		name    string
+		current int64
+		total   int64
+		elapsed time.Duration
+		want    string
+	}{
+		{name: "nothing read", total: 3 * mb, elapsed: time.Second, want: "0 B / 3.00 MB"},
+		{name: "zero elapsed", current: mb, total: 3 * mb, want: "1.00 MB / 3.00 MB"},
+		{name: "throughput and ETA", current: mb, total: 3 * mb, elapsed: 2 * time.Second, want: "1.00 MB / 3.00 MB • 512.00 KB/s • ETA: 4s"},
+		{name: "long ETA", current: mb, total: 7200 * mb, elapsed: time.Second, want: "1.00 MB / 7.03 GB • 1.00 MB/s • ETA: 1h59m59s"},
+		{name: "complete", current: 3 * mb, total: 3 * mb, elapsed: 3 * time.Second, want: "3.00 MB / 3.00 MB • 1.00 MB/s"},
+		{name: "unknown total", current: mb, elapsed: 4 * time.Second, want: "1.00 MB • 256.00 KB/s"},
+		{name: "unknown total and zero elapsed", current: mb, want: "1.00 MB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := progressInfo(tt.current, tt.total, tt.elapsed); got != tt.want {
+				t.Errorf("progressInfo(%d, %d, %v) = %q, want %q", tt.current, tt.total, tt.elapsed, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// ReadPassword prompts for a password on the terminal without echoing it
+func ReadPassword(message string) (string, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", fmt.Errorf("cannot prompt for a password without a terminal")
+	}
+	fmt.Print(Prompt(message))
+	password, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return strings.TrimSpace(string(password)), nil
+}
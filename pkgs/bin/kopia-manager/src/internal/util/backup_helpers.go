@@ -11,7 +11,8 @@ import (
 
 // ExtractBackupName extracts the backup name from a snapshot's description or source path
 func ExtractBackupName(snap manager.SnapshotSummary) string {
-	desc := snap.Description
+	// Further lines hold details such as hook output
+	desc, _, _ := strings.Cut(snap.Description, "\n")
 	if strings.HasPrefix(desc, "Automated backup: ") {
 		return strings.TrimPrefix(desc, "Automated backup: ")
 	}
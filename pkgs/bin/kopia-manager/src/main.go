@@ -1,13 +1,11 @@
 package main
 
 import (
-	"kopia-manager/cmd"
+	"os"
 
-	"charm.land/log/v2"
+	"kopia-manager/cmd"
 )
 
 func main() {
-	if err := cmd.Execute(); err != nil {
-		log.Fatal("Command failed", "error", err)
-	}
+	os.Exit(cmd.Execute())
 }